@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/streadway/amqp"
 	"log"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -158,3 +159,234 @@ func SetDefaultTimezone() error {
 	log.Printf("时区已设置为: %s", loc.String())
 	return nil
 }
+
+// RateLimitRule 限流规则：window 时间窗口内最多 limit 次，limit 为 0 表示不限制
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// SMSRateLimitConfig 发送短信验证码的限流规则
+type SMSRateLimitConfig struct {
+	PerIP         RateLimitRule `mapstructure:"per-ip"`
+	PerMobileHour RateLimitRule `mapstructure:"per-mobile-hour"`
+	PerMobileDay  RateLimitRule `mapstructure:"per-mobile-day"`
+	Global        RateLimitRule `mapstructure:"global"`
+}
+
+// CaptchaRateLimitConfig 获取人机验证挑战的限流规则
+type CaptchaRateLimitConfig struct {
+	PerIP RateLimitRule `mapstructure:"per-ip"`
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Backend string                 // redis 或 memory
+	SMS     SMSRateLimitConfig     `mapstructure:"sms"`
+	Captcha CaptchaRateLimitConfig `mapstructure:"captcha"`
+}
+
+// LoadRateLimitConfig 从配置文件中读取限流配置，未配置时使用默认规则
+func LoadRateLimitConfig() RateLimitConfig {
+	rateLimitConfig := RateLimitConfig{
+		Backend: "redis",
+		SMS: SMSRateLimitConfig{
+			PerIP:         RateLimitRule{Limit: 1, Window: time.Minute},
+			PerMobileHour: RateLimitRule{Limit: 5, Window: time.Hour},
+			PerMobileDay:  RateLimitRule{Limit: 10, Window: 24 * time.Hour},
+			Global:        RateLimitRule{Limit: 100, Window: time.Minute},
+		},
+		Captcha: CaptchaRateLimitConfig{
+			PerIP: RateLimitRule{Limit: 10, Window: time.Minute},
+		},
+	}
+	if err := viper.UnmarshalKey("ratelimit", &rateLimitConfig); err != nil {
+		log.Printf("读取限流配置失败，使用默认配置: %v", err)
+	}
+	return rateLimitConfig
+}
+
+// GetTrustedProxies 读取可信代理 CIDR 列表，只有来自这些地址的 X-Forwarded-For/Forwarded 头才会被采信
+func GetTrustedProxies() []string {
+	return viper.GetStringSlice("server.trusted-proxies")
+}
+
+// GetSMSCodeSecret 读取计算短信验证码 HMAC 的服务端密钥，环境变量 SMS_CODE_SECRET 优先于配置文件，
+// 避免把生产密钥写进仓库中的 config.yaml
+func GetSMSCodeSecret() string {
+	if secret := os.Getenv("SMS_CODE_SECRET"); secret != "" {
+		return secret
+	}
+	return viper.GetString("sms.code-secret")
+}
+
+// CaptchaConfig 发送短信前的人机验证（工作量证明）配置
+type CaptchaConfig struct {
+	Enabled       bool
+	Difficulty    int           // 哈希前导 0 的比特数，越大客户端计算越久
+	TTL           time.Duration // 挑战的有效期
+	RiskThreshold int           `mapstructure:"risk-threshold"` // 同一 IP 在窗口内前 N 次请求无需验证，0 表示始终需要
+	RiskWindow    time.Duration `mapstructure:"risk-window"`
+}
+
+// LoadCaptchaConfig 从配置文件中读取人机验证配置，未配置时使用默认值
+func LoadCaptchaConfig() CaptchaConfig {
+	captchaConfig := CaptchaConfig{
+		Enabled:       true,
+		Difficulty:    20,
+		TTL:           5 * time.Minute,
+		RiskThreshold: 3,
+		RiskWindow:    time.Hour,
+	}
+	if err := viper.UnmarshalKey("captcha", &captchaConfig); err != nil {
+		log.Printf("读取人机验证配置失败，使用默认配置: %v", err)
+	}
+	return captchaConfig
+}
+
+// OIDCConfig OpenID Connect 第三方登录配置
+type OIDCConfig struct {
+	Enabled           bool
+	Issuer            string
+	ClientID          string `mapstructure:"client-id"`
+	ClientSecret      string `mapstructure:"client-secret"`
+	RedirectURL       string `mapstructure:"redirect-url"` // 身份提供方回调本服务的地址，即 /oidc/callback
+	Scopes            []string
+	PostLoginRedirect string `mapstructure:"post-login-redirect"` // 登录成功后跳转的前端地址，为空时返回 JSON
+}
+
+// LoadOIDCConfig 从配置文件中读取 OIDC 配置
+func LoadOIDCConfig() OIDCConfig {
+	oidcConfig := OIDCConfig{
+		Scopes: []string{"openid", "profile", "email"},
+	}
+	if err := viper.UnmarshalKey("oidc", &oidcConfig); err != nil {
+		log.Printf("读取 OIDC 配置失败: %v", err)
+	}
+	return oidcConfig
+}
+
+// GetMobileChangeWait 丢失原手机号时更换手机号的冷静期，期间原手机号的主人可以取消
+func GetMobileChangeWait() time.Duration {
+	if wait := viper.GetDuration("account.mobile-change-wait"); wait > 0 {
+		return wait
+	}
+	return 72 * time.Hour
+}
+
+// AdminConfig 启动时授予管理员的用户，按手机号或用户ID指定
+type AdminConfig struct {
+	Mobiles    []string
+	CreatorIDs []string `mapstructure:"creator-ids"`
+}
+
+// LoadAdminConfig 从配置文件中读取管理员配置，未配置时不授予任何用户管理员
+func LoadAdminConfig() AdminConfig {
+	var adminConfig AdminConfig
+	if err := viper.UnmarshalKey("admin", &adminConfig); err != nil {
+		log.Printf("读取管理员配置失败: %v", err)
+	}
+	return adminConfig
+}
+
+// CookieConfig 登录 Cookie 的属性配置
+type CookieConfig struct {
+	Secure   bool   // 只通过 HTTPS 发送
+	SameSite string `mapstructure:"same-site"` // lax、strict 或 none，none 时强制 Secure
+	Domain   string // 为空时只对当前域名有效
+}
+
+// LoadCookieConfig 从配置文件中读取 Cookie 配置，未配置时使用 SameSite=Lax
+func LoadCookieConfig() CookieConfig {
+	cookieConfig := CookieConfig{SameSite: "lax"}
+	if err := viper.UnmarshalKey("cookie", &cookieConfig); err != nil {
+		log.Printf("读取 Cookie 配置失败，使用默认配置: %v", err)
+	}
+	return cookieConfig
+}
+
+// CSRFConfig 跨站请求伪造防护配置
+type CSRFConfig struct {
+	Enabled        bool
+	AllowedOrigins []string `mapstructure:"allowed-origins"` // 允许携带 Cookie 发起写请求的前端来源，例如 https://app.example.com
+	RequireOrigin  bool     `mapstructure:"require-origin"`  // 携带登录 Cookie 的写请求缺少 Origin 和 Referer 时是否拒绝
+}
+
+// LoadCSRFConfig 从配置文件中读取 CSRF 防护配置，未配置时默认开启，并拒绝缺少来源的 Cookie 写请求
+func LoadCSRFConfig() CSRFConfig {
+	csrfConfig := CSRFConfig{Enabled: true, RequireOrigin: true}
+	if err := viper.UnmarshalKey("csrf", &csrfConfig); err != nil {
+		log.Printf("读取 CSRF 配置失败，使用默认配置: %v", err)
+	}
+	return csrfConfig
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	Retention     time.Duration // 审计日志保留时长，0 表示永久保留
+	PurgeInterval time.Duration `mapstructure:"purge-interval"` // 清理过期日志的间隔
+}
+
+// LoadAuditConfig 从配置文件中读取审计日志配置，默认保留 180 天
+func LoadAuditConfig() AuditConfig {
+	auditConfig := AuditConfig{
+		Retention:     180 * 24 * time.Hour,
+		PurgeInterval: time.Hour,
+	}
+	if err := viper.UnmarshalKey("audit", &auditConfig); err != nil {
+		log.Printf("读取审计日志配置失败，使用默认配置: %v", err)
+	}
+	if auditConfig.PurgeInterval <= 0 {
+		auditConfig.PurgeInterval = time.Hour
+	}
+	return auditConfig
+}
+
+// PhoneConfig 手机号国家/地区配置
+type PhoneConfig struct {
+	DefaultCountry   string   `mapstructure:"default-country"`   // 手机号不带区号时使用的国家/地区
+	AllowedCountries []string `mapstructure:"allowed-countries"` // 允许注册的国家/地区，ISO 3166-1 二位代码
+}
+
+// LoadPhoneConfig 从配置文件中读取手机号配置，默认只允许中国大陆手机号
+func LoadPhoneConfig() PhoneConfig {
+	phoneConfig := PhoneConfig{
+		DefaultCountry:   "CN",
+		AllowedCountries: []string{"CN"},
+	}
+	if err := viper.UnmarshalKey("phone", &phoneConfig); err != nil {
+		log.Printf("读取手机号配置失败，使用默认配置: %v", err)
+	}
+	return phoneConfig
+}
+
+// TOTPConfig 两步验证配置
+type TOTPConfig struct {
+	Issuer string // 身份验证器 App 中显示的服务名称
+}
+
+// LoadTOTPConfig 从配置文件中读取两步验证配置
+func LoadTOTPConfig() TOTPConfig {
+	totpConfig := TOTPConfig{Issuer: "CalendarReminder"}
+	if err := viper.UnmarshalKey("totp", &totpConfig); err != nil {
+		log.Printf("读取两步验证配置失败，使用默认配置: %v", err)
+	}
+	if totpConfig.Issuer == "" {
+		totpConfig.Issuer = "CalendarReminder"
+	}
+	return totpConfig
+}
+
+// StoreConfig 验证码、登录凭证和会话的存储配置
+type StoreConfig struct {
+	Backend string // redis（多实例共享）或 memory（单机模式，不需要 Redis）
+}
+
+// LoadStoreConfig 从配置文件中读取存储配置，默认使用 Redis
+func LoadStoreConfig() StoreConfig {
+	storeConfig := StoreConfig{Backend: "redis"}
+	if err := viper.UnmarshalKey("store", &storeConfig); err != nil {
+		log.Printf("读取存储配置失败，使用默认配置: %v", err)
+	}
+	return storeConfig
+}
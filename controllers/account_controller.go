@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 未绑定手机号的用户注销账号时，要求当前会话在这段时间内重新登录
+const accountDeletionReauthWindow = 10 * time.Minute
+
+// 注销账号：短信验证码二次确认后删除用户及其全部数据
+func DeleteAccount(w http.ResponseWriter, r *http.Request, accountService services.AccountService, sessionService services.SessionService, auditService services.AuditService, codeStore store.CodeStore) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	var reqBody struct {
+		SmsCode string `json:"smsCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+
+	if user.Mobile != "" {
+		// 通过发送到绑定手机号的验证码再次确认身份
+		result, err := verifySMSCode(codeStore, user.Mobile, reqBody.SmsCode)
+		if err != nil {
+			log.Printf("校验验证码失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "校验验证码失败")
+			return
+		}
+		switch result {
+		case smsCodeLocked:
+			lockRemaining, _ := smsCodeLockRemaining(codeStore, user.Mobile)
+			utils.TooManyRequestsResponse(w, lockRemaining, utils.CodeSMSCodeLocked, "验证码错误次数过多，请稍后再试")
+			return
+		case smsCodeInvalid:
+			log.Printf("注销账号验证码错误, 用户ID: %s", user.CreatorID)
+			recordAudit(r, auditService, user.CreatorID, services.AuditAccountDelete, user.CreatorID, services.AuditFailure, "验证码错误")
+			utils.ErrorResponse(w, http.StatusUnauthorized, "验证码错误")
+			return
+		}
+	} else {
+		// 没有手机号的用户（第三方登录）需要刚刚重新登录过
+		session, err := GetCurrentSession(r)
+		if err != nil || time.Since(session.CreatedAt.Time) > accountDeletionReauthWindow {
+			utils.ErrorResponse(w, http.StatusForbidden, "请重新登录后再注销账号")
+			return
+		}
+	}
+
+	// 删除数据库中的用户数据；用户删除后，队列中已有的提醒消息在消费时会被丢弃
+	receipt, err := accountService.DeleteAccount(user.CreatorID)
+	if err == services.ErrUserNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("注销账号失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "注销账号失败")
+		return
+	}
+
+	// 清理会话和验证码，失败不影响注销结果
+	if err := sessionService.RevokeAllSessions(user.CreatorID); err != nil {
+		log.Printf("清理会话失败: %v", err)
+	}
+	if user.Mobile != "" {
+		if err := codeStore.Delete(MOBILE_SMSCODE+user.Mobile, MOBILE_SMSCODE_FAIL+user.Mobile, MOBILE_SMSCODE_LOCK+user.Mobile); err != nil {
+			log.Printf("清理验证码失败: %v", err)
+		}
+	}
+	if err := clearPendingUserState(codeStore, user.CreatorID); err != nil {
+		log.Printf("清理更换手机号申请和登录凭证失败: %v", err)
+	}
+
+	setCookie(w, "token", "", -time.Hour)
+	setCookie(w, "creator_id", "", -time.Hour)
+
+	log.Printf("账号已注销, 回执编号: %s, 删除提醒数: %d", receipt.ReceiptID, receipt.RemindersDeleted)
+	// 注销后不再保存明文 creator_id，之前的审计日志已替换为哈希，本条只记录回执编号
+	recordAudit(r, auditService, "", services.AuditAccountDelete, receipt.ReceiptID, services.AuditSuccess, "")
+	utils.SuccessResponse(w, receipt, "账号已注销")
+}
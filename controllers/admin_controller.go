@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 管理员按手机号前缀搜索用户
+func AdminSearchUsers(w http.ResponseWriter, r *http.Request, userService services.UserService) {
+	mobile := r.URL.Query().Get("mobile")
+	if mobile == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "请提供要搜索的手机号")
+		return
+	}
+
+	users, err := userService.SearchUsersByMobile(mobile)
+	if err != nil {
+		log.Printf("搜索用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "搜索用户失败")
+		return
+	}
+	utils.SuccessResponse(w, users, "搜索用户成功")
+}
+
+// 管理员禁用或启用用户，禁用时同时让该用户的全部会话下线
+func AdminSetUserDisabled(w http.ResponseWriter, r *http.Request, userService services.UserService, sessionService services.SessionService, auditService services.AuditService, disabled bool) {
+	admin, err := GetCurrentUser(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+	creatorID := mux.Vars(r)["creator_id"]
+	if disabled && creatorID == admin.CreatorID {
+		utils.ErrorResponse(w, http.StatusBadRequest, "不能禁用自己的账号")
+		return
+	}
+
+	err = userService.SetDisabled(creatorID, disabled)
+	if err == services.ErrUserNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("修改用户状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "修改用户状态失败")
+		return
+	}
+
+	if !disabled {
+		log.Printf("管理员 %s 启用了用户 %s", admin.CreatorID, creatorID)
+		recordAudit(r, auditService, admin.CreatorID, services.AuditUserEnable, creatorID, services.AuditSuccess, "")
+		utils.SuccessResponse(w, nil, "用户已启用")
+		return
+	}
+
+	if err := sessionService.RevokeAllSessions(creatorID); err != nil {
+		log.Printf("注销被禁用用户的会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "用户已禁用，但注销会话失败")
+		return
+	}
+	log.Printf("管理员 %s 禁用了用户 %s", admin.CreatorID, creatorID)
+	recordAudit(r, auditService, admin.CreatorID, services.AuditUserDisable, creatorID, services.AuditSuccess, "")
+	utils.SuccessResponse(w, nil, "用户已禁用")
+}
+
+// 管理员查看用户的提醒列表
+func AdminGetUserReminders(w http.ResponseWriter, r *http.Request, userService services.UserService, reminderService services.ReminderService) {
+	creatorID, ok := adminTargetUser(w, r, userService)
+	if !ok {
+		return
+	}
+
+	// 支持与 GET /reminders 相同的分页和筛选参数，时间按服务器时区解释
+	query, err := parseReminderQuery(r, time.Local)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := reminderService.ListReminders(creatorID, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("获取提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取提醒失败")
+		return
+	}
+	utils.SuccessResponse(w, page, "获取提醒列表成功")
+}
+
+// 管理员查看用户最近的提醒投递记录
+func AdminGetUserDeliveries(w http.ResponseWriter, r *http.Request, userService services.UserService, deliveryLogService services.DeliveryLogService) {
+	creatorID, ok := adminTargetUser(w, r, userService)
+	if !ok {
+		return
+	}
+
+	deliveries, err := deliveryLogService.ListByCreatorID(creatorID)
+	if err != nil {
+		log.Printf("获取投递记录失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取投递记录失败")
+		return
+	}
+	utils.SuccessResponse(w, deliveries, "获取投递记录成功")
+}
+
+// 管理员查看用户的登录会话
+func AdminGetUserSessions(w http.ResponseWriter, r *http.Request, userService services.UserService, sessionService services.SessionService) {
+	creatorID, ok := adminTargetUser(w, r, userService)
+	if !ok {
+		return
+	}
+
+	sessions, err := sessionService.ListSessions(creatorID)
+	if err != nil {
+		log.Printf("获取会话列表失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取会话列表失败")
+		return
+	}
+	utils.SuccessResponse(w, sessions, "获取会话列表成功")
+}
+
+// 管理员强制用户下线：指定会话ID时只注销该会话，否则注销全部会话
+func AdminDeleteUserSessions(w http.ResponseWriter, r *http.Request, userService services.UserService, sessionService services.SessionService, auditService services.AuditService) {
+	creatorID, ok := adminTargetUser(w, r, userService)
+	if !ok {
+		return
+	}
+
+	if sessionID := mux.Vars(r)["id"]; sessionID != "" {
+		err := sessionService.RevokeSession(creatorID, sessionID)
+		if err == services.ErrSessionNotFound {
+			utils.ErrorResponse(w, http.StatusNotFound, "会话不存在")
+			return
+		}
+		if err != nil {
+			log.Printf("注销会话失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "注销会话失败")
+			return
+		}
+		log.Printf("管理员强制注销会话, 用户ID: %s, 会话ID: %s", creatorID, sessionID)
+		recordAudit(r, auditService, adminCreatorID(r), services.AuditForceLogout, creatorID, services.AuditSuccess, "会话ID: "+sessionID)
+		utils.SuccessResponse(w, nil, "会话已注销")
+		return
+	}
+
+	if err := sessionService.RevokeAllSessions(creatorID); err != nil {
+		log.Printf("注销全部会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "注销会话失败")
+		return
+	}
+	log.Printf("管理员强制用户下线, 用户ID: %s", creatorID)
+	recordAudit(r, auditService, adminCreatorID(r), services.AuditForceLogout, creatorID, services.AuditSuccess, "全部会话")
+	utils.SuccessResponse(w, nil, "用户已在所有设备上下线")
+}
+
+// adminTargetUser 读取路径中的 creator_id 并确认用户存在，失败时写入响应
+func adminTargetUser(w http.ResponseWriter, r *http.Request, userService services.UserService) (string, bool) {
+	creatorID := mux.Vars(r)["creator_id"]
+	user, err := userService.GetUserByCreatorID(creatorID)
+	if err != nil {
+		log.Printf("查询用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "查询用户失败")
+		return "", false
+	}
+	if user == nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return "", false
+	}
+	return creatorID, true
+}
+
+// adminCreatorID 返回发起请求的管理员的 creator_id
+func adminCreatorID(r *http.Request) string {
+	creatorID, _ := GetCreatorIDFromRequest(r)
+	return creatorID
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 默认的 API Token 有效期（天）
+const defaultApiTokenDays = 30
+
+// 创建 API Token
+func CreateApiToken(w http.ResponseWriter, r *http.Request, apiTokenService services.ApiTokenService) {
+	creatorID, err := GetCreatorIDFromRequest(r)
+	if err != nil {
+		log.Printf("获取 creator_id 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	var reqBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+	if reqBody.ExpiresInDays == 0 {
+		reqBody.ExpiresInDays = defaultApiTokenDays
+	}
+
+	apiToken, token, err := apiTokenService.CreateToken(creatorID, reqBody.Name, reqBody.Scopes, time.Duration(reqBody.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		log.Printf("创建 API Token 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.Printf("API Token 创建成功, 用户ID: %s, ID: %d", creatorID, apiToken.ID)
+	// token 明文只在创建时返回一次
+	utils.SuccessResponse(w, map[string]interface{}{
+		"token":     token,
+		"api_token": apiToken,
+	}, "API Token 创建成功")
+}
+
+// 获取 API Token 列表
+func GetApiTokens(w http.ResponseWriter, r *http.Request, apiTokenService services.ApiTokenService) {
+	creatorID, err := GetCreatorIDFromRequest(r)
+	if err != nil {
+		log.Printf("获取 creator_id 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	tokens, err := apiTokenService.ListTokens(creatorID)
+	if err != nil {
+		log.Printf("获取 API Token 列表失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取 API Token 列表失败")
+		return
+	}
+
+	utils.SuccessResponse(w, tokens, "获取 API Token 列表成功")
+}
+
+// 吊销 API Token
+func DeleteApiToken(w http.ResponseWriter, r *http.Request, apiTokenService services.ApiTokenService) {
+	creatorID, err := GetCreatorIDFromRequest(r)
+	if err != nil {
+		log.Printf("获取 creator_id 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	err = apiTokenService.RevokeToken(id, creatorID)
+	if err == services.ErrApiTokenNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "API Token 不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("吊销 API Token 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "吊销 API Token 失败")
+		return
+	}
+
+	log.Printf("API Token 已吊销, 用户ID: %s, ID: %s", creatorID, id)
+	utils.SuccessResponse(w, nil, "API Token 已吊销")
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 管理员查询审计日志，支持按操作者、操作类型、结果和时间范围过滤
+func GetAuditLogs(w http.ResponseWriter, r *http.Request, auditService services.AuditService) {
+	params := r.URL.Query()
+	query := services.AuditQuery{
+		Actor:   params.Get("actor"),
+		Action:  params.Get("action"),
+		Outcome: params.Get("outcome"),
+	}
+
+	var err error
+	if query.Since, err = parseQueryTime(params.Get("since")); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "since 时间格式不正确")
+		return
+	}
+	if query.Until, err = parseQueryTime(params.Get("until")); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "until 时间格式不正确")
+		return
+	}
+	if page := params.Get("page"); page != "" {
+		if query.Page, err = strconv.Atoi(page); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "page 必须是整数")
+			return
+		}
+	}
+	if pageSize := params.Get("page_size"); pageSize != "" {
+		if query.PageSize, err = strconv.Atoi(pageSize); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "page_size 必须是整数")
+			return
+		}
+	}
+
+	entries, total, err := auditService.Query(query)
+	if err != nil {
+		log.Printf("查询审计日志失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "查询审计日志失败")
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"items": entries,
+		"total": total,
+	}, "查询审计日志成功")
+}
+
+// recordAudit 记录一条安全审计日志，写入失败只记录日志，不影响请求本身
+func recordAudit(r *http.Request, auditService services.AuditService, actor, action, target, outcome, detail string) {
+	entry := &models.AuditLog{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		IP:        utils.GetRequestIP(r),
+		UserAgent: r.UserAgent(),
+		Outcome:   outcome,
+		Detail:    detail,
+	}
+	if err := auditService.Record(entry); err != nil {
+		log.Printf("记录审计日志失败: %v, 操作: %s", err, action)
+	}
+}
+
+// parseQueryTime 解析查询参数中的时间，支持 RFC3339 和 YYYY-MM-DD HH:MM:SS（按服务器时区），为空时返回零值
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// 请求上下文中存放登录用户的 key 类型，避免与其他包冲突
+type contextKey string
+
+const (
+	currentUserKey     contextKey = "current_user"
+	currentSessionKey  contextKey = "current_session"
+	currentApiTokenKey contextKey = "current_api_token"
+)
+
+// AuthMiddleware 鉴权中间件：支持登录会话 token（Cookie 或 Authorization: Bearer）和 Authorization: Bearer 方式的 API Token，
+// 校验通过后将登录用户以及会话或 API Token 注入请求上下文
+func AuthMiddleware(userService services.UserService, sessionService services.SessionService, apiTokenService services.ApiTokenService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			var creatorID string
+
+			if bearer := getBearerToken(r); strings.HasPrefix(bearer, services.ApiTokenPrefix) {
+				// 机器客户端使用 API Token
+				apiToken, err := apiTokenService.Authenticate(bearer)
+				if err != nil {
+					log.Printf("校验 API Token 失败: %v", err)
+					utils.ErrorResponse(w, http.StatusInternalServerError, "校验登录状态失败")
+					return
+				}
+				if apiToken == nil {
+					log.Println("API Token 无效或已过期")
+					utils.ErrorResponse(w, http.StatusUnauthorized, "API Token 无效或已过期")
+					return
+				}
+				creatorID = apiToken.CreatorID
+				ctx = context.WithValue(ctx, currentApiTokenKey, apiToken)
+			} else {
+				// 浏览器使用 Cookie，App 和命令行客户端使用 Bearer 携带登录会话 token
+				token, _ := getSessionToken(r)
+				if token == "" {
+					log.Println("请求未携带 token")
+					utils.ErrorResponse(w, http.StatusUnauthorized, "未登录")
+					return
+				}
+
+				// 校验 token 对应的会话是否仍然有效
+				session, err := sessionService.ValidateToken(token)
+				if err != nil {
+					log.Printf("校验 token 失败: %v", err)
+					utils.ErrorResponse(w, http.StatusInternalServerError, "校验登录状态失败")
+					return
+				}
+				if session == nil {
+					log.Println("token 不存在或已过期")
+					utils.ErrorResponse(w, http.StatusUnauthorized, "登录已失效，请重新登录")
+					return
+				}
+				creatorID = session.CreatorID
+				ctx = context.WithValue(ctx, currentSessionKey, session)
+			}
+
+			// 查询登录用户
+			user, err := userService.GetUserByCreatorID(creatorID)
+			if err != nil {
+				log.Printf("获取用户信息失败: %v", err)
+				utils.ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
+				return
+			}
+			if user == nil {
+				log.Printf("用户不存在, 用户ID: %s", creatorID)
+				utils.ErrorResponse(w, http.StatusUnauthorized, "用户不存在")
+				return
+			}
+			if user.Disabled {
+				log.Printf("用户已被禁用, 用户ID: %s", creatorID)
+				utils.ErrorResponse(w, http.StatusForbidden, "账号已被禁用")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithCurrentUser(ctx, user)))
+		})
+	}
+}
+
+// RequireScope 权限范围中间件：API Token 调用读接口需要 readScope，写接口需要 writeScope；
+// scope 为空表示该接口不允许使用 API Token，只能通过登录会话访问。登录会话拥有全部权限
+func RequireScope(readScope, writeScope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiToken := GetCurrentApiToken(r)
+			if apiToken == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+			if scope == "" || !services.HasScope(apiToken.Scopes, scope) {
+				log.Printf("API Token 权限不足, ID: %d, 需要: %q", apiToken.ID, scope)
+				utils.ErrorResponse(w, http.StatusForbidden, "API Token 权限不足")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin 管理员中间件：只允许管理员通过登录会话访问，需放在 AuthMiddleware 之后
+func RequireAdmin() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := GetCurrentUser(r)
+			if err != nil {
+				utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+				return
+			}
+			if user.Role != services.RoleAdmin || GetCurrentApiToken(r) != nil {
+				log.Printf("非管理员访问管理接口, 用户ID: %s, 路径: %s", user.CreatorID, r.URL.Path)
+				utils.ErrorResponse(w, http.StatusForbidden, "需要管理员权限")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// getBearerToken 从 Authorization 头中提取 Bearer token
+func getBearerToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// getSessionToken 获取请求携带的登录会话 token，优先使用 Authorization: Bearer，其次使用 Cookie；
+// fromCookie 表示 token 是否来自 Cookie
+func getSessionToken(r *http.Request) (token string, fromCookie bool) {
+	if bearer := getBearerToken(r); bearer != "" {
+		return bearer, false
+	}
+	if tokenCookie, err := r.Cookie("token"); err == nil && tokenCookie.Value != "" {
+		return tokenCookie.Value, true
+	}
+	return "", false
+}
+
+// GetCurrentUser 从请求上下文中获取经过鉴权的登录用户
+func GetCurrentUser(r *http.Request) (*models.User, error) {
+	user, ok := r.Context().Value(currentUserKey).(*models.User)
+	if !ok || user == nil {
+		return nil, errors.New("请求上下文中没有登录用户")
+	}
+	return user, nil
+}
+
+// WithCurrentUser 将登录用户写入上下文，供中间件和测试使用
+func WithCurrentUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, currentUserKey, user)
+}
+
+// GetCurrentSession 从请求上下文中获取当前请求所属的登录会话
+func GetCurrentSession(r *http.Request) (*models.Session, error) {
+	session, ok := r.Context().Value(currentSessionKey).(*models.Session)
+	if !ok || session == nil {
+		return nil, errors.New("请求上下文中没有登录会话")
+	}
+	return session, nil
+}
+
+// GetCurrentApiToken 获取当前请求使用的 API Token，使用登录会话访问时返回 nil
+func GetCurrentApiToken(r *http.Request) *models.ApiToken {
+	apiToken, _ := r.Context().Value(currentApiTokenKey).(*models.ApiToken)
+	return apiToken
+}
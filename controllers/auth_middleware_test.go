@@ -0,0 +1,84 @@
+package controllers_test
+
+import (
+	"calendarReminder-service/controllers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// authRequest 通过鉴权中间件发起请求，处理函数返回上下文中登录用户的ID
+func (env *passportTestEnv) authRequest(setup func(r *http.Request)) *httptest.ResponseRecorder {
+	auth := controllers.AuthMiddleware(env.userService, env.sessionService, nil)
+	handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := controllers.GetCurrentUser(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(user.CreatorID))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/reminders", nil)
+	if setup != nil {
+		setup(req)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// 测试鉴权中间件：未携带 token、token 无效或已失效时拒绝，Bearer 与 Cookie 都能登录并注入登录用户
+func TestAuthMiddleware(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	other, err := env.userService.CreateUser("+8615014354724")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	session, token, err := env.sessionService.CreateSession(user.CreatorID, "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	_, otherToken, err := env.sessionService.CreateSession(other.CreatorID, "电脑", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	// 未携带 token
+	rr := env.authRequest(nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// 无效的 token
+	rr = env.authRequest(func(r *http.Request) { r.Header.Set("Authorization", "Bearer invalid") })
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = env.authRequest(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: "invalid"}) })
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Bearer 和 Cookie 都可以携带会话 token，登录用户注入请求上下文
+	rr = env.authRequest(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, user.CreatorID, rr.Body.String())
+	rr = env.authRequest(func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: token}) })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, user.CreatorID, rr.Body.String())
+
+	// 同时携带时优先使用 Bearer
+	rr = env.authRequest(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+otherToken)
+		r.AddCookie(&http.Cookie{Name: "token", Value: token})
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, other.CreatorID, rr.Body.String())
+
+	// 已注销的会话失效
+	assert.NoError(t, env.sessionService.RevokeSession(user.CreatorID, session.ID))
+	rr = env.authRequest(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) })
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// 被禁用的用户不能访问
+	assert.NoError(t, env.userService.SetDisabled(other.CreatorID, true))
+	rr = env.authRequest(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+otherToken) })
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
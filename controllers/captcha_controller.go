@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/ratelimit"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"log"
+	"net/http"
+)
+
+// 获取人机验证挑战接口：按 IP 限流，挑战绑定请求的 IP
+func GetCaptcha(w http.ResponseWriter, r *http.Request, captchaService services.CaptchaService, limiter ratelimit.RateLimiter, rules config.CaptchaRateLimitConfig) {
+	// 不需要登录，每个挑战都会写入存储，限制同一 IP 的请求频率，防止占满存储
+	userIP := utils.GetRequestIP(r)
+	allowed, retryAfter, err := ratelimit.Check(limiter, ratelimit.NewRule("captcha:ip", rules.PerIP, userIP))
+	if err != nil {
+		log.Printf("限流检查失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "生成人机验证失败")
+		return
+	}
+	if !allowed {
+		log.Printf("获取人机验证过于频繁, IP: %s, %v 后可重试", userIP, retryAfter)
+		utils.TooManyRequestsResponse(w, retryAfter, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+		return
+	}
+
+	captcha, err := captchaService.Issue(userIP)
+	if err != nil {
+		log.Printf("生成人机验证挑战失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "生成人机验证失败")
+		return
+	}
+
+	log.Printf("人机验证挑战已生成, ID: %s, 难度: %d", captcha.ID, captcha.Difficulty)
+	utils.SuccessResponse(w, captcha, "获取人机验证成功")
+}
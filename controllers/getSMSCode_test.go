@@ -3,45 +3,134 @@ package controllers_test
 import (
 	"calendarReminder-service/config"
 	"calendarReminder-service/controllers"
+	"calendarReminder-service/models"
+	"calendarReminder-service/ratelimit"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
 	"net/http"
 	"net/http/httptest"
-	"strings"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
-// 测试 GetSMSCode 函数的功能测试
-func TestGetSMSCode(t *testing.T) {
-	// 初始化 Mock Redis 客户端
-	config.InitMockRedis()
+// smsTestEnv 发送验证码接口测试使用的内存存储和内存限流器，不需要 Redis
+type smsTestEnv struct {
+	codeStore      store.CodeStore
+	limiter        ratelimit.RateLimiter
+	rules          config.SMSRateLimitConfig
+	captchaService services.CaptchaService
+}
 
-	// 模拟 HTTP 请求
-	req, err := http.NewRequest("GET", "/getSMSCode?mobile=15014354723", nil)
-	if err != nil {
-		t.Fatalf("创建请求失败: %v", err)
+func newSMSTestEnv(rules config.SMSRateLimitConfig, captchaConfig config.CaptchaConfig) *smsTestEnv {
+	codeStore := store.NewMemoryCodeStore()
+	limiter := ratelimit.NewMemoryRateLimiter()
+	return &smsTestEnv{
+		codeStore:      codeStore,
+		limiter:        limiter,
+		rules:          rules,
+		captchaService: services.NewCaptchaService(codeStore, limiter, captchaConfig),
 	}
+}
 
-	// 创建 HTTP ResponseRecorder 用于记录响应
+func (env *smsTestEnv) getSMSCode(mobile string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/getSMSCode?mobile="+mobile, nil)
 	rr := httptest.NewRecorder()
+	controllers.GetSMSCode(rr, req, env.codeStore, env.limiter, env.rules, env.captchaService)
+	return rr
+}
+
+// lock 模拟手机号因验证码错误次数过多被锁定，锁定时接口在发送短信之前返回
+func (env *smsTestEnv) lock(t *testing.T, mobile string) {
+	if err := env.codeStore.Set(controllers.MOBILE_SMSCODE_LOCK+mobile, "1", 10*time.Minute); err != nil {
+		t.Fatalf("设置锁定状态失败: %v", err)
+	}
+}
 
-	// 创建 Redis 客户端 mock
-	mockRedisClient := config.GetRedisClient()
+// 测试手机号格式不正确时拒绝发送
+func TestGetSMSCodeInvalidMobile(t *testing.T) {
+	env := newSMSTestEnv(config.SMSRateLimitConfig{}, config.CaptchaConfig{})
+	rr := env.getSMSCode("12345")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
 
-	// 使用闭包传递 Redis 客户端
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		controllers.GetSMSCode(w, r, mockRedisClient)
-	})
+// 测试验证码错误次数过多被锁定期间不再下发验证码
+func TestGetSMSCodeLocked(t *testing.T) {
+	env := newSMSTestEnv(config.SMSRateLimitConfig{}, config.CaptchaConfig{})
+	env.lock(t, "+8615014354723")
+
+	rr := env.getSMSCode("15014354723")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "验证码错误次数过多")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
 
-	// 调用处理函数
-	handler.ServeHTTP(rr, req)
+// 测试同一 IP 请求过于频繁时限流
+func TestGetSMSCodeRateLimited(t *testing.T) {
+	env := newSMSTestEnv(config.SMSRateLimitConfig{
+		PerIP: config.RateLimitRule{Limit: 1, Window: time.Minute},
+	}, config.CaptchaConfig{})
+	env.lock(t, "+8615014354723")
 
-	// 检查状态码是否为 200 OK
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("处理程序返回错误状态码: 得到 %v 想要 %v", status, http.StatusOK)
+	rr := env.getSMSCode("15014354723")
+	assert.Contains(t, rr.Body.String(), "验证码错误次数过多", "第一次请求应通过限流")
+
+	rr = env.getSMSCode("15014354723")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "请求过于频繁")
+}
+
+// 测试开启人机验证后未提交人机验证时拒绝发送
+func TestGetSMSCodeCaptchaRequired(t *testing.T) {
+	env := newSMSTestEnv(config.SMSRateLimitConfig{}, config.CaptchaConfig{Enabled: true, Difficulty: 1, TTL: time.Minute})
+
+	rr := env.getSMSCode("15014354723")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "请先完成人机验证")
+
+	solve := func(captcha *models.Captcha) string {
+		nonce := 0
+		for !utils.VerifyProofOfWork(captcha.Challenge, strconv.Itoa(nonce), captcha.Difficulty) {
+			nonce++
+		}
+		return strconv.Itoa(nonce)
 	}
 
-	// 检查响应体内容是否正确
-	expected := "验证码已发送至 15014354723"
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("处理程序返回了意外的响应体: 得到 %v 想要 %v", rr.Body.String(), expected)
+	// 人机验证挑战只能使用一次
+	captcha, err := env.captchaService.Issue("192.0.2.1")
+	assert.NoError(t, err)
+	nonce := solve(captcha)
+	passed, err := env.captchaService.Verify(captcha.ID, nonce, "192.0.2.1")
+	assert.NoError(t, err)
+	assert.True(t, passed)
+	passed, err = env.captchaService.Verify(captcha.ID, nonce, "192.0.2.1")
+	assert.NoError(t, err)
+	assert.False(t, passed)
+
+	// 挑战绑定获取它的 IP，其他 IP 提交的答案无效
+	captcha, err = env.captchaService.Issue("192.0.2.1")
+	assert.NoError(t, err)
+	passed, err = env.captchaService.Verify(captcha.ID, solve(captcha), "198.51.100.1")
+	assert.NoError(t, err)
+	assert.False(t, passed)
+}
+
+// 测试获取人机验证挑战按 IP 限流
+func TestGetCaptchaRateLimit(t *testing.T) {
+	env := newSMSTestEnv(config.SMSRateLimitConfig{}, config.CaptchaConfig{Enabled: true, Difficulty: 1, TTL: time.Minute})
+	rules := config.CaptchaRateLimitConfig{PerIP: config.RateLimitRule{Limit: 2, Window: time.Minute}}
+
+	getCaptcha := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		controllers.GetCaptcha(rr, httptest.NewRequest(http.MethodGet, "/captcha", nil), env.captchaService, env.limiter, rules)
+		return rr
 	}
+	assert.Equal(t, http.StatusOK, getCaptcha().Code)
+	assert.Equal(t, http.StatusOK, getCaptcha().Code)
+	rr := getCaptcha()
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
 }
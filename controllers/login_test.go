@@ -2,105 +2,282 @@ package controllers_test
 
 import (
 	"bytes"
-	"calendarReminder-service/config"
 	"calendarReminder-service/controllers"
 	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/stretchr/testify/mock"
-	"log"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
-// MockUserService 用于模拟 UserService 的行为
-type MockUserService struct {
-	mock.Mock
+// passportTestEnv 登录相关接口测试使用的服务，验证码和会话保存在内存中，不需要 Redis
+type passportTestEnv struct {
+	codeStore      store.CodeStore
+	userService    services.UserService
+	sessionService services.SessionService
+	totpService    services.TOTPService
+	auditService   services.AuditService
 }
 
-// 模拟 QueryMobileIsExist 方法，返回是否存在该手机号
-func (m *MockUserService) QueryMobileIsExist(mobile string) (bool, error) {
-	args := m.Called(mobile)
-	return args.Bool(0), args.Error(1)
+func newPassportTestEnv(t *testing.T) *passportTestEnv {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserTOTP{}, &models.RecoveryCode{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	return &passportTestEnv{
+		codeStore:      store.NewMemoryCodeStore(),
+		userService:    services.NewUserService(db, &utils.SimpleIDGenerator{}),
+		sessionService: services.NewSessionService(store.NewMemorySessionStore()),
+		totpService:    services.NewTOTPService(db, "CalendarReminder"),
+		auditService:   services.NewAuditService(db),
+	}
+}
+
+// login 调用登录接口
+func (env *passportTestEnv) login(mobile, smsCode string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"mobile": mobile, "smsCode": smsCode})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	controllers.Login(rr, req, env.userService, env.sessionService, env.totpService, env.auditService, env.codeStore)
+	return rr
 }
 
-// 模拟 GetUserByMobile 方法，返回用户信息
-func (m *MockUserService) GetUserByMobile(mobile string) (*models.User, error) {
-	args := m.Called(mobile)
-	return args.Get(0).(*models.User), args.Error(1)
+// presetSMSCode 模拟已经下发的短信验证码
+func (env *passportTestEnv) presetSMSCode(t *testing.T, mobile, code string) {
+	if err := env.codeStore.Set(controllers.MOBILE_SMSCODE+mobile, utils.HashSMSCode(mobile, code), 30*time.Minute); err != nil {
+		t.Fatalf("设置验证码失败: %v", err)
+	}
 }
 
-// 模拟 CreateUser 方法，返回新创建的用户
-func (m *MockUserService) CreateUser(mobile string) (*models.User, error) {
-	args := m.Called(mobile)
-	return args.Get(0).(*models.User), args.Error(1)
+func responseCookie(rr *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
 }
 
-// 测试 Login 函数的单元测试
+// 测试短信验证码登录：首次登录自动注册并创建会话，验证码只能使用一次
 func TestLogin(t *testing.T) {
-	log.Println("启动 Login 函数测试")
+	env := newPassportTestEnv(t)
+	env.presetSMSCode(t, "+8615014354723", "123456")
 
-	// 初始化 Mock Redis（可以使用实际 Redis 连接或者 mock）
-	config.InitMockRedis()
+	// 不同写法的手机号对应同一个验证码
+	rr := env.login("150 1435 4723", "123456")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "登录成功")
 
-	// 预设 Redis 中的验证码，确保验证码校验通过
-	mobile := "15014354723"
-	redisKey := fmt.Sprintf("%s:%s", controllers.MOBILE_SMSCODE, mobile)
-	expectedSmsCode := "123456"
-	err := config.RedisClient.Set(config.Ctx, redisKey, expectedSmsCode, 30*time.Minute).Err()
-	if err != nil {
-		t.Fatalf("设置 Redis 验证码失败: %v", err)
+	tokenCookie := responseCookie(rr, "token")
+	if assert.NotNil(t, tokenCookie, "登录成功后应写入 token Cookie") {
+		session, err := env.sessionService.ValidateToken(tokenCookie.Value)
+		assert.NoError(t, err)
+		assert.NotNil(t, session)
+		// Cookie 与会话同时过期
+		assert.WithinDuration(t, time.Now().Add(services.SessionTTL), tokenCookie.Expires, time.Minute)
 	}
 
-	// 创建 MockUserService 实例
-	mockUserService := new(MockUserService)
+	// 响应体中同样返回 token，供不使用 Cookie 的客户端使用
+	var resp struct {
+		Data struct {
+			Token     string `json:"token"`
+			TokenType string `json:"token_type"`
+			ExpiresIn int    `json:"expires_in"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "Bearer", resp.Data.TokenType)
+	if tokenCookie != nil {
+		assert.Equal(t, tokenCookie.Value, resp.Data.Token)
+	}
+	assert.InDelta(t, services.SessionTTL.Seconds(), resp.Data.ExpiresIn, 5)
 
-	// 假设手机号为 15014354723，模拟用户已存在的情况
-	mockUserService.On("QueryMobileIsExist", "15014354723").Return(true, nil)
-	mockUserService.On("GetUserByMobile", "15014354723").Return(&models.User{CreatorID: "12345"}, nil)
+	user, err := env.userService.GetUserByMobile("+8615014354723")
+	assert.NoError(t, err)
+	assert.NotNil(t, user)
 
-	// 构造请求体，模拟登录请求
-	reqBody := map[string]string{
-		"mobile":  "15014354723", // 模拟的手机号码
-		"smsCode": "123456",      // 模拟的验证码
+	// 验证码已被使用
+	rr = env.login("15014354723", "123456")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// 测试输错验证码不会作废正确的验证码
+func TestLoginWrongCodeKeepsCode(t *testing.T) {
+	env := newPassportTestEnv(t)
+	env.presetSMSCode(t, "+8615014354723", "123456")
+
+	rr := env.login("15014354723", "000000")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = env.login("15014354723", "123456")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+// 测试验证码错误次数过多后锁定手机号
+func TestLoginLockout(t *testing.T) {
+	env := newPassportTestEnv(t)
+	env.presetSMSCode(t, "+8615014354723", "123456")
+
+	for i := 0; i < 4; i++ {
+		rr := env.login("15014354723", "000000")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
 	}
-	reqBodyBytes, _ := json.Marshal(reqBody)
+	rr := env.login("15014354723", "000000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
 
-	// 创建 HTTP POST 请求，用于模拟登录请求
-	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(reqBodyBytes))
+	// 锁定期间正确的验证码也不能登录
+	rr = env.login("15014354723", "123456")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+// 测试开启两步验证的用户需要再提交动态验证码才能登录
+func TestLoginTwoFactor(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
 	if err != nil {
-		t.Fatalf("创建 HTTP 请求失败: %v", err)
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	secret, _, err := env.totpService.Enroll(user.CreatorID, user.Mobile)
+	assert.NoError(t, err)
+	code, _ := utils.TOTPCode(secret, time.Now())
+	_, err = env.totpService.Confirm(user.CreatorID, code)
+	assert.NoError(t, err)
+
+	env.presetSMSCode(t, user.Mobile, "123456")
+	rr := env.login(user.Mobile, "123456")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, responseCookie(rr, "token"), "两步验证完成前不应创建会话")
+
+	var resp struct {
+		Data struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			LoginTicket       string `json:"login_ticket"`
+		} `json:"data"`
 	}
-	req.Header.Set("Content-Type", "application/json") // 设置请求头为 JSON 格式
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.True(t, resp.Data.TwoFactorRequired)
+	assert.NotEmpty(t, resp.Data.LoginTicket)
 
-	// 创建 ResponseRecorder 记录 HTTP 响应结果
+	submit := func(code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"login_ticket": resp.Data.LoginTicket, "code": code})
+		req := httptest.NewRequest(http.MethodPost, "/login/2fa", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		controllers.LoginTwoFactor(rr, req, env.userService, env.sessionService, env.totpService, env.auditService, env.codeStore)
+		return rr
+	}
+
+	rr = submit("000000")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// 确认时用过的验证码不能再次使用，使用下一个时间步的验证码
+	next, _ := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	rr = submit(next)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotNil(t, responseCookie(rr, "token"))
+
+	// 登录凭证只能使用一次
+	rr = submit(next)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// enrollTOTP 为用户开启两步验证，返回密钥和恢复码
+func (env *passportTestEnv) enrollTOTP(t *testing.T, user *models.User) (string, []string) {
+	secret, _, err := env.totpService.Enroll(user.CreatorID, user.Mobile)
+	assert.NoError(t, err)
+	code, _ := utils.TOTPCode(secret, time.Now())
+	recoveryCodes, err := env.totpService.Confirm(user.CreatorID, code)
+	assert.NoError(t, err)
+	return secret, recoveryCodes
+}
+
+// submitTwoFactor 调用两步验证登录接口
+func (env *passportTestEnv) submitTwoFactor(ticket, code string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"login_ticket": ticket, "code": code})
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
+	controllers.LoginTwoFactor(rr, req, env.userService, env.sessionService, env.totpService, env.auditService, env.codeStore)
+	return rr
+}
+
+// 测试登录凭证尝试次数达到上限后作废，并发请求也不能突破上限
+func TestLoginTwoFactorAttemptLimit(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	secret, _ := env.enrollTOTP(t, user)
+	ticket := "ticket-limit"
+	assert.NoError(t, env.codeStore.Set(controllers.LOGIN_2FA_TICKET+ticket, `{"creator_id":"`+user.CreatorID+`"}`, time.Minute))
+
+	// 尝试次数在校验前计数：模拟 5 个尚未返回的并发请求已经计数，之后的请求即使验证码正确也会被拒绝
+	for i := 0; i < 5; i++ {
+		_, err := env.codeStore.Incr(controllers.LOGIN_2FA_FAIL+ticket, time.Minute)
+		assert.NoError(t, err)
+	}
 
-	// 构建 HTTP 处理器，调用 Login 函数进行测试
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		controllers.Login(w, r, mockUserService, config.RedisClient)
-	})
+	next, _ := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	rr := env.submitTwoFactor(ticket, next)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "错误次数达到上限后凭证应当作废")
+	assert.Nil(t, responseCookie(rr, "token"))
+}
 
-	// 发送 HTTP 请求，调用被测试的 Login 函数
-	handler.ServeHTTP(rr, req)
+// 测试签发凭证后两步验证被关闭时凭证作废，不能跳过第二步验证
+func TestLoginTwoFactorNotEnrolled(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	_, recoveryCodes := env.enrollTOTP(t, user)
+	ticket := "ticket-disabled"
+	assert.NoError(t, env.codeStore.Set(controllers.LOGIN_2FA_TICKET+ticket, `{"creator_id":"`+user.CreatorID+`"}`, time.Minute))
+	assert.NoError(t, env.totpService.Disable(user.CreatorID, recoveryCodes[0]))
+
+	rr := env.submitTwoFactor(ticket, "000000")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Nil(t, responseCookie(rr, "token"))
+	_, err = env.codeStore.Get(controllers.LOGIN_2FA_TICKET + ticket)
+	assert.Equal(t, store.ErrNotFound, err, "凭证应当作废")
+}
+
+// 测试关闭两步验证按用户限制尝试次数，超过后正确的恢复码也会被拒绝
+func TestDisableTOTPAttemptLimit(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	_, recoveryCodes := env.enrollTOTP(t, user)
 
-	// 检查 HTTP 响应状态码是否为 200 OK
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Login 处理程序返回了错误状态码: 得到 %v, 预期 %v", status, http.StatusOK)
-	} else {
-		log.Printf("Login 请求成功，状态码: %v", rr.Code)
+	disable := func(code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"code": code})
+		req := httptest.NewRequest(http.MethodPost, "/me/2fa/disable", bytes.NewBuffer(body))
+		req = req.WithContext(controllers.WithCurrentUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		controllers.DisableTOTP(rr, req, env.totpService, env.auditService, env.codeStore)
+		return rr
 	}
 
-	// 检查响应体内容是否为“登录成功”
-	expected := "登录成功"
-	if rr.Body.String() != expected {
-		t.Errorf("Login 处理程序返回了意外的响应体: 得到 %v, 预期 %v", rr.Body.String(), expected)
-	} else {
-		log.Printf("Login 请求返回的响应体正确: %v", rr.Body.String())
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusBadRequest, disable("000000").Code)
 	}
+	rr := disable(recoveryCodes[0])
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
 
-	log.Println("Login 函数测试完成")
+	enabled, err := env.totpService.Enabled(user.CreatorID)
+	assert.NoError(t, err)
+	assert.True(t, enabled, "超过尝试次数后两步验证不应被关闭")
 }
@@ -1,52 +1,49 @@
 package controllers_test
 
 import (
-	"calendarReminder-service/config"
 	"calendarReminder-service/controllers"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
-// 测试 Logout 函数的单元测试
+// 测试登出只注销当前会话，不影响其他设备
 func TestLogout(t *testing.T) {
-	log.Println("启动 Logout 函数测试")
-
-	// 初始化 Mock Redis（可以使用实际 Redis 连接或者 mock）
-	config.InitRedis()
-
-	// 构造 HTTP GET 请求，模拟登出请求
-	req, err := http.NewRequest("GET", "/logout?creator_id=12345", nil) // 模拟带有 userID 的登出请求
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
 	if err != nil {
-		t.Fatalf("创建 HTTP 请求失败: %v", err)
+		t.Fatalf("创建用户失败: %v", err)
 	}
-
-	// 创建 ResponseRecorder 用于记录 HTTP 响应结果
+	_, token, err := env.sessionService.CreateSession(user.CreatorID, "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	_, otherToken, err := env.sessionService.CreateSession(user.CreatorID, "电脑", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	auth := controllers.AuthMiddleware(env.userService, env.sessionService, nil)
+	handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controllers.Logout(w, r, env.sessionService, env.auditService)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
 	rr := httptest.NewRecorder()
-
-	// 构建 HTTP 处理器，调用 Logout 函数进行测试
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		controllers.Logout(w, r, config.RedisClient)
-	})
-
-	// 发送 HTTP 请求，调用被测试的 Logout 函数
 	handler.ServeHTTP(rr, req)
-
-	// 检查 HTTP 响应状态码是否为 200 OK
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Logout 处理程序返回了错误状态码: 得到 %v, 预期 %v", status, http.StatusOK)
-	} else {
-		log.Printf("Logout 请求成功，状态码: %v", rr.Code)
-	}
-
-	// 检查响应体内容是否为“登出成功”
-	expected := "登出成功\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Logout 处理程序返回了意外的响应体: 得到 %v, 预期 %v", rr.Body.String(), expected)
-	} else {
-		log.Printf("Logout 请求返回的响应体正确: %v", rr.Body.String())
-	}
-
-	log.Println("Logout 函数测试完成")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "用户登出成功")
+
+	session, err := env.sessionService.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Nil(t, session, "当前会话应已注销")
+	session, err = env.sessionService.ValidateToken(otherToken)
+	assert.NoError(t, err)
+	assert.NotNil(t, session, "其他设备的会话不受影响")
+
+	// 已注销的 token 不能再访问
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
 }
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 存储待生效的更换手机号申请的Key前缀
+const MOBILE_CHANGE_PENDING = "MOBILE_CHANGE_PENDING:"
+
+// 待生效申请在冷静期结束后仍保留的时间，超过后需要重新申请
+const mobileChangeGrace = 7 * 24 * time.Hour
+
+// pendingMobileChange 丢失原手机号时提交的更换申请
+type pendingMobileChange struct {
+	NewMobile   string          `json:"new_mobile"`
+	EffectiveAt models.JSONTime `json:"effective_at"`
+}
+
+// 更换手机号：新手机号必须验证；原手机号可用时同时验证原手机号，立即生效；
+// 原手机号丢失时进入冷静期，冷静期结束后再调用完成接口生效
+func ChangeMobile(w http.ResponseWriter, r *http.Request, userService services.UserService, auditService services.AuditService, codeStore store.CodeStore) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	var reqBody struct {
+		NewMobile     string `json:"new_mobile"`
+		NewSmsCode    string `json:"new_sms_code"`
+		OldSmsCode    string `json:"old_sms_code"`
+		OldMobileLost bool   `json:"old_mobile_lost"` // 原手机号已丢失，无法接收验证码
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+
+	newMobile, err := utils.NormalizePhoneNumber(reqBody.NewMobile)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reqBody.NewMobile = newMobile
+	if reqBody.NewMobile == user.Mobile {
+		utils.ErrorResponse(w, http.StatusBadRequest, "新手机号与当前手机号相同")
+		return
+	}
+	exists, err := userService.QueryMobileIsExist(reqBody.NewMobile)
+	if err != nil {
+		log.Printf("查询手机号是否存在失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "查询用户失败")
+		return
+	}
+	if exists {
+		utils.ErrorResponse(w, http.StatusConflict, services.ErrMobileTaken.Error())
+		return
+	}
+
+	// 原手机号可用时需要同时验证原手机号；第三方登录注册、尚未绑定手机号的用户直接绑定。
+	// 新手机号验证码正确时才校验并作废原手机号验证码，两个都正确后才作废新手机号验证码，
+	// 任一验证码错误都不会用掉另一个
+	if user.Mobile != "" && !reqBody.OldMobileLost {
+		result, err := peekSMSCode(codeStore, reqBody.NewMobile, reqBody.NewSmsCode)
+		if err != nil {
+			log.Printf("校验验证码失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "校验验证码失败")
+			return
+		}
+		if result == smsCodeValid && !checkSMSCode(w, codeStore, user.Mobile, reqBody.OldSmsCode, "原手机号验证码错误") {
+			return
+		}
+	}
+
+	// 验证新手机号，验证码错误时记录错误次数
+	if !checkSMSCode(w, codeStore, reqBody.NewMobile, reqBody.NewSmsCode, "新手机号验证码错误") {
+		return
+	}
+
+	if user.Mobile != "" && reqBody.OldMobileLost {
+		submitPendingMobileChange(w, r, user, reqBody.NewMobile, auditService, codeStore)
+		return
+	}
+
+	applyMobileChange(w, r, user, reqBody.NewMobile, userService, auditService, codeStore)
+}
+
+// 冷静期结束后完成更换手机号
+func CompleteMobileChange(w http.ResponseWriter, r *http.Request, userService services.UserService, auditService services.AuditService, codeStore store.CodeStore) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	pending, err := getPendingMobileChange(codeStore, user.CreatorID)
+	if err != nil {
+		log.Printf("读取更换手机号申请失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更换手机号失败")
+		return
+	}
+	if pending == nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "没有待生效的更换手机号申请")
+		return
+	}
+	if time.Now().Before(pending.EffectiveAt.Time) {
+		utils.ErrorResponse(w, http.StatusConflict, fmt.Sprintf("冷静期尚未结束，请在 %s 之后再试", pending.EffectiveAt.Format("2006-01-02 15:04:05")))
+		return
+	}
+
+	applyMobileChange(w, r, user, pending.NewMobile, userService, auditService, codeStore)
+}
+
+// 取消待生效的更换手机号申请，例如原手机号的主人收到通知后取消
+func CancelMobileChange(w http.ResponseWriter, r *http.Request, codeStore store.CodeStore) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	_, err = codeStore.Take(MOBILE_CHANGE_PENDING + user.CreatorID)
+	if err == store.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "没有待生效的更换手机号申请")
+		return
+	}
+	if err != nil {
+		log.Printf("取消更换手机号申请失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "取消更换手机号申请失败")
+		return
+	}
+
+	log.Printf("更换手机号申请已取消, 用户ID: %s", user.CreatorID)
+	utils.SuccessResponse(w, nil, "更换手机号申请已取消")
+}
+
+// checkSMSCode 校验验证码并在失败时写入响应，返回是否通过
+func checkSMSCode(w http.ResponseWriter, codeStore store.CodeStore, mobile, code, invalidMessage string) bool {
+	result, err := verifySMSCode(codeStore, mobile, code)
+	if err != nil {
+		log.Printf("校验验证码失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "校验验证码失败")
+		return false
+	}
+	switch result {
+	case smsCodeLocked:
+		lockRemaining, _ := smsCodeLockRemaining(codeStore, mobile)
+		utils.TooManyRequestsResponse(w, lockRemaining, utils.CodeSMSCodeLocked, "验证码错误次数过多，请稍后再试")
+		return false
+	case smsCodeInvalid:
+		utils.ErrorResponse(w, http.StatusUnauthorized, invalidMessage)
+		return false
+	}
+	return true
+}
+
+// submitPendingMobileChange 记录更换申请，并通知原手机号以便其主人及时取消
+func submitPendingMobileChange(w http.ResponseWriter, r *http.Request, user *models.User, newMobile string, auditService services.AuditService, codeStore store.CodeStore) {
+	wait := config.GetMobileChangeWait()
+	pending := pendingMobileChange{
+		NewMobile:   newMobile,
+		EffectiveAt: models.JSONTime{Time: time.Now().Add(wait).Truncate(time.Second)},
+	}
+	data, _ := json.Marshal(pending)
+	if err := codeStore.Set(MOBILE_CHANGE_PENDING+user.CreatorID, string(data), wait+mobileChangeGrace); err != nil {
+		log.Printf("保存更换手机号申请失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更换手机号失败")
+		return
+	}
+
+	// 通知失败不影响申请，冷静期本身即是保护
+	notice := fmt.Sprintf("您的账号申请更换手机号，将于%s生效，如非本人操作请登录后取消", pending.EffectiveAt.Format("2006-01-02 15:04"))
+	if err := utils.SendSMSReminder(notice, user.Mobile); err != nil {
+		log.Printf("通知原手机号失败: %v", err)
+	}
+
+	log.Printf("更换手机号申请已提交, 用户ID: %s, 生效时间: %v", user.CreatorID, pending.EffectiveAt.Time)
+	recordAudit(r, auditService, user.CreatorID, services.AuditMobileChange, newMobile, services.AuditSuccess, "原手机号丢失，进入冷静期")
+	utils.SuccessResponse(w, pending, "更换手机号申请已提交，冷静期结束后生效")
+}
+
+// applyMobileChange 更新用户手机号；队列中的提醒在投递时会读取用户当前的手机号
+func applyMobileChange(w http.ResponseWriter, r *http.Request, user *models.User, newMobile string, userService services.UserService, auditService services.AuditService, codeStore store.CodeStore) {
+	err := userService.UpdateMobile(user.CreatorID, newMobile)
+	if err == services.ErrMobileTaken {
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("更新手机号失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更换手机号失败")
+		return
+	}
+	// 手机号已更换，清理失败只记录日志
+	if err := clearPendingUserState(codeStore, user.CreatorID); err != nil {
+		log.Printf("清理更换手机号申请和登录凭证失败: %v", err)
+	}
+
+	log.Printf("手机号更换成功, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditMobileChange, newMobile, services.AuditSuccess, "")
+	utils.SuccessResponse(w, nil, "手机号更换成功")
+}
+
+// clearPendingUserState 作废用户待生效的更换手机号申请和尚未完成两步验证的登录凭证，
+// 更换手机号和注销账号后这些数据不应再被使用
+func clearPendingUserState(codeStore store.CodeStore, creatorID string) error {
+	if err := codeStore.Delete(MOBILE_CHANGE_PENDING + creatorID); err != nil {
+		return err
+	}
+	return revokeLoginTicket(codeStore, creatorID)
+}
+
+// getPendingMobileChange 读取待生效的更换申请，不存在时返回 nil
+func getPendingMobileChange(codeStore store.CodeStore, creatorID string) (*pendingMobileChange, error) {
+	data, err := codeStore.Get(MOBILE_CHANGE_PENDING + creatorID)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending pendingMobileChange
+	if err := json.Unmarshal([]byte(data), &pending); err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
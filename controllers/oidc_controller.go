@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"calendarReminder-service/oidc"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 存储 OIDC 登录状态（state -> nonce、code_verifier）的Key前缀
+const OIDC_STATE = "OIDC_STATE:"
+
+// OIDC 登录状态有效期，用户需要在这段时间内完成身份提供方的登录
+const oidcStateTTL = 10 * time.Minute
+
+// 保存 state 哈希的 Cookie，回调时要求与发起登录的浏览器一致，防止登录 CSRF
+const oidcStateCookie = "oidc_state"
+
+// oidcState 发起登录时保存的状态，回调时取出校验
+type oidcState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Device       string `json:"device"`
+}
+
+// 发起 OIDC 登录：生成 state、nonce 和 PKCE 参数后跳转到身份提供方
+func OIDCLogin(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, codeStore store.CodeStore) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		log.Printf("生成 state 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发起登录失败")
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		log.Printf("生成 nonce 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发起登录失败")
+		return
+	}
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		log.Printf("生成 code_verifier 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发起登录失败")
+		return
+	}
+
+	data, _ := json.Marshal(oidcState{
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Device:       r.URL.Query().Get("device"),
+	})
+	if err := codeStore.Set(OIDC_STATE+state, string(data), oidcStateTTL); err != nil {
+		log.Printf("保存 OIDC 登录状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发起登录失败")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		log.Printf("生成授权地址失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadGateway, "身份提供方不可用")
+		return
+	}
+
+	// 把 state 绑定到发起登录的浏览器，其他人的回调地址无法在这个浏览器上完成登录
+	setOIDCStateCookie(w, hashOIDCState(state), oidcStateTTL)
+
+	log.Println("跳转到身份提供方登录")
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDC 登录回调：校验 state，用授权码换取并校验 ID Token，按第三方身份查找或注册用户后创建会话
+func OIDCCallback(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userService services.UserService, sessionService services.SessionService, totpService services.TOTPService, auditService services.AuditService, codeStore store.CodeStore, postLoginRedirect string) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		log.Printf("身份提供方返回错误: %s %s", errCode, query.Get("error_description"))
+		utils.ErrorResponse(w, http.StatusUnauthorized, "第三方登录失败")
+		return
+	}
+
+	// state 必须与发起登录时写入浏览器的 Cookie 一致，防止攻击者诱导用户登录到攻击者的账号
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashOIDCState(state))) != 1 {
+		log.Println("OIDC state 与浏览器 Cookie 不一致")
+		utils.ErrorResponse(w, http.StatusBadRequest, "登录请求无效或已过期，请重新登录")
+		return
+	}
+	setOIDCStateCookie(w, "", -time.Hour)
+
+	// state 只能使用一次
+	data, err := codeStore.Take(OIDC_STATE + state)
+	if err == store.ErrNotFound {
+		log.Println("OIDC state 无效或已过期")
+		utils.ErrorResponse(w, http.StatusBadRequest, "登录请求无效或已过期，请重新登录")
+		return
+	}
+	if err != nil {
+		log.Printf("读取 OIDC 登录状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "第三方登录失败")
+		return
+	}
+
+	var saved oidcState
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		log.Printf("解析 OIDC 登录状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "第三方登录失败")
+		return
+	}
+
+	claims, err := provider.Exchange(query.Get("code"), saved.CodeVerifier, saved.Nonce)
+	if err != nil {
+		log.Printf("OIDC 授权码换取失败: %v", err)
+		recordAudit(r, auditService, "", services.AuditOIDCLogin, provider.Issuer(), services.AuditFailure, err.Error())
+		utils.ErrorResponse(w, http.StatusUnauthorized, "第三方登录失败")
+		return
+	}
+
+	// 按第三方身份查找用户，不存在时自动注册
+	user, err := userService.GetUserByIdentity(claims.Issuer, claims.Subject)
+	if err != nil {
+		log.Printf("查询第三方身份失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "查询用户失败")
+		return
+	}
+	if user == nil {
+		user, err = userService.CreateUserWithIdentity(claims.Issuer, claims.Subject, claims.Email)
+		if err != nil {
+			log.Printf("通过第三方身份注册用户失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "注册用户失败")
+			return
+		}
+		log.Printf("通过第三方身份注册新用户, 用户ID: %s", user.CreatorID)
+	}
+	if user.Disabled {
+		log.Printf("用户已被禁用, 用户ID: %s", user.CreatorID)
+		recordAudit(r, auditService, user.CreatorID, services.AuditOIDCLogin, claims.Issuer, services.AuditFailure, "账号已被禁用")
+		utils.ErrorResponse(w, http.StatusForbidden, "账号已被禁用")
+		return
+	}
+
+	// 开启了两步验证的用户与短信登录一样，需要通过 /login/2fa 提交动态验证码后才创建会话
+	twoFactorEnabled, err := totpService.Enabled(user.CreatorID)
+	if err != nil {
+		log.Printf("查询两步验证状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "第三方登录失败")
+		return
+	}
+	if twoFactorEnabled {
+		challenge, err := issueLoginTicket(codeStore, user.CreatorID, saved.Device)
+		if err != nil {
+			log.Printf("保存两步验证登录凭证失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "第三方登录失败")
+			return
+		}
+		log.Printf("第三方身份校验通过，等待两步验证, 用户ID: %s", user.CreatorID)
+		if postLoginRedirect != "" {
+			// 登录凭证写入短时有效的 HttpOnly Cookie，不放到地址中，避免通过浏览历史、日志或 Referer 泄露
+			setCookie(w, loginTicketCookie, challenge.LoginTicket, loginTicketTTL)
+			http.Redirect(w, r, withTwoFactorRequired(postLoginRedirect), http.StatusFound)
+			return
+		}
+		utils.SuccessResponse(w, challenge, "请输入身份验证器中的动态验证码")
+		return
+	}
+
+	loginToken, err := startSession(w, r, sessionService, user, saved.Device)
+	if err != nil {
+		log.Printf("创建会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "生成 Token 失败")
+		return
+	}
+
+	log.Printf("第三方登录成功, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditOIDCLogin, claims.Issuer, services.AuditSuccess, "")
+	if postLoginRedirect != "" {
+		// 跳转时只写入 Cookie，不把 token 放到地址中
+		http.Redirect(w, r, postLoginRedirect, http.StatusFound)
+		return
+	}
+	utils.SuccessResponse(w, loginToken, "登录成功")
+}
+
+// withTwoFactorRequired 在登录后跳转地址上标记需要两步验证，登录凭证通过 Cookie 传递
+func withTwoFactorRequired(redirect string) string {
+	target, err := url.Parse(redirect)
+	if err != nil {
+		return redirect
+	}
+	query := target.Query()
+	query.Set("two_factor_required", "true")
+	target.RawQuery = query.Encode()
+	return target.String()
+}
+
+// hashOIDCState Cookie 中只保存 state 的哈希
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// setOIDCStateCookie 写入或清除 state Cookie
+// 身份提供方跳回回调地址属于跨站的顶层跳转，固定使用 SameSite=Lax 才能带上 Cookie
+func setOIDCStateCookie(w http.ResponseWriter, value string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/oidc",
+		Domain:   cookieConfig.Domain,
+		Expires:  time.Now().Add(maxAge),
+		HttpOnly: true,
+		Secure:   cookieConfig.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -3,56 +3,96 @@ package controllers
 import (
 	"calendarReminder-service/config"
 	"calendarReminder-service/models"
+	"calendarReminder-service/ratelimit"
 	"calendarReminder-service/services"
+	"calendarReminder-service/store"
 	"calendarReminder-service/utils"
 	"encoding/json"
 	"fmt"
-	"github.com/go-redis/redis/v8"
 	"log"
-	"math/rand"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// Redis中存储短信验证码的Key前缀
+// 存储短信验证码的Key前缀
 const MOBILE_SMSCODE = "MOBILE_SMSCODE:"
 
-// Redis中存储用户Token的Key前缀
-const REDIS_USER_TOKEN = "REDIS_USER_TOKEN:"
-
 // 发送短信验证码接口
-func GetSMSCode(w http.ResponseWriter, r *http.Request, redisClient *redis.Client) {
+func GetSMSCode(w http.ResponseWriter, r *http.Request, codeStore store.CodeStore, limiter ratelimit.RateLimiter, rules config.SMSRateLimitConfig, captchaService services.CaptchaService) {
 	// 从请求中提取手机号码
 	mobile := r.URL.Query().Get("mobile")
 	log.Printf("收到发送验证码请求, 手机号: %s", mobile)
 
-	// 校验手机号码的格式（可以使用正则表达式）
-	if !utils.IsValidPhoneNumber(mobile) {
-		log.Printf("手机号格式不正确: %s", mobile)
-		utils.ErrorResponse(w, http.StatusBadRequest, "手机号格式不正确")
+	// 校验手机号码的格式，并统一转换为 E.164 格式，同一号码的不同写法对应同一个用户
+	mobile, err := utils.NormalizePhoneNumber(mobile)
+	if err != nil {
+		log.Printf("手机号格式不正确: %s, %v", r.URL.Query().Get("mobile"), err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// 根据用户的IP地址限制请求频率，防止刷短信
+	// 按全局、IP、手机号（每小时/每天）限制请求频率，防止刷短信
 	userIP := utils.GetRequestIP(r)
-	ipKey := fmt.Sprintf(MOBILE_SMSCODE + userIP)
+	allowed, retryAfter, err := ratelimit.Check(limiter,
+		ratelimit.NewRule("sms:global", rules.Global, "all"),
+		ratelimit.NewRule("sms:ip", rules.PerIP, userIP),
+		ratelimit.NewRule("sms:mobile:hour", rules.PerMobileHour, mobile),
+		ratelimit.NewRule("sms:mobile:day", rules.PerMobileDay, mobile),
+	)
+	if err != nil {
+		log.Printf("限流检查失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发送短信失败")
+		return
+	}
+	if !allowed {
+		log.Printf("请求频繁, IP: %s, 手机号: %s, %v 后可重试", userIP, mobile, retryAfter)
+		utils.TooManyRequestsResponse(w, retryAfter, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+		return
+	}
 
-	// 使用Redis的SetNX方法设置一个带过期时间的键，确保60秒内只能发送一次验证码
-	_, err := redisClient.SetNX(config.Ctx, ipKey, mobile, 60*time.Second).Result()
+	// 请求次数超过风险阈值后，需要先通过人机验证才能发送短信
+	captchaRequired, err := captchaService.Required(userIP, mobile)
 	if err != nil {
-		log.Printf("请求频繁，60秒内只能请求一次验证码, IP: %s, 手机号: %s", userIP, mobile)
-		utils.ErrorResponse(w, http.StatusTooManyRequests, "60秒内只能请求一次验证码")
+		log.Printf("评估人机验证风险失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "发送短信失败")
 		return
 	}
+	if captchaRequired {
+		captchaID := r.URL.Query().Get("captcha_id")
+		if captchaID == "" {
+			log.Printf("需要人机验证, IP: %s, 手机号: %s", userIP, mobile)
+			utils.ErrorResponseWithCode(w, http.StatusForbidden, utils.CodeCaptchaRequired, "请先完成人机验证")
+			return
+		}
+		passed, err := captchaService.Verify(captchaID, r.URL.Query().Get("captcha_nonce"), userIP)
+		if err != nil {
+			log.Printf("校验人机验证失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "发送短信失败")
+			return
+		}
+		if !passed {
+			log.Printf("人机验证未通过, IP: %s, 手机号: %s", userIP, mobile)
+			utils.ErrorResponseWithCode(w, http.StatusForbidden, utils.CodeCaptchaInvalid, "人机验证未通过或已过期")
+			return
+		}
+	}
 
-	// 生成6位随机验证码
-	rand.Seed(time.Now().UnixNano())
-	random := fmt.Sprintf("%06d", rand.Intn(1000000))
-	log.Printf("生成验证码: %s, 手机号: %s", random, mobile)
+	// 错误次数过多被锁定期间不再下发新的验证码
+	lockRemaining, err := smsCodeLockRemaining(codeStore, mobile)
+	if err != nil {
+		log.Printf("查询验证码锁定状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "存储验证码失败")
+		return
+	}
+	if lockRemaining > 0 {
+		log.Printf("验证码错误次数过多，手机号已被锁定: %s", mobile)
+		utils.TooManyRequestsResponse(w, lockRemaining, utils.CodeSMSCodeLocked, "验证码错误次数过多，请稍后再试")
+		return
+	}
 
-	// 将验证码存储到Redis，有效期30分钟
-	redisKey := fmt.Sprintf(MOBILE_SMSCODE + mobile)
-	err = redisClient.Set(config.Ctx, redisKey, random, 30*time.Minute).Err()
+	// 生成6位随机验证码，只保存哈希
+	random, err := issueSMSCode(codeStore, mobile)
 	if err != nil {
 		log.Printf("存储验证码失败: %s", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "存储验证码失败")
@@ -72,24 +112,46 @@ func GetSMSCode(w http.ResponseWriter, r *http.Request, redisClient *redis.Clien
 }
 
 // 登录或注册处理
-func Login(w http.ResponseWriter, r *http.Request, userService services.UserService, redisClient *redis.Client) {
+func Login(w http.ResponseWriter, r *http.Request, userService services.UserService, sessionService services.SessionService, totpService services.TOTPService, auditService services.AuditService, codeStore store.CodeStore) {
 	// 从请求中提取手机号码和验证码
 	var reqBody struct {
 		Mobile  string `json:"mobile"`
 		SmsCode string `json:"smsCode"`
+		Device  string `json:"device"` // 可选，设备名称，用于会话列表展示
 	}
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
 		log.Printf("请求体解析失败: %v", err)
 		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
 		return
 	}
-	log.Printf("收到登录/注册请求, 手机号: %s, 验证码: %s", reqBody.Mobile, reqBody.SmsCode)
+	log.Printf("收到登录/注册请求, 手机号: %s", reqBody.Mobile)
+
+	// 验证码按 E.164 格式的手机号保存
+	mobile, err := utils.NormalizePhoneNumber(reqBody.Mobile)
+	if err != nil {
+		log.Printf("手机号格式不正确: %s, %v", reqBody.Mobile, err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reqBody.Mobile = mobile
 
-	// 校验验证码是否正确
-	redisKey := fmt.Sprintf(MOBILE_SMSCODE + reqBody.Mobile)
-	redisSmsCode, err := redisClient.Get(config.Ctx, redisKey).Result()
-	if err != nil || redisSmsCode != reqBody.SmsCode {
-		log.Printf("验证码错误, 手机号: %s, 输入的验证码: %s, Redis中的验证码: %s", reqBody.Mobile, reqBody.SmsCode, redisSmsCode)
+	// 校验验证码是否正确，错误次数过多时锁定
+	result, err := verifySMSCode(codeStore, reqBody.Mobile, reqBody.SmsCode)
+	if err != nil {
+		log.Printf("校验验证码失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "校验验证码失败")
+		return
+	}
+	switch result {
+	case smsCodeLocked:
+		log.Printf("验证码错误次数过多，手机号已被锁定: %s", reqBody.Mobile)
+		recordAudit(r, auditService, "", services.AuditLogin, reqBody.Mobile, services.AuditFailure, "验证码错误次数过多，已锁定")
+		lockRemaining, _ := smsCodeLockRemaining(codeStore, reqBody.Mobile)
+		utils.TooManyRequestsResponse(w, lockRemaining, utils.CodeSMSCodeLocked, "验证码错误次数过多，请稍后再试")
+		return
+	case smsCodeInvalid:
+		log.Printf("验证码错误, 手机号: %s", reqBody.Mobile)
+		recordAudit(r, auditService, "", services.AuditLogin, reqBody.Mobile, services.AuditFailure, "验证码错误")
 		utils.ErrorResponse(w, http.StatusUnauthorized, "验证码错误")
 		return
 	}
@@ -112,6 +174,12 @@ func Login(w http.ResponseWriter, r *http.Request, userService services.UserServ
 			return
 		}
 		log.Printf("用户存在，获取用户信息成功, 用户ID: %s", user.CreatorID)
+		if user.Disabled {
+			log.Printf("用户已被禁用, 用户ID: %s", user.CreatorID)
+			recordAudit(r, auditService, user.CreatorID, services.AuditLogin, reqBody.Mobile, services.AuditFailure, "账号已被禁用")
+			utils.ErrorResponse(w, http.StatusForbidden, "账号已被禁用")
+			return
+		}
 	} else {
 		// 用户不存在，创建新用户
 		user, err = userService.CreateUser(reqBody.Mobile)
@@ -123,29 +191,101 @@ func Login(w http.ResponseWriter, r *http.Request, userService services.UserServ
 		log.Printf("新用户注册成功, 用户ID: %s", user.CreatorID)
 	}
 
-	// 生成分布式Token，并存储在Redis中
-	token := utils.GenerateUUID() // 生成唯一的 UUID token
-	tokenKey := fmt.Sprintf(REDIS_USER_TOKEN + user.CreatorID)
+	// 开启了两步验证的用户还需要提交动态验证码，此时不创建会话
+	twoFactorEnabled, err := totpService.Enabled(user.CreatorID)
+	if err != nil {
+		log.Printf("查询两步验证状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+	if twoFactorEnabled {
+		challenge, err := issueLoginTicket(codeStore, user.CreatorID, reqBody.Device)
+		if err != nil {
+			log.Printf("保存两步验证登录凭证失败: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+			return
+		}
+		log.Printf("短信验证码校验通过，等待两步验证, 用户ID: %s", user.CreatorID)
+		utils.SuccessResponse(w, challenge, "请输入身份验证器中的动态验证码")
+		return
+	}
 
-	if err = redisClient.Set(config.Ctx, tokenKey, token, 24*time.Hour).Err(); err != nil {
-		log.Printf("存储 Token 失败: %v", err)
+	// 为本次登录创建独立会话，不影响其他设备上的登录状态
+	loginToken, err := startSession(w, r, sessionService, user, reqBody.Device)
+	if err != nil {
+		log.Printf("创建会话失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("生成 Token 失败: %v", err))
 		return
 	}
-	log.Printf("Token 生成并存储成功, 用户ID: %s, Token: %s", user.CreatorID, token)
 
-	// 设置cookies
-	setCookie(w, "token", token, 30*24*time.Hour)
-	setCookie(w, "creator_id", user.CreatorID, 30*24*time.Hour)
-	log.Printf("Cookies 设置成功, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditLogin, reqBody.Mobile, services.AuditSuccess, "")
+
+	// 返回成功响应
+	utils.SuccessResponse(w, loginToken, "登录成功")
+}
+
+// sessionToken 登录成功后返回的会话 token，无法使用 Cookie 的 App 和命令行客户端通过 Authorization: Bearer 携带
+type sessionToken struct {
+	Token     string          `json:"token"`
+	TokenType string          `json:"token_type"`
+	ExpiresIn int             `json:"expires_in"` // 剩余有效期，单位秒
+	ExpiresAt models.JSONTime `json:"expires_at"`
+}
 
-	// 删除已使用的验证码
-	if err := redisClient.Del(config.Ctx, redisKey).Err(); err != nil {
-		log.Printf("删除验证码失败: %v", err)
+func newSessionToken(token string, session *models.Session) *sessionToken {
+	return &sessionToken{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresIn: int(time.Until(session.ExpiresAt.Time) / time.Second),
+		ExpiresAt: session.ExpiresAt,
 	}
+}
 
-	// 返回成功响应
-	utils.SuccessResponse(w, nil, "登录成功")
+// 为登录成功的用户创建会话并写入 cookies，device 为空时使用 User-Agent；返回 token 供不使用 Cookie 的客户端使用
+func startSession(w http.ResponseWriter, r *http.Request, sessionService services.SessionService, user *models.User, device string) (*sessionToken, error) {
+	if device == "" {
+		device = r.UserAgent()
+	}
+	session, token, err := sessionService.CreateSession(user.CreatorID, device, utils.GetRequestIP(r), r.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("会话创建成功, 用户ID: %s, 会话ID: %s", user.CreatorID, session.ID)
+
+	// 设置cookies，有效期与会话一致
+	setSessionCookies(w, token, session)
+	log.Printf("Cookies 设置成功, 用户ID: %s", user.CreatorID)
+	return newSessionToken(token, session), nil
+}
+
+// setSessionCookies 写入会话 token 和 creator_id 的 Cookie，在会话过期时同时过期
+func setSessionCookies(w http.ResponseWriter, token string, session *models.Session) {
+	maxAge := time.Until(session.ExpiresAt.Time)
+	setCookie(w, "token", token, maxAge)
+	setCookie(w, "creator_id", session.CreatorID, maxAge)
+}
+
+// 登录 Cookie 的属性，启动时通过 SetCookieConfig 设置
+var (
+	cookieConfig   = config.CookieConfig{SameSite: "lax"}
+	cookieSameSite = http.SameSiteLaxMode
+)
+
+// SetCookieConfig 设置登录 Cookie 的 Secure、SameSite 和 Domain 属性
+func SetCookieConfig(cfg config.CookieConfig) error {
+	switch strings.ToLower(cfg.SameSite) {
+	case "", "lax":
+		cookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		cookieSameSite = http.SameSiteStrictMode
+	case "none":
+		// 浏览器只接受带 Secure 的 SameSite=None Cookie
+		cookieSameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("无效的 SameSite 配置: %s", cfg.SameSite)
+	}
+	cookieConfig = cfg
+	return nil
 }
 
 // 设置Cookie的帮助函数
@@ -154,36 +294,37 @@ func setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration)
 		Name:     name,
 		Value:    value,
 		Path:     "/",
+		Domain:   cookieConfig.Domain,
 		Expires:  time.Now().Add(maxAge),
 		HttpOnly: true, // 设置为 HttpOnly 防止 JavaScript 访问
+		Secure:   cookieConfig.Secure || cookieSameSite == http.SameSiteNoneMode,
+		SameSite: cookieSameSite,
 	})
-	log.Printf("Cookie 设置成功: %s = %s", name, value)
+	// 只记录 Cookie 名称，token 明文不能写入日志
+	log.Printf("Cookie 设置成功: %s", name)
 }
 
-// 用户退出登录
-func Logout(w http.ResponseWriter, r *http.Request, redisClient *redis.Client) {
-	// 从请求中提取用户ID
-	var reqBody struct {
-		CreatorID string `json:"creator_id"` // 将 userID 字段设为字符串类型
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		log.Printf("请求体解析失败: %v", err)
-		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+// 用户退出登录，仅注销发起请求的当前会话
+func Logout(w http.ResponseWriter, r *http.Request, sessionService services.SessionService, auditService services.AuditService) {
+	session, err := GetCurrentSession(r)
+	if err != nil {
+		log.Printf("获取当前会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
 		return
 	}
 
-	log.Printf("用户ID是: %s", reqBody.CreatorID)
-
-	// 删除用户的登录Token
-	tokenKey := fmt.Sprintf(REDIS_USER_TOKEN + reqBody.CreatorID)
-	err := redisClient.Del(config.Ctx, tokenKey).Err()
-	if err != nil {
-		log.Printf("删除Token失败: %v", err)
+	// 删除当前会话
+	if err := sessionService.RevokeSession(session.CreatorID, session.ID); err != nil && err != services.ErrSessionNotFound {
+		log.Printf("注销会话失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("删除Token失败: %v", err))
 		return
 	}
 
-	log.Printf("用户登出成功, 用户ID: %s", reqBody.CreatorID)
+	// 清除客户端 cookies
+	setCookie(w, "token", "", -time.Hour)
+	setCookie(w, "creator_id", "", -time.Hour)
+
+	log.Printf("用户登出成功, 用户ID: %s, 会话ID: %s", session.CreatorID, session.ID)
+	recordAudit(r, auditService, session.CreatorID, services.AuditLogout, session.ID, services.AuditSuccess, "")
 	utils.SuccessResponse(w, nil, "用户登出成功")
 }
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// meResponse 当前用户的账号信息和资料
+type meResponse struct {
+	*models.User
+	Profile          *models.UserProfile `json:"profile"`
+	TwoFactorEnabled bool                `json:"two_factor_enabled"`
+}
+
+// 获取当前用户的账号信息和资料
+func GetMe(w http.ResponseWriter, r *http.Request, profileService services.ProfileService, totpService services.TOTPService) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	profile, err := profileService.GetProfile(user.CreatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取用户资料失败")
+		return
+	}
+
+	twoFactorEnabled, err := totpService.Enabled(user.CreatorID)
+	if err != nil {
+		log.Printf("查询两步验证状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取用户资料失败")
+		return
+	}
+
+	utils.SuccessResponse(w, meResponse{User: user, Profile: profile, TwoFactorEnabled: twoFactorEnabled}, "获取用户资料成功")
+}
+
+// 部分更新当前用户的资料，只修改请求体中出现的字段
+func UpdateMe(w http.ResponseWriter, r *http.Request, profileService services.ProfileService, totpService services.TOTPService) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	var update services.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+
+	profile, err := profileService.UpdateProfile(user.CreatorID, &update)
+	if errors.Is(err, services.ErrInvalidProfile) {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("更新用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更新用户资料失败")
+		return
+	}
+
+	twoFactorEnabled, err := totpService.Enabled(user.CreatorID)
+	if err != nil {
+		log.Printf("查询两步验证状态失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取用户资料失败")
+		return
+	}
+
+	log.Printf("用户资料更新成功, 用户ID: %s", user.CreatorID)
+	utils.SuccessResponse(w, meResponse{User: user, Profile: profile, TwoFactorEnabled: twoFactorEnabled}, "用户资料更新成功")
+}
@@ -6,17 +6,35 @@ import (
 	"calendarReminder-service/services"
 	"calendarReminder-service/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // 创建提醒
-func CreateReminder(w http.ResponseWriter, r *http.Request, userService services.UserService, reminderService services.ReminderService) {
+func CreateReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, profileService services.ProfileService, auditService services.AuditService) {
 	log.Println("开始处理创建提醒的请求")
 
+	// 从鉴权中间件注入的上下文中获取登录用户
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	// 通过第三方登录注册的用户尚未绑定手机号，无法接收短信提醒
+	if user.Mobile == "" {
+		log.Printf("用户未绑定手机号, 用户ID: %s", user.CreatorID)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请先绑定手机号再创建提醒")
+		return
+	}
+
 	var reminder models.Reminder
 	// 解析请求体
 	if err := json.NewDecoder(r.Body).Decode(&reminder); err != nil {
@@ -25,62 +43,70 @@ func CreateReminder(w http.ResponseWriter, r *http.Request, userService services
 		return
 	}
 
-	// 获取当前时间
-	now := time.Now().Add(time.Hour).Truncate(time.Second) // 获取当前时间并截断到秒
-
-	// 设置提醒的创建和更新时间
-	reminder.CreatedAt = models.JSONTime{Time: now}
-	reminder.UpdatedAt = models.JSONTime{Time: now}
+	// 提醒的创建者只能是当前登录用户，忽略请求体中的 creator_id、版本和投递状态
+	reminder.CreatorID = user.CreatorID
+	resetManagedFields(&reminder)
+	reminder.Version = 1
+	reminder.Status = services.ReminderScheduled
+	if reminder.Tags, err = services.NormalizeTags(reminder.Tags); err != nil {
+		log.Printf("标签无效: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	err := reminderService.CreateReminder(&reminder)
+	// 提醒时间按用户资料中的时区解释
+	profile, err := profileService.GetProfile(user.CreatorID)
 	if err != nil {
-		log.Printf("创建提醒失败: %v", err)
+		log.Printf("获取用户资料失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "创建提醒失败")
 		return
 	}
+	loc := services.ProfileLocation(profile)
+	reminder.RemindAt = inUserTimezone(reminder.RemindAt, loc)
 
-	// 获取时间字符串（假设没有时区）
-	remindAtStr := reminder.RemindAt.String() // 这里获取的是一个带时区的字符串
-	remindAtStrWithoutTZ := remindAtStr[:19]  // 去掉时区信息
+	// 获取当前时间
+	now := time.Now().Truncate(time.Second) // 获取当前时间并截断到秒
 
-	// 假设提醒时间通过请求体传递，解析前端传入的时间字符串
-	remindAt, err := time.Parse("2006-01-02 15:04:05", remindAtStrWithoutTZ) // 使用自定义的 JSONTime 类型
+	// 校验重复规则并计算第一次提醒时间：单次提醒的提醒时间必须在未来，重复提醒必须还有未来的提醒
+	if err := services.ValidateRecurrence(&reminder, now); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	occurrence, ok, err := services.NextOccurrence(&reminder, loc, now)
 	if err != nil {
-		log.Printf("时间解析失败: %v", err)
-		utils.ErrorResponse(w, http.StatusBadRequest, "时间格式不正确")
+		log.Printf("重复规则无效: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	now = time.Now().Add(8 * time.Hour).Truncate(time.Second) // 获取当前时间并截断到秒
-
-	// 检查提醒时间是否在未来
-	if remindAt.Before(now) {
-		log.Printf("提醒时间无效: %v", remindAt)
+	if !ok {
+		log.Printf("提醒时间无效: %v, 重复规则: %s", reminder.RemindAt.Time, reminder.RRule)
+		if reminder.RRule != "" {
+			utils.ErrorResponse(w, http.StatusBadRequest, "重复规则没有未来的提醒时间")
+			return
+		}
 		utils.ErrorResponse(w, http.StatusBadRequest, "提醒时间必须是未来的时间")
 		return
 	}
 
-	// 检查提醒时间是否至少距离当前时间一小时
-	//if remindAt.Sub(now) < time.Hour {
-	//	log.Printf("提醒时间距离当前时间少于一小时: %v", remindAt)
-	//	utils.ErrorResponse(w, "提醒时间必须至少在一个小时之后", http.StatusBadRequest)
-	//	return
-	//}
-
-	// 计算提醒时间与当前时间的延迟
-	delay := remindAt.Sub(now).Milliseconds()
+	// 设置提醒的创建和更新时间
+	reminder.CreatedAt = models.JSONTime{Time: now}
+	reminder.UpdatedAt = models.JSONTime{Time: now}
 
-	// 获取用户信息
-	user, err := userService.GetUserByCreatorID(reminder.CreatorID)
-	if err != nil || user == nil {
-		log.Printf("获取用户信息失败: %v", err)
-		utils.ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
+	err = reminderService.CreateReminder(&reminder)
+	if err != nil {
+		log.Printf("创建提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "创建提醒失败")
 		return
 	}
+	recordAudit(r, auditService, user.CreatorID, services.AuditReminderCreate, strconv.FormatUint(uint64(reminder.ID), 10), services.AuditSuccess, "")
 
-	// 发布提醒消息到 RabbitMQ 延迟队列
-	err = rabbitmq.PublishReminderToQueue(reminder.Content, delay, user.Mobile)
+	// 发布第一次提醒到 RabbitMQ 延迟队列，重复提醒的后续提醒由消费者在每次发送后安排
+	err = rabbitmq.ScheduleOccurrence(&reminder, user.Mobile, occurrence)
 	if err != nil {
 		log.Printf("发布消息到队列失败: %v", err)
+		if err := reminderService.UpdateReminderStatus(reminder.ID, services.ReminderFailed, "发布到队列失败: "+err.Error()); err != nil {
+			log.Printf("更新提醒投递状态失败: %v", err)
+		}
 		utils.ErrorResponse(w, http.StatusInternalServerError, "创建提醒成功，但短信提醒无法发送")
 		return
 	}
@@ -90,11 +116,11 @@ func CreateReminder(w http.ResponseWriter, r *http.Request, userService services
 }
 
 // 获取用户的提醒列表
-func GetReminders(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService) {
+func GetReminders(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, profileService services.ProfileService) {
 	// 日志记录：开始处理获取提醒列表的请求
 	log.Println("开始处理获取提醒列表的请求")
 
-	// 从请求的 cookie 中获取 creator_id
+	// 从请求上下文中获取登录用户的 creator_id
 	creatorID, err := GetCreatorIDFromRequest(r)
 	if err != nil {
 		log.Printf("获取 creator_id 失败: %v", err)
@@ -104,23 +130,93 @@ func GetReminders(w http.ResponseWriter, r *http.Request, reminderService servic
 	// 日志记录：获取提醒的创建者ID
 	log.Printf("获取创建者ID: %s 的提醒列表", creatorID)
 
-	// 调用服务层获取提醒列表
-	reminders, err := reminderService.GetRemindersByCreatorID(creatorID)
+	// 筛选时间按用户资料中的时区解释，返回的提醒时间也转换到该时区
+	profile, err := profileService.GetProfile(creatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取提醒失败")
+		return
+	}
+	loc := services.ProfileLocation(profile)
+
+	query, err := parseReminderQuery(r, loc)
+	if err != nil {
+		log.Printf("查询参数无效: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 调用服务层分页查询提醒
+	page, err := reminderService.ListReminders(creatorID, query)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		log.Printf("获取提醒失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "获取提醒失败")
 		return
 	}
+	for i := range page.Items {
+		page.Items[i].RemindAt.Time = page.Items[i].RemindAt.In(loc)
+	}
 
 	// 日志记录：成功获取提醒列表
-	log.Printf("提醒列表获取成功: %+v", reminders)
+	log.Printf("提醒列表获取成功, 本页 %d 条, 共 %d 条", len(page.Items), page.Total)
 
 	// 返回提醒列表
-	utils.SuccessResponse(w, reminders, "获取提醒列表成功")
+	utils.SuccessResponse(w, page, "获取提醒列表成功")
+}
+
+// 获取单个提醒
+func GetReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, profileService services.ProfileService) {
+	log.Println("开始处理获取提醒的请求")
+
+	id, err := getIDFromRequest(r)
+	if err != nil {
+		log.Println("提醒ID不存在")
+		utils.ErrorResponse(w, http.StatusBadRequest, "提醒ID不存在")
+		return
+	}
+
+	creatorID, err := GetCreatorIDFromRequest(r)
+	if err != nil {
+		log.Printf("获取 creator_id 失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	// 不存在或属于其他用户的提醒都返回 404，不暴露提醒是否存在
+	reminder, err := reminderService.GetReminder(id, creatorID)
+	if err != nil {
+		if errors.Is(err, services.ErrReminderNotFound) {
+			log.Printf("提醒不存在, ID: %s, 创建者ID: %s", id, creatorID)
+			utils.ErrorResponse(w, http.StatusNotFound, "提醒不存在")
+			return
+		}
+		log.Printf("获取提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取提醒失败")
+		return
+	}
+
+	// 按用户资料中的时区返回提醒时间
+	profile, err := profileService.GetProfile(creatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取提醒失败")
+		return
+	}
+	loc := services.ProfileLocation(profile)
+	reminder.RemindAt.Time = reminder.RemindAt.In(loc)
+	if reminder.SnoozedUntil != nil {
+		reminder.SnoozedUntil.Time = reminder.SnoozedUntil.In(loc)
+	}
+
+	utils.SuccessResponse(w, reminder, "获取提醒成功")
 }
 
 // 删除提醒
-func DeleteReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService) {
+func DeleteReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, auditService services.AuditService) {
 	// 日志记录：开始处理删除提醒的请求
 	log.Println("开始处理删除提醒的请求")
 
@@ -132,7 +228,7 @@ func DeleteReminder(w http.ResponseWriter, r *http.Request, reminderService serv
 		return
 	}
 
-	// 从请求的 cookie 中获取 creator_id
+	// 从请求上下文中获取登录用户的 creator_id
 	creatorID, err := GetCreatorIDFromRequest(r)
 	if err != nil {
 		log.Printf("获取 creator_id 失败: %v", err)
@@ -145,17 +241,24 @@ func DeleteReminder(w http.ResponseWriter, r *http.Request, reminderService serv
 
 	// 调用服务层删除提醒
 	if err := reminderService.DeleteReminder(id, creatorID); err != nil {
+		if errors.Is(err, services.ErrReminderNotFound) {
+			log.Printf("提醒不存在, ID: %s, 创建者ID: %s", id, creatorID)
+			utils.ErrorResponse(w, http.StatusNotFound, "提醒不存在")
+			return
+		}
 		log.Printf("删除提醒失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "删除提醒失败")
 		return
 	}
 
+	recordAudit(r, auditService, creatorID, services.AuditReminderDelete, id, services.AuditSuccess, "")
+
 	// 删除成功，返回无内容状态
 	utils.SuccessResponse(w, nil, "删除提醒成功")
 }
 
 // 更新提醒
-func UpdateReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService) {
+func UpdateReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, profileService services.ProfileService, auditService services.AuditService) {
 	// 日志记录：开始处理更新提醒的请求
 	log.Println("开始处理更新提醒的请求")
 
@@ -167,27 +270,118 @@ func UpdateReminder(w http.ResponseWriter, r *http.Request, reminderService serv
 		return
 	}
 
-	// 定义提醒对象，解析请求体
-	var reminder models.Reminder
-	if err := json.NewDecoder(r.Body).Decode(&reminder); err != nil {
+	// 解析请求体，只接受允许用户修改的字段
+	var req reminderUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("解析请求体失败: %v", err)
 		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
 		return
 	}
 
-	// 从请求的 cookie 中获取 creator_id
-	creatorID, err := GetCreatorIDFromRequest(r)
+	// 从请求上下文中获取登录用户
+	user, err := GetCurrentUser(r)
 	if err != nil {
-		log.Printf("获取 creator_id 失败: %v", err)
+		log.Printf("获取登录用户失败: %v", err)
 		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
 		return
 	}
+	creatorID := user.CreatorID
+
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		log.Printf("标签无效: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 查询要更新的提醒，不存在或属于其他用户时返回 404
+	existing, err := reminderService.GetReminder(id, creatorID)
+	if err != nil {
+		if errors.Is(err, services.ErrReminderNotFound) {
+			log.Printf("提醒不存在, ID: %s, 创建者ID: %s", id, creatorID)
+			utils.ErrorResponse(w, http.StatusNotFound, "提醒不存在")
+			return
+		}
+		log.Printf("查询提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更新提醒失败")
+		return
+	}
+
+	// 已经发送的单次提醒不能再修改
+	if existing.RRule == "" && (existing.Status == services.ReminderSent || existing.Status == services.ReminderSending) {
+		log.Printf("提醒已发送，不能修改, ID: %s", id)
+		utils.ErrorResponse(w, http.StatusConflict, "提醒已发送，不能修改")
+		return
+	}
+
+	// 提醒时间和重复规则按用户资料中的时区解释
+	profile, err := profileService.GetProfile(creatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "更新提醒失败")
+		return
+	}
+	loc := services.ProfileLocation(profile)
+
+	// 合并更新后重新计算下一次提醒时间，不允许把提醒改到过去；rrule 传空字符串表示改为单次提醒
+	updated := *existing
+	updated.Tags = tags
+	if req.Content != nil && *req.Content != "" {
+		updated.Content = *req.Content
+	}
+	if req.RemindAt != nil && !req.RemindAt.IsZero() {
+		updated.RemindAt = inUserTimezone(*req.RemindAt, loc)
+	}
+	if req.RRule != nil {
+		updated.RRule = *req.RRule
+		// 改为单次提醒时一并清空排除日期
+		if updated.RRule == "" {
+			updated.ExDate = ""
+		}
+	}
+	if req.ExDate != nil {
+		updated.ExDate = *req.ExDate
+	}
+	now := time.Now()
+	if err := services.ValidateRecurrence(&updated, now); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	occurrence, ok, err := services.NextOccurrence(&updated, loc, now)
+	if err != nil {
+		log.Printf("重复规则无效: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ok {
+		log.Printf("提醒时间无效: %v, 重复规则: %s", updated.RemindAt.Time, updated.RRule)
+		if updated.RRule != "" {
+			utils.ErrorResponse(w, http.StatusBadRequest, "重复规则没有未来的提醒时间")
+			return
+		}
+		utils.ErrorResponse(w, http.StatusBadRequest, "提醒时间必须是未来的时间")
+		return
+	}
+
+	// 更新后提升版本号，队列中旧版本的消息到期时会被丢弃
+	updated.Version = existing.Version + 1
+	updated.Status = services.ReminderScheduled
 
 	// 日志记录：尝试更新提醒
-	log.Printf("尝试更新提醒, ID: %s, 创建者ID: %s, 更新内容: %+v", id, creatorID, reminder)
+	log.Printf("尝试更新提醒, ID: %s, 创建者ID: %s, 版本号: %d", id, creatorID, updated.Version)
 
 	// 调用服务层更新提醒
-	if err := reminderService.UpdateReminder(id, &reminder, creatorID); err != nil {
+	if err := reminderService.UpdateReminder(id, &updated, creatorID); err != nil {
+		if errors.Is(err, services.ErrReminderNotFound) {
+			log.Printf("提醒已被删除, ID: %s", id)
+			utils.ErrorResponse(w, http.StatusNotFound, "提醒不存在")
+			return
+		}
+		if errors.Is(err, services.ErrReminderConflict) {
+			log.Printf("提醒已被其他请求修改, ID: %s", id)
+			utils.ErrorResponse(w, http.StatusConflict, "提醒已被修改，请刷新后重试")
+			return
+		}
 		log.Printf("更新提醒失败: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "更新提醒失败")
 		return
@@ -195,18 +389,244 @@ func UpdateReminder(w http.ResponseWriter, r *http.Request, reminderService serv
 
 	// 日志记录：成功更新提醒
 	log.Println("提醒更新成功")
+	recordAudit(r, auditService, creatorID, services.AuditReminderUpdate, id, services.AuditSuccess, "")
+
+	// 按新的提醒时间重新发布到延迟队列
+	if err := rabbitmq.ScheduleOccurrence(&updated, user.Mobile, occurrence); err != nil {
+		log.Printf("发布消息到队列失败: %v", err)
+		if err := reminderService.UpdateReminderStatus(updated.ID, services.ReminderFailed, "发布到队列失败: "+err.Error()); err != nil {
+			log.Printf("更新提醒投递状态失败: %v", err)
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "提醒更新成功，但短信提醒无法发送")
+		return
+	}
 
 	// 返回更新后的提醒信息
 	utils.SuccessResponse(w, nil, "提醒更新成功")
 }
 
-// 从请求中获取 creator_id
+// 推迟提醒的最长时间
+const maxSnoozeDuration = 30 * 24 * time.Hour
+
+// snoozeRequest 推迟提醒的请求体，duration 和 until 二选一
+type snoozeRequest struct {
+	Duration string           `json:"duration"` // 推迟的时长，例如 10m、1h30m
+	Until    *models.JSONTime `json:"until"`    // 推迟到的时间，按用户资料中的时区解释
+}
+
+// 推迟提醒
+func SnoozeReminder(w http.ResponseWriter, r *http.Request, reminderService services.ReminderService, profileService services.ProfileService, auditService services.AuditService) {
+	log.Println("开始处理推迟提醒的请求")
+
+	id, err := getIDFromRequest(r)
+	if err != nil {
+		log.Println("提醒ID不存在")
+		utils.ErrorResponse(w, http.StatusBadRequest, "提醒ID不存在")
+		return
+	}
+
+	var req snoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("解析请求体失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+	if user.Mobile == "" {
+		log.Printf("用户未绑定手机号, 用户ID: %s", user.CreatorID)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请先绑定手机号再推迟提醒")
+		return
+	}
+
+	reminder, err := reminderService.GetReminder(id, user.CreatorID)
+	if err != nil {
+		if errors.Is(err, services.ErrReminderNotFound) {
+			log.Printf("提醒不存在, ID: %s, 创建者ID: %s", id, user.CreatorID)
+			utils.ErrorResponse(w, http.StatusNotFound, "提醒不存在")
+			return
+		}
+		log.Printf("查询提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "推迟提醒失败")
+		return
+	}
+
+	profile, err := profileService.GetProfile(user.CreatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "推迟提醒失败")
+		return
+	}
+	loc := services.ProfileLocation(profile)
+
+	// 计算推迟到的时间
+	now := time.Now()
+	var until time.Time
+	switch {
+	case req.Duration != "" && req.Until != nil:
+		utils.ErrorResponse(w, http.StatusBadRequest, "duration 和 until 只能传一个")
+		return
+	case req.Duration != "":
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			log.Printf("推迟时长无效: %q", req.Duration)
+			utils.ErrorResponse(w, http.StatusBadRequest, "推迟时长无效")
+			return
+		}
+		until = now.Add(duration)
+	case req.Until != nil:
+		until = inUserTimezone(*req.Until, loc).Time
+	default:
+		utils.ErrorResponse(w, http.StatusBadRequest, "请传入推迟时长 duration 或推迟到的时间 until")
+		return
+	}
+	if !until.After(now) {
+		log.Printf("推迟到的时间无效: %v", until)
+		utils.ErrorResponse(w, http.StatusBadRequest, "推迟到的时间必须是未来的时间")
+		return
+	}
+	if until.Sub(now) > maxSnoozeDuration {
+		log.Printf("推迟时间过长: %v", until)
+		utils.ErrorResponse(w, http.StatusBadRequest, "最多推迟 30 天")
+		return
+	}
+
+	if err := reminderService.SnoozeReminder(reminder, until); err != nil {
+		if errors.Is(err, services.ErrReminderConflict) {
+			log.Printf("提醒已被其他请求修改, ID: %s", id)
+			utils.ErrorResponse(w, http.StatusConflict, "提醒已被修改，请刷新后重试")
+			return
+		}
+		log.Printf("推迟提醒失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "推迟提醒失败")
+		return
+	}
+	recordAudit(r, auditService, user.CreatorID, services.AuditReminderSnooze, id, services.AuditSuccess, "")
+
+	// 通过延迟队列在推迟到的时间重新发送
+	if err := rabbitmq.ScheduleSnooze(reminder, user.Mobile, until); err != nil {
+		log.Printf("发布消息到队列失败: %v", err)
+		if err := reminderService.UpdateReminderStatus(reminder.ID, services.ReminderFailed, "发布到队列失败: "+err.Error()); err != nil {
+			log.Printf("更新提醒投递状态失败: %v", err)
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "提醒已推迟，但短信提醒无法发送")
+		return
+	}
+
+	log.Printf("提醒已推迟到 %v, ID: %s, 推迟次数: %d", until, id, reminder.SnoozeCount)
+	reminder.RemindAt.Time = reminder.RemindAt.In(loc)
+	reminder.SnoozedUntil.Time = reminder.SnoozedUntil.In(loc)
+	utils.SuccessResponse(w, reminder, "提醒已推迟")
+}
+
+// inUserTimezone 把不带时区的提醒时间（YYYY-MM-DD HH:MM:SS）解释为用户所在时区的时间，
+// 请求中已经带有时区偏移（RFC3339）时保持不变
+func inUserTimezone(remindAt models.JSONTime, loc *time.Location) models.JSONTime {
+	if remindAt.HasOffset() {
+		return remindAt
+	}
+	t := remindAt.Time
+	return models.JSONTime{Time: time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)}
+}
+
+// reminderUpdateRequest 更新提醒的请求体，只包含允许用户修改的字段，字段为 nil 表示不修改；
+// 归属、版本、投递状态和推迟记录由服务端维护
+type reminderUpdateRequest struct {
+	Content  *string          `json:"content"`
+	RemindAt *models.JSONTime `json:"remind_at"`
+	RRule    *string          `json:"rrule"`  // 空字符串表示改为单次提醒，同时清空排除日期
+	ExDate   *string          `json:"exdate"` // 空字符串表示清空排除日期
+	Tags     []string         `json:"tags"`   // 不为 nil 时替换全部标签
+}
+
+// resetManagedFields 清空请求体中由服务端维护的字段：ID、版本、投递状态、推迟记录和时间戳；
+// 推迟次数用于判断队列中的消息是否过期，不能由客户端指定
+func resetManagedFields(reminder *models.Reminder) {
+	reminder.ID = 0
+	reminder.Version = 0
+	reminder.Status = ""
+	reminder.SentAt = nil
+	reminder.Attempts = 0
+	reminder.LastError = ""
+	reminder.SnoozeCount = 0
+	reminder.SnoozedUntil = nil
+	reminder.CreatedAt = models.JSONTime{}
+	reminder.UpdatedAt = models.JSONTime{}
+}
+
+// parseReminderQuery 解析提醒列表的查询参数，from、to 不带时区时按 loc 解释
+func parseReminderQuery(r *http.Request, loc *time.Location) (services.ReminderQuery, error) {
+	params := r.URL.Query()
+	query := services.ReminderQuery{
+		Keyword: strings.TrimSpace(params.Get("q")),
+		Sort:    params.Get("sort"),
+		Cursor:  params.Get("cursor"),
+	}
+
+	// 按投递状态筛选，支持 ?status=sent,failed 或重复传入 status
+	for _, status := range listParam(params["status"]) {
+		if !services.IsValidReminderStatus(status) {
+			return query, fmt.Errorf("不支持的投递状态: %s", status)
+		}
+		query.Statuses = append(query.Statuses, status)
+	}
+	query.Tags = listParam(params["tag"])
+
+	if query.Sort != "" && !services.IsValidReminderSort(query.Sort) {
+		return query, fmt.Errorf("不支持的排序方式: %s", query.Sort)
+	}
+
+	var err error
+	if query.From, err = parseReminderQueryTime(params.Get("from"), loc); err != nil {
+		return query, fmt.Errorf("from 时间格式不正确")
+	}
+	if query.To, err = parseReminderQueryTime(params.Get("to"), loc); err != nil {
+		return query, fmt.Errorf("to 时间格式不正确")
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("limit 必须是正整数")
+		}
+	}
+	return query, nil
+}
+
+// parseReminderQueryTime 解析筛选时间，支持 RFC3339 和按 loc 解释的 YYYY-MM-DD HH:MM:SS
+func parseReminderQueryTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, loc)
+}
+
+// listParam 合并逗号分隔或重复传入的查询参数，忽略空值
+func listParam(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// 从请求上下文中获取经过鉴权的 creator_id
 func GetCreatorIDFromRequest(r *http.Request) (string, error) {
-	cookie, err := r.Cookie("creator_id") // 获取存储 creator_id 的 cookie
+	user, err := GetCurrentUser(r) // 由 AuthMiddleware 注入
 	if err != nil {
-		return "", err // 如果没有找到 cookie，返回错误
+		return "", err
 	}
-	return cookie.Value, nil // 返回 cookie 的值
+	return user.CreatorID, nil
 }
 
 // 从请求中获取提醒ID的工具函数
@@ -4,18 +4,30 @@ import (
 	"bytes"
 	"calendarReminder-service/controllers"
 	"calendarReminder-service/models"
+	"calendarReminder-service/services"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 // MockReminderService 是一个模拟的提醒服务，用于测试
 type MockReminderService struct {
-	CreateReminderFunc          func(reminder *models.Reminder) error
-	GetRemindersByCreatorIDFunc func(creatorID string) ([]models.Reminder, error)
-	DeleteReminderFunc          func(id, creatorID string) error
-	UpdateReminderFunc          func(id string, reminder *models.Reminder, creatorID string) error
+	CreateReminderFunc       func(reminder *models.Reminder) error
+	ListRemindersFunc        func(creatorID string, query services.ReminderQuery) (*services.ReminderPage, error)
+	GetReminderFunc          func(id string, creatorID string) (*models.Reminder, error)
+	GetReminderByIDFunc      func(id uint) (*models.Reminder, error)
+	UpdateReminderStatusFunc func(id uint, status string, lastError string) error
+	SnoozeReminderFunc       func(reminder *models.Reminder, until time.Time) error
+	DeleteReminderFunc       func(id, creatorID string) error
+	UpdateReminderFunc       func(id string, reminder *models.Reminder, creatorID string) error
 }
 
 // 实现 ReminderService 接口
@@ -23,8 +35,24 @@ func (m *MockReminderService) CreateReminder(reminder *models.Reminder) error {
 	return m.CreateReminderFunc(reminder)
 }
 
-func (m *MockReminderService) GetRemindersByCreatorID(creatorID string) ([]models.Reminder, error) {
-	return m.GetRemindersByCreatorIDFunc(creatorID)
+func (m *MockReminderService) ListReminders(creatorID string, query services.ReminderQuery) (*services.ReminderPage, error) {
+	return m.ListRemindersFunc(creatorID, query)
+}
+
+func (m *MockReminderService) GetReminder(id string, creatorID string) (*models.Reminder, error) {
+	return m.GetReminderFunc(id, creatorID)
+}
+
+func (m *MockReminderService) GetReminderByID(id uint) (*models.Reminder, error) {
+	return m.GetReminderByIDFunc(id)
+}
+
+func (m *MockReminderService) UpdateReminderStatus(id uint, status string, lastError string) error {
+	return m.UpdateReminderStatusFunc(id, status, lastError)
+}
+
+func (m *MockReminderService) SnoozeReminder(reminder *models.Reminder, until time.Time) error {
+	return m.SnoozeReminderFunc(reminder, until)
 }
 
 func (m *MockReminderService) DeleteReminder(id, creatorID string) error {
@@ -35,88 +63,552 @@ func (m *MockReminderService) UpdateReminder(id string, reminder *models.Reminde
 	return m.UpdateReminderFunc(id, reminder, creatorID)
 }
 
-// 测试创建提醒接口
+// newReminderTestServices 创建提醒接口依赖的用户资料和审计日志服务
+func newReminderTestServices(t *testing.T) (services.ProfileService, services.AuditService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserProfile{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	return services.NewProfileService(db), services.NewAuditService(db)
+}
+
+// withTestUser 模拟鉴权中间件注入登录用户
+func withTestUser(req *http.Request) *http.Request {
+	user := &models.User{CreatorID: "test_user", Mobile: "+8615014354723"}
+	return req.WithContext(controllers.WithCurrentUser(req.Context(), user))
+}
+
+// 测试创建提醒接口拒绝过去的时间
 func TestCreateReminder(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
 	service := &MockReminderService{
 		CreateReminderFunc: func(reminder *models.Reminder) error {
-			// 模拟成功的创建行为
+			t.Error("提醒时间无效时不应保存提醒")
 			return nil
 		},
 	}
 
 	// 创建一个 HTTP 请求
-	reminder := models.Reminder{Content: "测试提醒"}
-	body, _ := json.Marshal(reminder)
-	req := httptest.NewRequest(http.MethodPost, "/reminders", bytes.NewBuffer(body))
-	req.AddCookie(&http.Cookie{Name: "creator_id", Value: "test_user"})
+	body := []byte(`{"content": "测试提醒", "remind_at": "2020-01-01 08:00:00"}`)
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/reminders", bytes.NewBuffer(body)))
 
 	// 创建响应记录器
 	rr := httptest.NewRecorder()
 
 	// 调用 CreateReminder 控制器
-	controllers.CreateReminder(rr, req, service)
+	controllers.CreateReminder(rr, req, service, profileService, auditService)
 
 	// 验证响应状态码
-	if status := rr.Code; status != http.StatusCreated {
-		t.Errorf("期望状态码 %v，得到 %v", http.StatusCreated, status)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("期望状态码 %v，得到 %v", http.StatusBadRequest, status)
+	}
+}
+
+// 测试创建提醒时忽略请求体中由服务端维护的字段
+func TestCreateReminderIgnoresManagedFields(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	created := false
+	service := &MockReminderService{
+		CreateReminderFunc: func(reminder *models.Reminder) error {
+			created = true
+			if reminder.ID != 0 || reminder.CreatorID != "test_user" || reminder.Version != 1 || reminder.Status != services.ReminderScheduled {
+				t.Errorf("不应允许指定提醒的 ID、归属、版本和投递状态: %+v", reminder)
+			}
+			if reminder.SnoozeCount != 0 || reminder.SnoozedUntil != nil || reminder.CreatedAt.Year() == 2020 {
+				t.Errorf("不应允许指定提醒的推迟记录和创建时间: %+v", reminder)
+			}
+			return nil
+		},
+		UpdateReminderStatusFunc: func(id uint, status string, lastError string) error {
+			return nil
+		},
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":            42,
+		"creator_id":    "other_user",
+		"content":       "测试提醒",
+		"remind_at":     time.Now().Add(time.Hour).Format(time.RFC3339),
+		"version":       7,
+		"status":        "sent",
+		"snooze_count":  9,
+		"snoozed_until": "2030-01-01 08:00:00",
+		"created_at":    "2020-01-01 08:00:00",
+	})
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/reminders", bytes.NewBuffer(body)))
+	rr := httptest.NewRecorder()
+
+	controllers.CreateReminder(rr, req, service, profileService, auditService)
+
+	if !created {
+		t.Fatalf("提醒没有被创建: %s", rr.Body.String())
+	}
+}
+
+// 测试创建提醒时的时区：不带偏移的时间按用户时区解释，带 Z 或偏移的时间保持不变
+func TestCreateReminderTimezone(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	timezone := "Asia/Shanghai"
+	if _, err := profileService.UpdateProfile("test_user", &services.ProfileUpdate{Timezone: &timezone}); err != nil {
+		t.Fatalf("设置用户时区失败: %v", err)
+	}
+	shanghai, _ := time.LoadLocation(timezone)
+	local := time.Now().Add(48 * time.Hour).In(shanghai).Truncate(time.Second)
+	utc := local.UTC()
+
+	tests := []struct {
+		name     string
+		remindAt string
+		want     time.Time
+	}{
+		{"不带偏移", local.Format("2006-01-02 15:04:05"), local},
+		{"Z 结尾", utc.Format("2006-01-02T15:04:05Z"), utc},
+		{"+00:00 偏移", utc.Format("2006-01-02T15:04:05") + "+00:00", utc},
+		{"+08:00 偏移", local.Format(time.RFC3339), local},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved time.Time
+			service := &MockReminderService{
+				CreateReminderFunc: func(reminder *models.Reminder) error {
+					saved = reminder.RemindAt.Time
+					return nil
+				},
+				UpdateReminderStatusFunc: func(id uint, status string, lastError string) error {
+					return nil
+				},
+			}
+			body, _ := json.Marshal(map[string]string{"content": "测试提醒", "remind_at": tt.remindAt})
+			req := withTestUser(httptest.NewRequest(http.MethodPost, "/reminders", bytes.NewBuffer(body)))
+			rr := httptest.NewRecorder()
+
+			controllers.CreateReminder(rr, req, service, profileService, auditService)
+
+			if !saved.Equal(tt.want) {
+				t.Errorf("期望提醒时间 %v，得到 %v（%s）", tt.want, saved, rr.Body.String())
+			}
+		})
+	}
+}
+
+// 测试创建提醒接口拒绝格式错误或已经结束的重复规则、写在规则中的 EXDATE、单次提醒的排除日期和过早的起始时间
+func TestCreateReminderInvalidRRule(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	service := &MockReminderService{
+		CreateReminderFunc: func(reminder *models.Reminder) error {
+			t.Error("重复规则无效时不应保存提醒")
+			return nil
+		},
+	}
+
+	for _, tt := range []struct{ remindAt, rule, exdate string }{
+		{"2020-01-01 08:00:00", "FREQ=HOURLY", ""},
+		{"2020-01-01 08:00:00", "FREQ=DAILY;COUNT=0", ""},
+		{"2020-01-01 08:00:00", "FREQ=DAILY;UNTIL=20200201", ""},
+		{"2020-01-01 08:00:00", "FREQ=DAILY;EXDATE=20200102", ""},
+		{"2020-01-01 08:00:00", "FREQ=DAILY", "2020-01-02"},
+		{"2999-01-01 08:00:00", "", "29990102"},
+		{"1990-01-01 08:00:00", "FREQ=DAILY", ""},
+	} {
+		body, _ := json.Marshal(map[string]string{"content": "测试提醒", "remind_at": tt.remindAt, "rrule": tt.rule, "exdate": tt.exdate})
+		req := withTestUser(httptest.NewRequest(http.MethodPost, "/reminders", bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+
+		controllers.CreateReminder(rr, req, service, profileService, auditService)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("提醒 %+v 期望状态码 %v，得到 %v", tt, http.StatusBadRequest, status)
+		}
 	}
 }
 
 // 测试获取提醒接口
 func TestGetReminders(t *testing.T) {
+	profileService, _ := newReminderTestServices(t)
 	service := &MockReminderService{
-		GetRemindersByCreatorIDFunc: func(creatorID string) ([]models.Reminder, error) {
-			return []models.Reminder{{CreatorID: creatorID, Content: "测试提醒"}}, nil
+		ListRemindersFunc: func(creatorID string, query services.ReminderQuery) (*services.ReminderPage, error) {
+			if len(query.Statuses) != 0 || query.Cursor != "" || query.Sort != "" {
+				t.Errorf("没有传入查询参数时不应筛选: %+v", query)
+			}
+			return &services.ReminderPage{Items: []models.Reminder{{CreatorID: creatorID, Content: "测试提醒"}}, Total: 1}, nil
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/reminders", nil)
-	req.AddCookie(&http.Cookie{Name: "creator_id", Value: "test_user"})
+	req := withTestUser(httptest.NewRequest(http.MethodGet, "/reminders", nil))
 	rr := httptest.NewRecorder()
 
-	controllers.GetReminders(rr, req, service)
+	controllers.GetReminders(rr, req, service, profileService)
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("期望状态码 %v，得到 %v", http.StatusOK, status)
 	}
+	var resp struct {
+		Data services.ReminderPage `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Data.Items) != 1 || resp.Data.Items[0].CreatorID != "test_user" || resp.Data.Total != 1 {
+		t.Errorf("返回的提醒列表不正确: %s", rr.Body.String())
+	}
+}
+
+// 测试提醒列表的查询参数
+func TestGetRemindersQuery(t *testing.T) {
+	profileService, _ := newReminderTestServices(t)
+	var got services.ReminderQuery
+	service := &MockReminderService{
+		ListRemindersFunc: func(creatorID string, query services.ReminderQuery) (*services.ReminderPage, error) {
+			got = query
+			return &services.ReminderPage{Items: []models.Reminder{}}, nil
+		},
+	}
+
+	req := withTestUser(httptest.NewRequest(http.MethodGet, "/reminders?status=sent,failed&status=cancelled&tag=work,home&q=%E4%BC%9A%E8%AE%AE&sort=-remind_at&limit=5&from=2024-10-01+00:00:00&cursor=abc", nil))
+	rr := httptest.NewRecorder()
+	controllers.GetReminders(rr, req, service, profileService)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("期望状态码 %v，得到 %v: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if len(got.Statuses) != 3 || got.Statuses[0] != "sent" || got.Statuses[2] != "cancelled" {
+		t.Errorf("状态筛选不正确: %v", got.Statuses)
+	}
+	if len(got.Tags) != 2 || got.Keyword != "会议" || got.Sort != "-remind_at" || got.Limit != 5 || got.Cursor != "abc" {
+		t.Errorf("查询参数不正确: %+v", got)
+	}
+	// 默认时区 Asia/Shanghai
+	if want := "2024-09-30T16:00:00Z"; got.From.UTC().Format(time.RFC3339) != want {
+		t.Errorf("from 应按用户时区解释，期望 %s，得到 %v", want, got.From.UTC())
+	}
+
+	for _, query := range []string{"status=unknown", "sort=content", "limit=0", "from=yesterday"} {
+		req = withTestUser(httptest.NewRequest(http.MethodGet, "/reminders?"+query, nil))
+		rr = httptest.NewRecorder()
+		controllers.GetReminders(rr, req, service, profileService)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: 期望状态码 %v，得到 %v", query, http.StatusBadRequest, rr.Code)
+		}
+	}
 }
 
 // 测试删除提醒接口
 func TestDeleteReminder(t *testing.T) {
+	_, auditService := newReminderTestServices(t)
 	service := &MockReminderService{
 		DeleteReminderFunc: func(id, creatorID string) error {
+			if id != "1" || creatorID != "test_user" {
+				t.Errorf("删除了错误的提醒: id=%s, creatorID=%s", id, creatorID)
+			}
 			return nil // 模拟成功删除
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodDelete, "/reminders?id=1", nil)
-	req.AddCookie(&http.Cookie{Name: "creator_id", Value: "test_user"})
+	req := withTestUser(httptest.NewRequest(http.MethodDelete, "/reminders/1", nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 	rr := httptest.NewRecorder()
 
-	controllers.DeleteReminder(rr, req, service)
+	controllers.DeleteReminder(rr, req, service, auditService)
 
-	if status := rr.Code; status != http.StatusNoContent {
-		t.Errorf("期望状态码 %v，得到 %v", http.StatusNoContent, status)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("期望状态码 %v，得到 %v", http.StatusOK, status)
 	}
 }
 
-// 测试更新提醒接口
-func TestUpdateReminder(t *testing.T) {
+// 测试删除不存在或属于其他用户的提醒时返回 404
+func TestDeleteReminderNotFound(t *testing.T) {
+	_, auditService := newReminderTestServices(t)
 	service := &MockReminderService{
-		UpdateReminderFunc: func(id string, reminder *models.Reminder, creatorID string) error {
-			return nil // 模拟成功更新
+		DeleteReminderFunc: func(id, creatorID string) error {
+			return services.ErrReminderNotFound
 		},
 	}
 
-	reminder := models.Reminder{Content: "更新的测试提醒"}
-	body, _ := json.Marshal(reminder)
-	req := httptest.NewRequest(http.MethodPut, "/reminders?id=1", bytes.NewBuffer(body))
-	req.AddCookie(&http.Cookie{Name: "creator_id", Value: "test_user"})
+	req := withTestUser(httptest.NewRequest(http.MethodDelete, "/reminders/2", nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
 	rr := httptest.NewRecorder()
 
-	controllers.UpdateReminder(rr, req, service)
+	controllers.DeleteReminder(rr, req, service, auditService)
 
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("期望状态码 %v，得到 %v", http.StatusOK, status)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("期望状态码 %v，得到 %v", http.StatusNotFound, status)
+	}
+}
+
+// 测试获取单个提醒：只能获取自己的提醒，其他用户的和不存在的都返回 404
+func TestGetReminder(t *testing.T) {
+	profileService, _ := newReminderTestServices(t)
+	service := newUpdateTestService(t, models.Reminder{
+		ID:        1,
+		CreatorID: "test_user",
+		Content:   "测试提醒",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour)},
+		Version:   1,
+		Status:    services.ReminderScheduled,
+		Tags:      []string{"工作"},
+	})
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"自己的提醒", "1", http.StatusOK},
+		{"不存在的提醒", "2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withTestUser(httptest.NewRequest(http.MethodGet, "/reminders/"+tt.id, nil))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+
+			controllers.GetReminder(rr, req, service, profileService)
+
+			if rr.Code != tt.status {
+				t.Errorf("期望状态码 %v，得到 %v: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.status == http.StatusOK && (!strings.Contains(rr.Body.String(), "测试提醒") || !strings.Contains(rr.Body.String(), "工作")) {
+				t.Errorf("响应缺少提醒内容或标签: %s", rr.Body.String())
+			}
+		})
+	}
+
+	// 其他用户的提醒同样返回 404
+	foreign := newUpdateTestService(t, models.Reminder{ID: 1, CreatorID: "other_user", Version: 1})
+	req := withTestUser(httptest.NewRequest(http.MethodGet, "/reminders/1", nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	controllers.GetReminder(rr, req, foreign, profileService)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("期望状态码 %v，得到 %v", http.StatusNotFound, rr.Code)
+	}
+}
+
+// newUpdateTestService 创建更新接口使用的模拟服务，ID 为 1 的提醒属于测试用户
+func newUpdateTestService(t *testing.T, existing models.Reminder) *MockReminderService {
+	return &MockReminderService{
+		GetReminderFunc: func(id string, creatorID string) (*models.Reminder, error) {
+			if id != strconv.FormatUint(uint64(existing.ID), 10) || creatorID != existing.CreatorID {
+				return nil, services.ErrReminderNotFound
+			}
+			reminder := existing
+			return &reminder, nil
+		},
+		UpdateReminderStatusFunc: func(id uint, status string, lastError string) error {
+			return nil
+		},
+	}
+}
+
+// updateReminderRequest 以测试用户的身份更新 ID 为 id 的提醒
+func updateReminderRequest(service *MockReminderService, profileService services.ProfileService, auditService services.AuditService, id string, body string) *httptest.ResponseRecorder {
+	req := withTestUser(httptest.NewRequest(http.MethodPut, "/reminders/"+id, bytes.NewBufferString(body)))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	controllers.UpdateReminder(rr, req, service, profileService, auditService)
+	return rr
+}
+
+// 测试更新提醒接口：不能修改归属和投递状态，更新后版本号加一并重新发布到队列
+func TestUpdateReminder(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	existing := models.Reminder{
+		ID:        1,
+		CreatorID: "test_user",
+		Content:   "测试提醒",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour)},
+		Version:   3,
+		Status:    services.ReminderScheduled,
+	}
+	service := newUpdateTestService(t, existing)
+	updated := false
+	service.UpdateReminderFunc = func(id string, reminder *models.Reminder, creatorID string) error {
+		updated = true
+		if reminder.CreatorID != "test_user" {
+			t.Errorf("不应允许修改提醒的归属: %s", reminder.CreatorID)
+		}
+		if reminder.Status != services.ReminderScheduled || reminder.Attempts != 0 {
+			t.Errorf("不应允许修改提醒的投递状态: %+v", reminder)
+		}
+		if reminder.SnoozeCount != 0 || reminder.SnoozedUntil != nil || !reminder.CreatedAt.IsZero() {
+			t.Errorf("不应允许修改提醒的推迟记录和创建时间: %+v", reminder)
+		}
+		if reminder.Version != existing.Version+1 {
+			t.Errorf("更新后版本号应为 %d，得到 %d", existing.Version+1, reminder.Version)
+		}
+		return nil // 模拟成功更新
+	}
+
+	rr := updateReminderRequest(service, profileService, auditService, "1", `{"content": "更新的测试提醒", "creator_id": "other_user", "status": "sent", "attempts": 3, "snooze_count": 9, "snoozed_until": "2030-01-01 08:00:00", "created_at": "2020-01-01 08:00:00"}`)
+
+	if !updated {
+		t.Fatalf("提醒没有被更新: %s", rr.Body.String())
+	}
+	// 测试环境没有连接 RabbitMQ，更新成功但无法重新发布到队列
+	if status := rr.Code; status != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "提醒更新成功，但短信提醒无法发送") {
+		t.Errorf("期望状态码 %v，得到 %v: %s", http.StatusInternalServerError, status, rr.Body.String())
+	}
+}
+
+// 测试更新重复提醒：不传 rrule 时保持不变，传空字符串时改为单次提醒
+func TestUpdateReminderRRule(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	existing := models.Reminder{
+		ID:        1,
+		CreatorID: "test_user",
+		Content:   "每日提醒",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour)},
+		RRule:     "FREQ=DAILY",
+		ExDate:    "20990101",
+		Version:   1,
+		Status:    services.ReminderScheduled,
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		rrule  string
+		exdate string
+	}{
+		{"不传 rrule", `{"content": "新的每日提醒"}`, "FREQ=DAILY", "20990101"},
+		{"清空 rrule", `{"rrule": ""}`, "", ""},
+		{"修改 rrule", `{"rrule": "FREQ=WEEKLY"}`, "FREQ=WEEKLY", "20990101"},
+		{"修改 exdate", `{"exdate": "20990102"}`, "FREQ=DAILY", "20990102"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newUpdateTestService(t, existing)
+			var saved *models.Reminder
+			service.UpdateReminderFunc = func(id string, reminder *models.Reminder, creatorID string) error {
+				saved = reminder
+				return nil
+			}
+
+			rr := updateReminderRequest(service, profileService, auditService, "1", tt.body)
+
+			if saved == nil {
+				t.Fatalf("提醒没有被更新: %s", rr.Body.String())
+			}
+			if saved.RRule != tt.rrule {
+				t.Errorf("期望重复规则 %q，得到 %q", tt.rrule, saved.RRule)
+			}
+			if saved.ExDate != tt.exdate {
+				t.Errorf("期望排除日期 %q，得到 %q", tt.exdate, saved.ExDate)
+			}
+			if saved.Content == "" || saved.RemindAt.IsZero() {
+				t.Errorf("未修改的字段应保留原值: %+v", saved)
+			}
+		})
+	}
+}
+
+// 测试更新提醒接口拒绝其他用户的提醒、已发送的提醒和过去的时间
+func TestUpdateReminderRejected(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	future := models.JSONTime{Time: time.Now().Add(time.Hour)}
+
+	cases := []struct {
+		name     string
+		existing models.Reminder
+		id       string
+		body     string
+		want     int
+	}{
+		{"其他用户的提醒", models.Reminder{ID: 1, CreatorID: "other_user", RemindAt: future, Version: 1}, "1", `{"content": "x"}`, http.StatusNotFound},
+		{"不存在的提醒", models.Reminder{ID: 1, CreatorID: "test_user", RemindAt: future, Version: 1}, "2", `{"content": "x"}`, http.StatusNotFound},
+		{"已发送的提醒", models.Reminder{ID: 1, CreatorID: "test_user", RemindAt: future, Version: 1, Status: services.ReminderSent}, "1", `{"content": "x"}`, http.StatusConflict},
+		{"过去的时间", models.Reminder{ID: 1, CreatorID: "test_user", RemindAt: future, Version: 1}, "1", `{"remind_at": "2020-01-01 08:00:00"}`, http.StatusBadRequest},
+		{"无效的重复规则", models.Reminder{ID: 1, CreatorID: "test_user", RemindAt: future, Version: 1}, "1", `{"rrule": "FREQ=HOURLY"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		service := newUpdateTestService(t, c.existing)
+		service.UpdateReminderFunc = func(id string, reminder *models.Reminder, creatorID string) error {
+			t.Errorf("%s: 不应更新提醒", c.name)
+			return nil
+		}
+
+		rr := updateReminderRequest(service, profileService, auditService, c.id, c.body)
+		if rr.Code != c.want {
+			t.Errorf("%s: 期望状态码 %v，得到 %v", c.name, c.want, rr.Code)
+		}
+	}
+}
+
+// 测试并发更新时返回 409
+func TestUpdateReminderConflict(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	service := newUpdateTestService(t, models.Reminder{ID: 1, CreatorID: "test_user", RemindAt: models.JSONTime{Time: time.Now().Add(time.Hour)}, Version: 1})
+	service.UpdateReminderFunc = func(id string, reminder *models.Reminder, creatorID string) error {
+		return services.ErrReminderConflict
+	}
+
+	rr := updateReminderRequest(service, profileService, auditService, "1", `{"content": "x"}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("期望状态码 %v，得到 %v", http.StatusConflict, rr.Code)
+	}
+}
+
+// snoozeReminderRequest 以测试用户的身份推迟 ID 为 id 的提醒
+func snoozeReminderRequest(service *MockReminderService, profileService services.ProfileService, auditService services.AuditService, id string, body string) *httptest.ResponseRecorder {
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/reminders/"+id+"/snooze", bytes.NewBufferString(body)))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	controllers.SnoozeReminder(rr, req, service, profileService, auditService)
+	return rr
+}
+
+// 测试推迟提醒接口按时长推迟并记录推迟次数
+func TestSnoozeReminder(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	service := newUpdateTestService(t, models.Reminder{ID: 1, CreatorID: "test_user", Content: "测试提醒", Version: 1, Status: services.ReminderSent})
+	var snoozedUntil time.Time
+	service.SnoozeReminderFunc = func(reminder *models.Reminder, until time.Time) error {
+		snoozedUntil = until
+		reminder.SnoozeCount++
+		reminder.SnoozedUntil = &models.JSONTime{Time: until}
+		return nil
+	}
+
+	before := time.Now()
+	rr := snoozeReminderRequest(service, profileService, auditService, "1", `{"duration": "10m"}`)
+
+	if snoozedUntil.Sub(before) < 10*time.Minute || snoozedUntil.Sub(before) > 11*time.Minute {
+		t.Fatalf("推迟到的时间不正确: %v: %s", snoozedUntil, rr.Body.String())
+	}
+	// 测试环境没有连接 RabbitMQ，推迟成功但无法发布到队列
+	if status := rr.Code; status != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "提醒已推迟，但短信提醒无法发送") {
+		t.Errorf("期望状态码 %v，得到 %v: %s", http.StatusInternalServerError, status, rr.Body.String())
+	}
+}
+
+// 测试推迟提醒接口的参数校验
+func TestSnoozeReminderRejected(t *testing.T) {
+	profileService, auditService := newReminderTestServices(t)
+	existing := models.Reminder{ID: 1, CreatorID: "test_user", Content: "测试提醒", Version: 1}
+
+	cases := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{"不存在的提醒", "2", `{"duration": "10m"}`, http.StatusNotFound},
+		{"缺少参数", "1", `{}`, http.StatusBadRequest},
+		{"同时传两个参数", "1", `{"duration": "10m", "until": "2099-01-01 08:00:00"}`, http.StatusBadRequest},
+		{"无效的时长", "1", `{"duration": "ten minutes"}`, http.StatusBadRequest},
+		{"负数时长", "1", `{"duration": "-10m"}`, http.StatusBadRequest},
+		{"过去的时间", "1", `{"until": "2020-01-01 08:00:00"}`, http.StatusBadRequest},
+		{"推迟太久", "1", `{"duration": "800h"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		service := newUpdateTestService(t, existing)
+		service.SnoozeReminderFunc = func(reminder *models.Reminder, until time.Time) error {
+			t.Errorf("%s: 不应推迟提醒", c.name)
+			return nil
+		}
+
+		rr := snoozeReminderRequest(service, profileService, auditService, c.id, c.body)
+		if rr.Code != c.want {
+			t.Errorf("%s: 期望状态码 %v，得到 %v", c.name, c.want, rr.Code)
+		}
 	}
 }
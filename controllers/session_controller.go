@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 获取当前用户的登录会话列表
+func GetSessions(w http.ResponseWriter, r *http.Request, sessionService services.SessionService) {
+	current, err := GetCurrentSession(r)
+	if err != nil {
+		log.Printf("获取当前会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	sessions, err := sessionService.ListSessions(current.CreatorID)
+	if err != nil {
+		log.Printf("获取会话列表失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "获取会话列表失败")
+		return
+	}
+
+	// 标记发起本次请求的会话
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.ID
+	}
+
+	utils.SuccessResponse(w, sessions, "获取会话列表成功")
+}
+
+// 注销指定会话，例如将丢失的手机下线
+func DeleteSession(w http.ResponseWriter, r *http.Request, sessionService services.SessionService) {
+	current, err := GetCurrentSession(r)
+	if err != nil {
+		log.Printf("获取当前会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	sessionID := mux.Vars(r)["id"]
+	if sessionID == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "会话ID不存在")
+		return
+	}
+
+	err = sessionService.RevokeSession(current.CreatorID, sessionID)
+	if err == services.ErrSessionNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "会话不存在")
+		return
+	}
+	if err != nil {
+		log.Printf("注销会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "注销会话失败")
+		return
+	}
+
+	// 注销的是当前会话时，同时清除 cookies
+	if sessionID == current.ID {
+		setCookie(w, "token", "", -time.Hour)
+		setCookie(w, "creator_id", "", -time.Hour)
+	}
+
+	log.Printf("会话已注销, 用户ID: %s, 会话ID: %s", current.CreatorID, sessionID)
+	utils.SuccessResponse(w, nil, "会话已注销")
+}
+
+// 在所有设备上退出登录
+func DeleteAllSessions(w http.ResponseWriter, r *http.Request, sessionService services.SessionService) {
+	current, err := GetCurrentSession(r)
+	if err != nil {
+		log.Printf("获取当前会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	if err := sessionService.RevokeAllSessions(current.CreatorID); err != nil {
+		log.Printf("注销全部会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "注销全部会话失败")
+		return
+	}
+
+	setCookie(w, "token", "", -time.Hour)
+	setCookie(w, "creator_id", "", -time.Hour)
+
+	log.Printf("已在所有设备上退出登录, 用户ID: %s", current.CreatorID)
+	utils.SuccessResponse(w, nil, "已在所有设备上退出登录")
+}
+
+// 刷新当前会话，把过期时间顺延为 24 小时之后；使用 Cookie 的客户端同时更新 Cookie 的过期时间
+func RefreshSession(w http.ResponseWriter, r *http.Request, sessionService services.SessionService) {
+	current, err := GetCurrentSession(r)
+	if err != nil {
+		log.Printf("获取当前会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	session, err := sessionService.RefreshSession(current.CreatorID, current.ID)
+	if err == services.ErrSessionNotFound {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "登录已失效，请重新登录")
+		return
+	}
+	if err != nil {
+		log.Printf("刷新会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "刷新会话失败")
+		return
+	}
+
+	token, fromCookie := getSessionToken(r)
+	if fromCookie {
+		setSessionCookies(w, token, session)
+	}
+
+	log.Printf("会话已刷新, 用户ID: %s, 会话ID: %s, 过期时间: %v", session.CreatorID, session.ID, session.ExpiresAt.Time)
+	utils.SuccessResponse(w, newSessionToken(token, session), "会话已刷新")
+}
@@ -0,0 +1,136 @@
+package controllers_test
+
+import (
+	"calendarReminder-service/controllers"
+	"calendarReminder-service/models"
+	"calendarReminder-service/routes"
+	"calendarReminder-service/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试 App 和命令行客户端通过 Authorization: Bearer 携带会话 token 访问，并刷新会话
+func TestBearerSessionRefresh(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	session, token, err := env.sessionService.CreateSession(user.CreatorID, "命令行", "127.0.0.1", "cli")
+	assert.NoError(t, err)
+
+	auth := controllers.AuthMiddleware(env.userService, env.sessionService, nil)
+	handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controllers.RefreshSession(w, r, env.sessionService)
+	}))
+
+	// 没有 token 时拒绝访问
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/sessions/refresh", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	time.Sleep(1100 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, "/sessions/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Result().Cookies(), "使用 Bearer 的客户端不需要写入 Cookie")
+
+	var resp struct {
+		Data struct {
+			Token     string `json:"token"`
+			ExpiresIn int    `json:"expires_in"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, token, resp.Data.Token)
+	assert.InDelta(t, services.SessionTTL.Seconds(), resp.Data.ExpiresIn, 5)
+
+	sessions, err := env.sessionService.ListSessions(user.CreatorID)
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.True(t, sessions[0].ExpiresAt.After(session.ExpiresAt.Time), "刷新后过期时间应顺延")
+	}
+
+	// 使用 Cookie 的客户端刷新后同时更新 Cookie
+	req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	if tokenCookie := responseCookie(rr, "token"); assert.NotNil(t, tokenCookie) {
+		assert.Equal(t, token, tokenCookie.Value)
+	}
+
+	// 无效的 Bearer token 拒绝访问
+	req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// 测试多设备会话管理：查询会话列表、注销其他设备的会话、拒绝注销其他用户的会话
+func TestSessionManagement(t *testing.T) {
+	env := newPassportTestEnv(t)
+	user, err := env.userService.CreateUser("+8615014354723")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	other, err := env.userService.CreateUser("+8615014354724")
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	current, token, err := env.sessionService.CreateSession(user.CreatorID, "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	laptop, laptopToken, err := env.sessionService.CreateSession(user.CreatorID, "电脑", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	foreign, foreignToken, err := env.sessionService.CreateSession(other.CreatorID, "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	router := mux.NewRouter()
+	routes.SessionRoutes(router, controllers.AuthMiddleware(env.userService, env.sessionService, nil), env.sessionService)
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// 会话列表只包含自己的会话，并标记当前会话
+	rr := request(http.MethodGet, "/sessions")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Data []models.Session `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 2) {
+		for _, session := range resp.Data {
+			assert.Equal(t, user.CreatorID, session.CreatorID)
+			assert.Equal(t, session.ID == current.ID, session.Current)
+		}
+	}
+
+	// 其他用户的会话返回 404 且不受影响
+	rr = request(http.MethodDelete, "/sessions/"+foreign.ID)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	session, _ := env.sessionService.ValidateToken(foreignToken)
+	assert.NotNil(t, session, "其他用户的会话不应被注销")
+
+	// 注销另一台设备，当前会话不受影响
+	rr = request(http.MethodDelete, "/sessions/"+laptop.ID)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, responseCookie(rr, "token"), "注销其他设备时不清除当前 Cookie")
+	session, _ = env.sessionService.ValidateToken(laptopToken)
+	assert.Nil(t, session, "被注销的会话应失效")
+	session, _ = env.sessionService.ValidateToken(token)
+	assert.NotNil(t, session)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"crypto/subtle"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 存储验证码错误次数的Key前缀
+const MOBILE_SMSCODE_FAIL = "MOBILE_SMSCODE_FAIL:"
+
+// 存储验证码锁定状态的Key前缀
+const MOBILE_SMSCODE_LOCK = "MOBILE_SMSCODE_LOCK:"
+
+// 验证码相关的默认配置，可在 config.yaml 的 sms 节点下覆盖
+const (
+	defaultSMSCodeTTL     = 30 * time.Minute
+	defaultSMSMaxAttempts = 5
+	defaultSMSCodeLockout = 15 * time.Minute
+	smsCodeDigits         = 6
+)
+
+// 验证码校验结果
+type smsCodeResult int
+
+const (
+	smsCodeValid   smsCodeResult = iota // 验证码正确
+	smsCodeInvalid                      // 验证码错误或已过期
+	smsCodeLocked                       // 错误次数过多，已锁定
+)
+
+func smsCodeTTL() time.Duration {
+	if ttl := viper.GetDuration("sms.code-ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultSMSCodeTTL
+}
+
+func smsMaxAttempts() int64 {
+	if n := viper.GetInt64("sms.max-attempts"); n > 0 {
+		return n
+	}
+	return defaultSMSMaxAttempts
+}
+
+func smsCodeLockout() time.Duration {
+	if d := viper.GetDuration("sms.lockout"); d > 0 {
+		return d
+	}
+	return defaultSMSCodeLockout
+}
+
+// smsCodeLockRemaining 返回手机号因验证码错误次数过多而被锁定的剩余时间，未锁定时返回 0
+func smsCodeLockRemaining(codeStore store.CodeStore, mobile string) (time.Duration, error) {
+	ttl, err := codeStore.TTL(MOBILE_SMSCODE_LOCK + mobile)
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		// 没有过期时间，按完整锁定时长处理
+		return smsCodeLockout(), nil
+	}
+	return ttl, nil
+}
+
+// issueSMSCode 生成新的验证码并只保存哈希，返回验证码明文用于发送短信
+func issueSMSCode(codeStore store.CodeStore, mobile string) (string, error) {
+	code, err := utils.GenerateNumericCode(smsCodeDigits)
+	if err != nil {
+		return "", err
+	}
+	if err := codeStore.Set(MOBILE_SMSCODE+mobile, utils.HashSMSCode(mobile, code), smsCodeTTL()); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// verifySMSCode 校验验证码，错误次数达到上限后作废验证码并锁定手机号
+func verifySMSCode(codeStore store.CodeStore, mobile, code string) (smsCodeResult, error) {
+	remaining, err := smsCodeLockRemaining(codeStore, mobile)
+	if err != nil {
+		return smsCodeInvalid, err
+	}
+	if remaining > 0 {
+		return smsCodeLocked, nil
+	}
+
+	// 验证码正确时原子地删除，验证码只能使用一次，同一验证码的并发请求只有一个能通过
+	matched, err := codeStore.DeleteIfEqual(MOBILE_SMSCODE+mobile, utils.HashSMSCode(mobile, code))
+	if err != nil {
+		return smsCodeInvalid, err
+	}
+	if matched {
+		if err := codeStore.Delete(MOBILE_SMSCODE_FAIL + mobile); err != nil {
+			return smsCodeInvalid, err
+		}
+		return smsCodeValid, nil
+	}
+
+	// 记录错误次数，第一次出错时设置过期时间
+	failKey := MOBILE_SMSCODE_FAIL + mobile
+	failures, err := codeStore.Incr(failKey, smsCodeTTL())
+	if err != nil {
+		return smsCodeInvalid, err
+	}
+	if failures >= smsMaxAttempts() {
+		// 先锁定一段时间，再作废当前验证码
+		if err := codeStore.Set(MOBILE_SMSCODE_LOCK+mobile, "1", smsCodeLockout()); err != nil {
+			return smsCodeInvalid, err
+		}
+		if err := codeStore.Delete(MOBILE_SMSCODE+mobile, failKey); err != nil {
+			return smsCodeInvalid, err
+		}
+		return smsCodeLocked, nil
+	}
+	return smsCodeInvalid, nil
+}
+
+// peekSMSCode 只检查验证码是否正确，不作废验证码也不记录错误次数；
+// 需要同时校验多个验证码时先用它确认，全部正确后再用 verifySMSCode 作废
+func peekSMSCode(codeStore store.CodeStore, mobile, code string) (smsCodeResult, error) {
+	remaining, err := smsCodeLockRemaining(codeStore, mobile)
+	if err != nil {
+		return smsCodeInvalid, err
+	}
+	if remaining > 0 {
+		return smsCodeLocked, nil
+	}
+	stored, err := codeStore.Get(MOBILE_SMSCODE + mobile)
+	if err == store.ErrNotFound {
+		return smsCodeInvalid, nil
+	}
+	if err != nil {
+		return smsCodeInvalid, err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(utils.HashSMSCode(mobile, code))) != 1 {
+		return smsCodeInvalid, nil
+	}
+	return smsCodeValid, nil
+}
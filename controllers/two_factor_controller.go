@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"calendarReminder-service/oidc"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 存储两步验证登录凭证的Key前缀，凭证在短信验证码校验通过后签发
+const LOGIN_2FA_TICKET = "LOGIN_2FA_TICKET:"
+
+// 记录两步验证登录凭证动态验证码错误次数的Key前缀
+const LOGIN_2FA_FAIL = "LOGIN_2FA_FAIL:"
+
+// 记录用户当前有效的两步验证登录凭证的Key前缀，注销账号或更换手机号时据此作废凭证
+const LOGIN_2FA_USER = "LOGIN_2FA_USER:"
+
+const (
+	loginTicketTTL      = 5 * time.Minute // 短信验证通过后需要在该时间内完成两步验证
+	loginTicketMaxFails = 5               // 动态验证码错误达到该次数后凭证作废，需要重新获取短信验证码
+)
+
+// 记录关闭两步验证时动态验证码尝试次数的Key前缀，按用户计数
+const TOTP_DISABLE_FAIL = "TOTP_DISABLE_FAIL:"
+
+const (
+	totpDisableMaxFails = 5                // 关闭两步验证时动态验证码最多允许输错的次数
+	totpDisableLockout  = 15 * time.Minute // 输错次数超限后的锁定时长，从第一次尝试开始计算
+)
+
+// 第三方登录跳转到前端时保存两步验证登录凭证的 Cookie 名称
+const loginTicketCookie = "login_ticket"
+
+// loginTicket 两步验证登录凭证对应的待登录用户
+type loginTicket struct {
+	CreatorID string `json:"creator_id"`
+	Device    string `json:"device"`
+}
+
+// twoFactorChallenge 需要两步验证时登录接口返回的数据
+type twoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	LoginTicket       string `json:"login_ticket"`
+	ExpiresIn         int    `json:"expires_in"` // 凭证有效期，单位秒
+}
+
+// issueLoginTicket 签发一次性的两步验证登录凭证，每个用户只保留最新签发的凭证
+func issueLoginTicket(codeStore store.CodeStore, creatorID, device string) (*twoFactorChallenge, error) {
+	ticket, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	if err := revokeLoginTicket(codeStore, creatorID); err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(loginTicket{CreatorID: creatorID, Device: device})
+	if err := codeStore.Set(LOGIN_2FA_TICKET+ticket, string(data), loginTicketTTL); err != nil {
+		return nil, err
+	}
+	if err := codeStore.Set(LOGIN_2FA_USER+creatorID, ticket, loginTicketTTL); err != nil {
+		return nil, err
+	}
+	return &twoFactorChallenge{
+		TwoFactorRequired: true,
+		LoginTicket:       ticket,
+		ExpiresIn:         int(loginTicketTTL / time.Second),
+	}, nil
+}
+
+// revokeLoginTicket 作废用户尚未完成两步验证的登录凭证，没有凭证时不报错
+func revokeLoginTicket(codeStore store.CodeStore, creatorID string) error {
+	ticket, err := codeStore.Take(LOGIN_2FA_USER + creatorID)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return codeStore.Delete(LOGIN_2FA_TICKET+ticket, LOGIN_2FA_FAIL+ticket)
+}
+
+// clearLoginTicketCookie 登录凭证使用或作废后清除浏览器中保存凭证的 Cookie
+func clearLoginTicketCookie(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(loginTicketCookie); err == nil {
+		setCookie(w, loginTicketCookie, "", -time.Hour)
+	}
+}
+
+// 两步验证登录：提交登录凭证和动态验证码（或恢复码），校验通过后创建会话
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request, userService services.UserService, sessionService services.SessionService, totpService services.TOTPService, auditService services.AuditService, codeStore store.CodeStore) {
+	var reqBody struct {
+		LoginTicket string `json:"login_ticket"`
+		Code        string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return
+	}
+	// 请求体中没有登录凭证时使用第三方登录跳转时写入的 Cookie
+	if reqBody.LoginTicket == "" {
+		if cookie, err := r.Cookie(loginTicketCookie); err == nil {
+			reqBody.LoginTicket = cookie.Value
+		}
+	}
+	if reqBody.LoginTicket == "" || reqBody.Code == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "登录凭证和动态验证码不能为空")
+		return
+	}
+
+	data, err := codeStore.Get(LOGIN_2FA_TICKET + reqBody.LoginTicket)
+	if err == store.ErrNotFound {
+		log.Println("两步验证登录凭证无效或已过期")
+		clearLoginTicketCookie(w, r)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "登录凭证无效或已过期，请重新登录")
+		return
+	}
+	if err != nil {
+		log.Printf("读取两步验证登录凭证失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+	var ticket loginTicket
+	if err := json.Unmarshal([]byte(data), &ticket); err != nil {
+		log.Printf("解析两步验证登录凭证失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+
+	// 先计数再校验，并发请求也不能突破错误次数上限；凭证使用成功后计数随凭证一起删除
+	attempts, err := codeStore.Incr(LOGIN_2FA_FAIL+reqBody.LoginTicket, loginTicketTTL)
+	if err != nil {
+		log.Printf("记录动态验证码尝试次数失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+	if attempts > loginTicketMaxFails {
+		codeStore.Delete(LOGIN_2FA_TICKET+reqBody.LoginTicket, LOGIN_2FA_FAIL+reqBody.LoginTicket)
+		clearLoginTicketCookie(w, r)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "动态验证码错误次数过多，请重新登录")
+		return
+	}
+
+	ok, err := totpService.Verify(ticket.CreatorID, reqBody.Code)
+	if errors.Is(err, services.ErrTOTPNotEnrolled) {
+		// 签发凭证后两步验证被关闭或正在重新设置，不能跳过第二步验证，作废凭证并要求重新登录
+		codeStore.Delete(LOGIN_2FA_TICKET+reqBody.LoginTicket, LOGIN_2FA_FAIL+reqBody.LoginTicket)
+		clearLoginTicketCookie(w, r)
+		log.Printf("两步验证状态已变化，登录凭证已作废, 用户ID: %s", ticket.CreatorID)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "两步验证设置已变化，请重新登录")
+		return
+	}
+	if err != nil {
+		log.Printf("校验动态验证码失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+	if !ok {
+		if attempts >= loginTicketMaxFails {
+			// 错误次数过多，作废凭证，需要重新通过短信验证码登录
+			codeStore.Delete(LOGIN_2FA_TICKET+reqBody.LoginTicket, LOGIN_2FA_FAIL+reqBody.LoginTicket)
+			clearLoginTicketCookie(w, r)
+			log.Printf("动态验证码错误次数过多，登录凭证已作废, 用户ID: %s", ticket.CreatorID)
+		}
+		recordAudit(r, auditService, ticket.CreatorID, services.AuditTwoFactor, ticket.CreatorID, services.AuditFailure, "动态验证码错误")
+		utils.ErrorResponse(w, http.StatusUnauthorized, "动态验证码错误")
+		return
+	}
+
+	// 凭证只能使用一次，并发提交的正确验证码只有一个能取走凭证
+	if _, err := codeStore.Take(LOGIN_2FA_TICKET + reqBody.LoginTicket); err != nil {
+		if err != store.ErrNotFound {
+			log.Printf("读取两步验证登录凭证失败: %v", err)
+		}
+		utils.ErrorResponse(w, http.StatusUnauthorized, "登录凭证无效或已过期，请重新登录")
+		return
+	}
+	codeStore.Delete(LOGIN_2FA_FAIL + reqBody.LoginTicket)
+	clearLoginTicketCookie(w, r)
+
+	// 签发凭证后用户可能已关闭两步验证、被禁用或注销
+	user, err := userService.GetUserByCreatorID(ticket.CreatorID)
+	if err != nil || user == nil {
+		log.Printf("获取用户信息失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "登录凭证无效或已过期，请重新登录")
+		return
+	}
+	if user.Disabled {
+		log.Printf("用户已被禁用, 用户ID: %s", user.CreatorID)
+		recordAudit(r, auditService, user.CreatorID, services.AuditTwoFactor, user.CreatorID, services.AuditFailure, "账号已被禁用")
+		utils.ErrorResponse(w, http.StatusForbidden, "账号已被禁用")
+		return
+	}
+
+	loginToken, err := startSession(w, r, sessionService, user, ticket.Device)
+	if err != nil {
+		log.Printf("创建会话失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "登录失败")
+		return
+	}
+
+	log.Printf("两步验证通过，登录成功, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditLogin, user.Mobile, services.AuditSuccess, "两步验证")
+	utils.SuccessResponse(w, loginToken, "登录成功")
+}
+
+// 开始设置两步验证：生成密钥，返回供身份验证器 App 扫码添加的 otpauth 地址
+func EnrollTOTP(w http.ResponseWriter, r *http.Request, totpService services.TOTPService) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+
+	// 身份验证器中显示的账号名，没有手机号的第三方登录用户使用用户ID
+	account := user.Mobile
+	if account == "" {
+		account = user.CreatorID
+	}
+	secret, uri, err := totpService.Enroll(user.CreatorID, account)
+	if errors.Is(err, services.ErrTOTPAlreadyEnabled) {
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("生成两步验证密钥失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "设置两步验证失败")
+		return
+	}
+
+	log.Printf("开始设置两步验证, 用户ID: %s", user.CreatorID)
+	utils.SuccessResponse(w, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	}, "请使用身份验证器扫码，并提交动态验证码完成设置")
+}
+
+// 提交身份验证器中的动态验证码，确认开启两步验证，返回只展示一次的恢复码
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request, totpService services.TOTPService, auditService services.AuditService) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := totpService.Confirm(user.CreatorID, code)
+	switch {
+	case errors.Is(err, services.ErrTOTPInvalidCode):
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrTOTPNotEnrolled), errors.Is(err, services.ErrTOTPAlreadyEnabled):
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Printf("开启两步验证失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "开启两步验证失败")
+		return
+	}
+
+	log.Printf("两步验证已开启, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditTOTPEnable, user.CreatorID, services.AuditSuccess, "")
+	utils.SuccessResponse(w, map[string][]string{"recovery_codes": recoveryCodes}, "两步验证已开启，请妥善保存恢复码")
+}
+
+// 提交动态验证码或恢复码，关闭两步验证；按用户限制尝试次数，防止会话被盗后暴力猜测动态验证码
+func DisableTOTP(w http.ResponseWriter, r *http.Request, totpService services.TOTPService, auditService services.AuditService, codeStore store.CodeStore) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		log.Printf("获取登录用户失败: %v", err)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "未授权的请求")
+		return
+	}
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+
+	// 先计数再校验，并发请求也不能突破上限
+	failKey := TOTP_DISABLE_FAIL + user.CreatorID
+	attempts, err := codeStore.Incr(failKey, totpDisableLockout)
+	if err != nil {
+		log.Printf("记录动态验证码尝试次数失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "关闭两步验证失败")
+		return
+	}
+	if attempts > totpDisableMaxFails {
+		retryAfter, _ := codeStore.TTL(failKey)
+		if retryAfter <= 0 {
+			retryAfter = totpDisableLockout
+		}
+		recordAudit(r, auditService, user.CreatorID, services.AuditTOTPDisable, user.CreatorID, services.AuditFailure, "错误次数过多")
+		utils.TooManyRequestsResponse(w, retryAfter, utils.CodeTOTPLocked, "动态验证码错误次数过多，请稍后再试")
+		return
+	}
+
+	err = totpService.Disable(user.CreatorID, code)
+	if err == nil {
+		codeStore.Delete(failKey)
+	}
+	switch {
+	case errors.Is(err, services.ErrTOTPInvalidCode):
+		recordAudit(r, auditService, user.CreatorID, services.AuditTOTPDisable, user.CreatorID, services.AuditFailure, "动态验证码错误")
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrTOTPNotEnrolled):
+		utils.ErrorResponse(w, http.StatusConflict, "尚未开启两步验证")
+		return
+	case err != nil:
+		log.Printf("关闭两步验证失败: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "关闭两步验证失败")
+		return
+	}
+
+	log.Printf("两步验证已关闭, 用户ID: %s", user.CreatorID)
+	recordAudit(r, auditService, user.CreatorID, services.AuditTOTPDisable, user.CreatorID, services.AuditSuccess, "")
+	utils.SuccessResponse(w, nil, "两步验证已关闭")
+}
+
+// decodeTOTPCode 读取请求体中的动态验证码，失败时直接写入错误响应
+func decodeTOTPCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var reqBody struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("请求体解析失败: %v", err)
+		utils.ErrorResponse(w, http.StatusBadRequest, "请求体解析失败")
+		return "", false
+	}
+	if reqBody.Code == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "动态验证码不能为空")
+		return "", false
+	}
+	return reqBody.Code, true
+}
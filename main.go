@@ -2,10 +2,14 @@ package main
 
 import (
 	"calendarReminder-service/config"
+	"calendarReminder-service/controllers"
 	"calendarReminder-service/models"
+	"calendarReminder-service/oidc"
 	"calendarReminder-service/rabbitmq"
+	"calendarReminder-service/ratelimit"
 	"calendarReminder-service/routes"
 	"calendarReminder-service/services"
+	"calendarReminder-service/store"
 	"calendarReminder-service/utils"
 	"github.com/gorilla/mux"
 	"log"
@@ -25,9 +29,42 @@ func main() {
 		log.Fatalf("设置时区失败: %v", err)
 	}
 
-	// 初始化 Redis、MySQL 和 RabbitMQ
-	config.InitRedis()
+	// 设置可信代理，用于解析客户端真实 IP
+	if err := utils.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		log.Fatalf("可信代理配置错误: %v", err)
+	}
+
+	// 设置允许注册的手机号国家/地区
+	phoneConfig := config.LoadPhoneConfig()
+	if err := utils.SetPhoneCountries(phoneConfig.AllowedCountries, phoneConfig.DefaultCountry); err != nil {
+		log.Fatalf("手机号配置错误: %v", err)
+	}
+
+	// 设置登录 Cookie 的属性
+	if err := controllers.SetCookieConfig(config.LoadCookieConfig()); err != nil {
+		log.Fatalf("Cookie 配置错误: %v", err)
+	}
+
+	// 初始化 Redis、MySQL 和 RabbitMQ；单机模式下不连接 Redis
+	storeConfig := config.LoadStoreConfig()
+	if storeConfig.Backend == "memory" {
+		log.Println("单机模式，验证码和会话保存在进程内存中")
+	} else {
+		config.InitRedis()
+	}
 	config.InitMySQL()
+
+	// 设置短信验证码 HMAC 的服务端密钥；多实例共享 Redis 时必须配置同一个密钥
+	if secret := config.GetSMSCodeSecret(); secret != "" {
+		if err := utils.SetSMSCodeSecret(secret); err != nil {
+			log.Fatalf("验证码密钥配置错误: %v", err)
+		}
+	} else if storeConfig.Backend != "memory" {
+		log.Println("警告: 未配置 sms.code-secret（或环境变量 SMS_CODE_SECRET），使用随机生成的验证码密钥，只适用于单实例部署；" +
+			"多个实例共享 Redis 时无法校验其他实例发送的验证码，重启后已发送的验证码也会失效")
+	} else {
+		log.Println("未配置 sms.code-secret，使用随机生成的验证码密钥，重启后已发送的验证码失效")
+	}
 	// 初始化 RabbitMQ
 	config.InitRabbitMQ()
 
@@ -36,28 +73,81 @@ func main() {
 		log.Fatalf("RabbitMQ 初始化失败: %v", err)
 	}
 
-	// 启动消息消费
-	go func() {
-		if err := rabbitmq.ConsumeReminders(); err != nil {
-			log.Fatalf("消费消息失败: %v", err)
-		}
-	}()
-
 	// 自动迁移表结构
-	config.DB.AutoMigrate(&models.User{}, &models.Reminder{})
+	config.DB.AutoMigrate(&models.User{}, &models.Reminder{}, &models.ReminderTag{}, &models.ApiToken{}, &models.UserIdentity{}, &models.DeletionReceipt{}, &models.UserProfile{}, &models.DeliveryLog{}, &models.AuditLog{}, &models.UserTOTP{}, &models.RecoveryCode{})
 
 	// 初始化 ID 生成器和 UserService
 	idGen := &utils.SimpleIDGenerator{}
 	userService := services.NewUserService(config.DB, idGen)
+	// 旧版本按国内格式保存的手机号转换为 E.164 格式，否则老用户登录时会被当作新用户重新注册
+	migrated, err := userService.MigrateLegacyMobiles()
+	if err != nil {
+		log.Fatalf("手机号格式迁移失败: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("已将 %d 个用户的手机号转换为 E.164 格式", migrated)
+	}
+	// 按配置授予管理员，管理接口本身不提供授予管理员的入口
+	adminConfig := config.LoadAdminConfig()
+	granted, err := userService.GrantAdmins(adminConfig.Mobiles, adminConfig.CreatorIDs)
+	if err != nil {
+		log.Fatalf("授予管理员失败: %v", err)
+	}
+	if granted > 0 {
+		log.Printf("已将 %d 个用户设为管理员", granted)
+	}
 	reminderService := services.NewReminderService(config.DB)
+	codeStore := store.NewCodeStore(storeConfig.Backend, config.RedisClient)
+	sessionService := services.NewSessionService(store.NewSessionStore(storeConfig.Backend, config.RedisClient))
+	apiTokenService := services.NewApiTokenService(config.DB)
+	accountService := services.NewAccountService(config.DB)
+	profileService := services.NewProfileService(config.DB)
+	deliveryLogService := services.NewDeliveryLogService(config.DB)
+	auditService := services.NewAuditService(config.DB)
+	totpService := services.NewTOTPService(config.DB, config.LoadTOTPConfig().Issuer)
+
+	// 定期清理过期的审计日志
+	auditConfig := config.LoadAuditConfig()
+	services.StartAuditRetention(auditService, auditConfig.Retention, auditConfig.PurgeInterval)
+
+	// 启动消息消费
+	go func() {
+		if err := rabbitmq.ConsumeReminders(reminderService, userService, profileService, deliveryLogService); err != nil {
+			log.Fatalf("消费消息失败: %v", err)
+		}
+	}()
+
+	// 初始化限流器
+	rateLimitConfig := config.LoadRateLimitConfig()
+	limiter := ratelimit.NewRateLimiter(rateLimitConfig.Backend, config.RedisClient)
+	captchaService := services.NewCaptchaService(codeStore, limiter, config.LoadCaptchaConfig())
 
 	// 初始化路由
 	router := mux.NewRouter()
+	// 携带 Cookie 的写请求需要通过来源校验，防止跨站请求伪造
+	router.Use(controllers.CSRFMiddleware(config.LoadCSRFConfig()))
+
+	// 鉴权中间件，支持登录会话和 API Token
+	auth := controllers.AuthMiddleware(userService, sessionService, apiTokenService)
 
 	// 注册用户登录、登出和短信验证码的路由，传递router
-	routes.PassportRoutes(router, userService)
+	routes.PassportRoutes(router, auth, codeStore, userService, sessionService, captchaService, totpService, auditService, limiter, rateLimitConfig)
+	// 注册第三方（OIDC）登录的路由
+	if oidcConfig := config.LoadOIDCConfig(); oidcConfig.Enabled {
+		routes.OIDCRoutes(router, oidc.NewProvider(oidcConfig), codeStore, userService, sessionService, totpService, auditService, oidcConfig.PostLoginRedirect)
+	}
+	// 注册会话管理的路由
+	routes.SessionRoutes(router, auth, sessionService)
+	// 注册 API Token 管理的路由
+	routes.ApiTokenRoutes(router, auth, apiTokenService)
+	// 注册账号管理的路由
+	routes.AccountRoutes(router, auth, codeStore, userService, accountService, sessionService, auditService)
+	// 注册用户资料的路由
+	routes.ProfileRoutes(router, auth, profileService, totpService, auditService, codeStore)
 	// 注册提醒功能的路由
-	routes.ReminderRoutes(router, userService, reminderService)
+	routes.ReminderRoutes(router, auth, reminderService, profileService, auditService)
+	// 注册管理员接口的路由
+	routes.AdminRoutes(router, auth, userService, reminderService, sessionService, deliveryLogService, auditService)
 
 	// 启动服务
 	log.Println("服务启动在端口 :9900")
@@ -10,6 +10,8 @@ import (
 // JSONTime 自定义时间类型，处理时间解析与格式化
 type JSONTime struct {
 	time.Time
+	// hasOffset 请求中的时间是否带有时区偏移（RFC3339，包括 Z 和 +00:00）
+	hasOffset bool
 }
 
 // 时间格式常量
@@ -29,6 +31,7 @@ func (jt *JSONTime) UnmarshalJSON(data []byte) error {
 	}
 
 	// 解析时允许不同格式，但最终统一转换为 "YYYY-MM-DD HH:MM:SS"
+	hasOffset := true
 	parsedTime, err := time.Parse(time.RFC3339, t)
 	if err != nil {
 		// 如果解析 RFC3339 失败，尝试使用自定义格式
@@ -36,11 +39,19 @@ func (jt *JSONTime) UnmarshalJSON(data []byte) error {
 		if err != nil {
 			return err
 		}
+		hasOffset = false
 	}
 	jt.Time = parsedTime
+	jt.hasOffset = hasOffset
 	return nil
 }
 
+// HasOffset 解析的时间是否带有时区偏移；不带偏移时 time.Parse 返回 UTC，
+// 无法通过 Location 与带 Z 的时间区分，需要由调用方按用户时区重新解释
+func (jt JSONTime) HasOffset() bool {
+	return jt.hasOffset
+}
+
 // Scan 实现 sql.Scanner 接口，用于从数据库中读取 time.Time 数据
 func (jt *JSONTime) Scan(value interface{}) error {
 	if value == nil {
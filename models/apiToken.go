@@ -0,0 +1,15 @@
+package models
+
+// API Token 实体类，供脚本和内部服务通过 Authorization: Bearer 调用接口
+type ApiToken struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatorID  string    `gorm:"not null;index" json:"creator_id"`
+	Name       string    `gorm:"not null" json:"name"`
+	TokenHash  string    `gorm:"unique;not null" json:"-"`   // 只保存 token 的哈希
+	Prefix     string    `gorm:"not null" json:"prefix"`     // token 前几位，便于用户辨认
+	Scopes     string    `gorm:"not null" json:"scopes"`     // 逗号分隔的权限范围，如 reminders:read,reminders:write
+	ExpiresAt  JSONTime  `gorm:"not null" json:"expires_at"` // 过期时间
+	LastUsedAt *JSONTime `json:"last_used_at"`               // 最近使用时间，从未使用时为空
+	CreatedAt  JSONTime  `json:"created_at"`
+	UpdatedAt  JSONTime  `json:"updated_at"`
+}
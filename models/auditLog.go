@@ -0,0 +1,14 @@
+package models
+
+// 安全审计日志，记录登录、登出、提醒修改等操作
+type AuditLog struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	Actor     string   `gorm:"index" json:"actor"`           // 操作者的 creator_id，未登录时为空
+	Action    string   `gorm:"index;not null" json:"action"` // 操作类型，例如 auth.login、reminder.update
+	Target    string   `json:"target"`                       // 操作对象，例如手机号、提醒ID、被操作的用户
+	IP        string   `json:"ip"`
+	UserAgent string   `json:"user_agent"`
+	Outcome   string   `gorm:"not null" json:"outcome"` // success 或 failure
+	Detail    string   `json:"detail"`                  // 失败原因等补充说明
+	CreatedAt JSONTime `gorm:"index" json:"created_at"`
+}
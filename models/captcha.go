@@ -0,0 +1,9 @@
+package models
+
+// 人机验证挑战：客户端需找到 nonce，使 SHA-256(challenge + ":" + nonce) 的前 difficulty 个比特全为 0
+type Captcha struct {
+	ID         string   `json:"captcha_id"`
+	Challenge  string   `json:"challenge"`
+	Difficulty int      `json:"difficulty"`
+	ExpiresAt  JSONTime `json:"expires_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// 账号注销回执实体类，用于合规留档。只保存用户标识的哈希，不保留手机号等个人信息
+type DeletionReceipt struct {
+	ID               uint     `gorm:"primaryKey" json:"-"`
+	ReceiptID        string   `gorm:"unique;not null" json:"receipt_id"`
+	CreatorIDHash    string   `gorm:"not null;index" json:"creator_id_hash"` // creator_id 的 SHA-256
+	RemindersDeleted int64    `json:"reminders_deleted"`
+	DeletedAt        JSONTime `json:"deleted_at"`
+}
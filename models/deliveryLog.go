@@ -0,0 +1,12 @@
+package models
+
+// 提醒投递记录，每次消费提醒消息时记录一次投递结果
+type DeliveryLog struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	CreatorID string   `gorm:"index;not null" json:"creator_id"`
+	Content   string   `json:"content"`
+	Mobile    string   `json:"mobile"`
+	Status    string   `gorm:"not null" json:"status"` // sent、failed、skipped 或 deferred
+	Reason    string   `json:"reason"`                 // 失败原因或未发送的原因
+	CreatedAt JSONTime `json:"created_at"`
+}
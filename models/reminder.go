@@ -1,11 +1,26 @@
 package models
 
-// 提醒实体类
+// 提醒实体类。列表查询总是按 creator_id 过滤，复合索引以 creator_id 开头，分别支持按提醒时间、投递状态和创建时间筛选排序
 type Reminder struct {
-	ID        uint     `gorm:"primaryKey" json:"id"`
-	CreatorID string   `gorm:"not null" json:"creator_id"`
-	Content   string   `gorm:"not null" json:"content"`
-	RemindAt  JSONTime `json:"remind_at"`  // 使用自定义时间类型
-	CreatedAt JSONTime `json:"created_at"` // 使用自定义时间类型
-	UpdatedAt JSONTime `json:"updated_at"` // 使用自定义时间类型
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	CreatorID string `gorm:"size:128;not null;index:idx_reminders_creator_remind_at,priority:1;index:idx_reminders_creator_status,priority:1;index:idx_reminders_creator_created_at,priority:1" json:"creator_id"`
+	Content   string `gorm:"not null" json:"content"`
+	// 使用自定义时间类型；重复提醒时为重复规则的起始时间
+	RemindAt JSONTime `gorm:"index:idx_reminders_creator_remind_at,priority:2;index:idx_reminders_creator_status,priority:3" json:"remind_at"`
+	// RFC 5545 重复规则，例如 FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR，为空表示单次提醒
+	RRule string `gorm:"column:rrule;size:1024" json:"rrule"`
+	// RFC 5545 EXDATE 排除日期，逗号分隔，例如 20250102,20250103T090000，只用于重复提醒
+	ExDate string `gorm:"column:exdate;size:1024" json:"exdate"`
+	// 提醒的版本，队列中版本不一致的消息不会发送
+	Version int `gorm:"not null;default:1" json:"version"`
+	// 投递状态：scheduled、sending、sent、failed 或 cancelled
+	Status       string    `gorm:"size:16;not null;default:scheduled;index:idx_reminders_creator_status,priority:2" json:"status"`
+	SentAt       *JSONTime `json:"sent_at"`                                // 最近一次发送成功的时间，从未发送时为空
+	Attempts     int       `gorm:"not null;default:0" json:"attempts"`     // 累计尝试发送的次数
+	LastError    string    `json:"last_error"`                             // 最近一次发送失败或取消的原因
+	SnoozeCount  int       `gorm:"not null;default:0" json:"snooze_count"` // 累计推迟的次数
+	SnoozedUntil *JSONTime `json:"snoozed_until"`                          // 最近一次推迟到的时间，从未推迟时为空
+	Tags         []string  `gorm:"-" json:"tags"`                          // 标签，保存在 reminder_tags 表中
+	CreatedAt    JSONTime  `gorm:"index:idx_reminders_creator_created_at,priority:2" json:"created_at"`
+	UpdatedAt    JSONTime  `json:"updated_at"` // 使用自定义时间类型
 }
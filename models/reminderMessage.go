@@ -2,6 +2,12 @@ package models
 
 // 定义一个结构体来封装提醒内容和手机号
 type ReminderMessage struct {
-	Content string `json:"content"`
-	Mobile  string `json:"mobile"`
+	ReminderID   uint     `json:"reminder_id,omitempty"` // 对应的提醒，发送前据此确认提醒没有被删除，重复提醒发送后据此安排下一次提醒
+	Version      int      `json:"version,omitempty"`     // 发布消息时提醒的版本，提醒更新后旧版本的消息不再发送
+	CreatorID    string   `json:"creator_id"`            // 提醒所属用户，消费时据此确认用户仍然存在
+	Content      string   `json:"content"`
+	Mobile       string   `json:"mobile"`
+	Snoozed      bool     `json:"snoozed,omitempty"`      // 推迟后重新发送的消息，发送后不安排重复提醒的下一次提醒
+	SnoozeCount  int      `json:"snooze_count,omitempty"` // 推迟消息对应的推迟次数，再次推迟后之前的推迟消息不再发送
+	OccurrenceAt JSONTime `json:"occurrence_at"`          // 本次提醒的计划时间，免打扰推迟后保持不变
 }
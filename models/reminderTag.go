@@ -0,0 +1,9 @@
+package models
+
+// 提醒标签，一个提醒可以有多个标签，按标签筛选提醒时使用
+type ReminderTag struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	ReminderID uint   `gorm:"not null;index" json:"-"`
+	CreatorID  string `gorm:"size:128;not null;index:idx_reminder_tags_creator_tag,priority:1" json:"creator_id"`
+	Tag        string `gorm:"size:32;not null;index:idx_reminder_tags_creator_tag,priority:2" json:"tag"`
+}
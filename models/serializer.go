@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("emptynull", EmptyAsNullSerializer{})
+}
+
+// EmptyAsNullSerializer 字符串字段序列化器：空字符串存为 NULL，读取 NULL 时还原为空字符串
+// 用于允许为空的唯一字段，例如通过第三方登录注册、尚未绑定手机号的用户
+type EmptyAsNullSerializer struct{}
+
+// Scan 实现 schema.SerializerInterface 接口
+func (EmptyAsNullSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value sql.NullString
+	if err := value.Scan(dbValue); err != nil {
+		return err
+	}
+	return field.Set(ctx, dst, value.String)
+}
+
+// Value 实现 schema.SerializerValuerInterface 接口
+func (EmptyAsNullSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if s, ok := fieldValue.(string); ok && s == "" {
+		return nil, nil
+	}
+	return fieldValue, nil
+}
@@ -0,0 +1,14 @@
+package models
+
+// 登录会话实体类，每次登录生成一条，保存在 Redis 或内存中
+type Session struct {
+	ID         string   `json:"id"`
+	CreatorID  string   `json:"creator_id"`
+	Device     string   `json:"device"`     // 设备名称，由客户端登录时传入
+	IP         string   `json:"ip"`         // 登录时的客户端 IP
+	UserAgent  string   `json:"user_agent"` // 登录时的 User-Agent
+	CreatedAt  JSONTime `json:"created_at"`
+	LastSeenAt JSONTime `json:"last_seen_at"`
+	ExpiresAt  JSONTime `json:"expires_at"` // 过期时间，刷新会话时顺延
+	Current    bool     `json:"current"`    // 是否为发起本次请求的会话，仅在查询列表时填充
+}
@@ -3,8 +3,10 @@ package models
 // 用户实体类
 type User struct {
 	ID        uint     `gorm:"primaryKey" json:"id"`
-	Mobile    string   `gorm:"unique;not null" json:"mobile"`
+	Mobile    string   `gorm:"unique;serializer:emptynull" json:"mobile"` // 通过第三方登录注册的用户可能没有手机号
 	CreatorID string   `gorm:"unique;not null" json:"creator_id"`
+	Role      string   `gorm:"not null;default:user" json:"role"`      // user 或 admin
+	Disabled  bool     `gorm:"not null;default:false" json:"disabled"` // 被管理员禁用的用户不能登录，提醒也不会发送
 	CreatedAt JSONTime `json:"created_at"`
 	UpdatedAt JSONTime `json:"updated_at"`
 }
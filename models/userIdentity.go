@@ -0,0 +1,12 @@
+package models
+
+// 第三方身份实体类，记录外部身份提供方（OIDC）的用户标识与本系统用户的关联
+type UserIdentity struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	CreatorID string   `gorm:"not null;index" json:"creator_id"`
+	Issuer    string   `gorm:"not null;uniqueIndex:idx_issuer_subject;size:255" json:"issuer"`  // 身份提供方 issuer
+	Subject   string   `gorm:"not null;uniqueIndex:idx_issuer_subject;size:255" json:"subject"` // 身份提供方中的用户标识 sub
+	Email     string   `json:"email"`
+	CreatedAt JSONTime `json:"created_at"`
+	UpdatedAt JSONTime `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// 用户资料实体类，保存显示名称、时区、语言、免打扰时段和通知渠道
+type UserProfile struct {
+	ID                  uint     `gorm:"primaryKey" json:"-"`
+	CreatorID           string   `gorm:"unique;not null" json:"creator_id"`
+	DisplayName         string   `json:"display_name"`
+	Timezone            string   `gorm:"not null" json:"timezone"` // IANA 时区，例如 Asia/Shanghai
+	Language            string   `json:"language"`                 // 语言标签，例如 zh-CN
+	QuietHoursStart     string   `json:"quiet_hours_start"`        // 免打扰开始时间 HH:MM，为空表示不启用
+	QuietHoursEnd       string   `json:"quiet_hours_end"`          // 免打扰结束时间 HH:MM，可以跨过零点
+	NotificationChannel string   `gorm:"not null" json:"notification_channel"`
+	CreatedAt           JSONTime `json:"created_at"`
+	UpdatedAt           JSONTime `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// 用户的 TOTP 动态验证码（两步验证）配置
+type UserTOTP struct {
+	ID           uint     `gorm:"primaryKey" json:"-"`
+	CreatorID    string   `gorm:"unique;not null" json:"creator_id"`
+	Secret       string   `gorm:"not null" json:"-"`
+	Confirmed    bool     `gorm:"not null;default:false" json:"confirmed"` // 用户用动态验证码确认后才生效
+	LastUsedStep int64    `json:"-"`                                       // 最近一次通过校验的时间步，防止同一验证码被重复使用
+	CreatedAt    JSONTime `json:"created_at"`
+	UpdatedAt    JSONTime `json:"updated_at"`
+}
+
+// 两步验证的恢复码，丢失身份验证器时使用，每个只能使用一次
+type RecoveryCode struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	CreatorID string    `gorm:"index;not null" json:"-"`
+	CodeHash  string    `gorm:"not null" json:"-"` // 只保存 SHA-256
+	UsedAt    *JSONTime `json:"used_at"`
+	CreatedAt JSONTime  `json:"created_at"`
+}
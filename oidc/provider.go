@@ -0,0 +1,249 @@
+package oidc
+
+import (
+	"calendarReminder-service/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryDocument 身份提供方的 /.well-known/openid-configuration 文档中用到的字段
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse 令牌端点的响应
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// 两次拉取 JWKS 的最小间隔，防止携带未知 kid 的令牌频繁触发拉取
+const jwksRefreshInterval = time.Minute
+
+// Provider OpenID Connect 授权码流程客户端
+type Provider struct {
+	cfg        config.OIDCConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *keySet
+	keysAt    time.Time // 最近一次拉取 JWKS 的时间
+}
+
+// NewProvider 创建 OIDC 客户端，发现文档在第一次使用时加载
+func NewProvider(cfg config.OIDCConfig) *Provider {
+	return &Provider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Issuer 返回配置的身份提供方 issuer
+func (p *Provider) Issuer() string {
+	return strings.TrimSuffix(p.cfg.Issuer, "/")
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址，使用 PKCE（S256）防止授权码被截获
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.scopes(), " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange 用授权码换取令牌，并校验 ID Token 的签名、issuer、audience、有效期和 nonce
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (*Claims, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求令牌端点失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取令牌响应失败: %w", err)
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("解析令牌响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("令牌端点返回错误: %d %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("令牌响应中缺少 id_token")
+	}
+
+	return p.verifyIDToken(token.IDToken, nonce)
+}
+
+// verifyIDToken 校验 ID Token 并返回其中的声明
+func (p *Provider) verifyIDToken(rawIDToken, nonce string) (*Claims, error) {
+	claims, err := verifyRS256(rawIDToken, p.getKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != p.Issuer() {
+		return nil, fmt.Errorf("id_token 的 issuer 不匹配: %s", claims.Issuer)
+	}
+	if !claims.Audience.contains(p.cfg.ClientID) {
+		return nil, errors.New("id_token 的 audience 不包含本服务")
+	}
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, errors.New("id_token 已过期")
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("id_token 签发时间无效")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id_token 的 nonce 不匹配")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token 中缺少 sub")
+	}
+	return claims, nil
+}
+
+// getDiscovery 加载并缓存发现文档
+func (p *Provider) getDiscovery() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(p.Issuer()+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("加载 OIDC 发现文档失败: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer() {
+		return nil, fmt.Errorf("发现文档中的 issuer 与配置不一致: %s", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少必要的端点")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// getKey 根据 kid 获取签名公钥，找不到时重新拉取一次 JWKS 以支持密钥轮换，两次拉取至少间隔 jwksRefreshInterval
+func (p *Provider) getKey(kid string) (interface{}, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil {
+		if key := p.keys.find(kid); key != nil {
+			return key, nil
+		}
+		if time.Since(p.keysAt) < jwksRefreshInterval {
+			return nil, fmt.Errorf("找不到 id_token 的签名公钥: %s", kid)
+		}
+	}
+
+	var keys keySet
+	if err := p.getJSON(doc.JWKSURI, &keys); err != nil {
+		return nil, fmt.Errorf("加载 JWKS 失败: %w", err)
+	}
+	p.keys = &keys
+	p.keysAt = time.Now()
+	if key := p.keys.find(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("找不到 id_token 的签名公钥: %s", kid)
+}
+
+// getJSON 发起 GET 请求并解析 JSON 响应
+func (p *Provider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.httpClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回状态码 %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func (p *Provider) scopes() []string {
+	scopes := p.cfg.Scopes
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return scopes
+		}
+	}
+	return append([]string{"openid"}, scopes...)
+}
+
+// RandomString 生成 URL 安全的随机字符串，用于 state、nonce 和 PKCE code_verifier
+func RandomString() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CodeChallenge 根据 PKCE code_verifier 计算 S256 code_challenge
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
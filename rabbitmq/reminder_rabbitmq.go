@@ -3,11 +3,14 @@ package rabbitmq
 import (
 	"calendarReminder-service/config"
 	"calendarReminder-service/models"
+	"calendarReminder-service/services"
 	"calendarReminder-service/utils"
 	"encoding/json"
+	"errors"
 	"github.com/streadway/amqp"
 	"log"
 	"sync"
+	"time"
 )
 
 var (
@@ -22,6 +25,11 @@ const (
 	routingKey   = "reminder.routing_key" // 路由键
 )
 
+// 发送前查询提醒失败时，消息重新投递的延迟
+const retryDelay = time.Minute
+
+var errRabbitMQNotConnected = errors.New("RabbitMQ 未连接")
+
 // setupRabbitMQ 设置交换机和队列，并进行绑定
 func SetupRabbitMQ() error {
 	log.Println("开始设置 RabbitMQ")
@@ -86,8 +94,13 @@ func SetupRabbitMQ() error {
 }
 
 // PublishReminderToQueue 发布消息到队列
-func PublishReminderToQueue(content string, delay int64, mobile string) error {
-	log.Printf("开始发布消息: 内容=%s, 手机号=%s, 延迟=%d 毫秒", content, mobile, delay)
+func PublishReminderToQueue(reminderMsg models.ReminderMessage, delay int64) error {
+	log.Printf("开始发布消息: 内容=%s, 手机号=%s, 延迟=%d 毫秒", reminderMsg.Content, reminderMsg.Mobile, delay)
+
+	if config.RabbitMQConn == nil {
+		log.Println("RabbitMQ 未连接，无法发布消息")
+		return errRabbitMQNotConnected
+	}
 
 	// 继续使用北京时间
 	ch, err := config.RabbitMQConn.Channel()
@@ -97,11 +110,6 @@ func PublishReminderToQueue(content string, delay int64, mobile string) error {
 	}
 	defer ch.Close()
 
-	reminderMsg := models.ReminderMessage{
-		Content: content,
-		Mobile:  mobile,
-	}
-
 	body, err := json.Marshal(reminderMsg)
 	if err != nil {
 		log.Printf("消息体序列化失败: %v", err)
@@ -127,12 +135,37 @@ func PublishReminderToQueue(content string, delay int64, mobile string) error {
 		return err
 	}
 
-	log.Printf("消息发布成功，内容: %s，手机号: %s，延迟 %d 毫秒发送", content, mobile, delay)
+	log.Printf("消息发布成功，内容: %s，手机号: %s，延迟 %d 毫秒发送", reminderMsg.Content, reminderMsg.Mobile, delay)
 	return nil
 }
 
+// ScheduleOccurrence 把提醒在 occurrence 时刻的一次提醒发布到延迟队列
+func ScheduleOccurrence(reminder *models.Reminder, mobile string, occurrence time.Time) error {
+	reminderMsg := newReminderMessage(reminder, mobile, occurrence)
+	return PublishReminderToQueue(reminderMsg, time.Until(occurrence).Milliseconds())
+}
+
+// ScheduleSnooze 把推迟后的提醒发布到延迟队列，在 until 时刻重新发送
+func ScheduleSnooze(reminder *models.Reminder, mobile string, until time.Time) error {
+	reminderMsg := newReminderMessage(reminder, mobile, until)
+	reminderMsg.Snoozed = true
+	reminderMsg.SnoozeCount = reminder.SnoozeCount
+	return PublishReminderToQueue(reminderMsg, time.Until(until).Milliseconds())
+}
+
+func newReminderMessage(reminder *models.Reminder, mobile string, occurrence time.Time) models.ReminderMessage {
+	return models.ReminderMessage{
+		ReminderID:   reminder.ID,
+		Version:      reminder.Version,
+		CreatorID:    reminder.CreatorID,
+		Content:      reminder.Content,
+		Mobile:       mobile,
+		OccurrenceAt: models.JSONTime{Time: occurrence},
+	}
+}
+
 // ConsumeReminders 消费队列中的消息
-func ConsumeReminders() error {
+func ConsumeReminders(reminderService services.ReminderService, userService services.UserService, profileService services.ProfileService, deliveryLogService services.DeliveryLogService) error {
 	log.Println("准备消费消息")
 
 	// 使用全局的 RabbitMQ 连接
@@ -175,14 +208,215 @@ func ConsumeReminders() error {
 		// 日志输出解析的消息内容
 		log.Printf("解析成功，内容: %s，手机号: %s", reminderMsg.Content, reminderMsg.Mobile)
 
-		// 调用 utils.SendSMSReminder 发送短信提醒
-		err := utils.SendSMSReminder(reminderMsg.Content, reminderMsg.Mobile)
-		if err != nil {
-			log.Printf("短信发送失败: %v", err)
-		} else {
-			log.Printf("短信发送成功，手机号: %s", reminderMsg.Mobile)
+		// 提醒已删除或已更新的消息不再发送
+		if !isCurrentMessage(reminderMsg, reminderService) {
+			continue
+		}
+
+		// 本次提醒处理完成后（推迟和取消的除外），重复提醒安排下一次提醒；用户推迟后重新发送的消息不安排
+		if deliverReminder(reminderMsg, reminderService, userService, profileService, deliveryLogService) && !reminderMsg.Snoozed {
+			scheduleNextOccurrence(reminderMsg, reminderService, profileService)
 		}
 	}
 
 	return nil
 }
+
+// isCurrentMessage 发送前查询数据库确认消息仍然有效：提醒已删除、消息版本落后于提醒，或推迟消息已被再次推迟时返回 false。
+// 没有 reminder_id 的旧消息总是有效；查询失败时稍后重新投递消息，避免把已删除的提醒发出去
+func isCurrentMessage(reminderMsg models.ReminderMessage, reminderService services.ReminderService) bool {
+	if reminderMsg.ReminderID == 0 {
+		return true
+	}
+
+	reminder, err := reminderService.GetReminderByID(reminderMsg.ReminderID)
+	if err != nil {
+		log.Printf("查询提醒失败，%v 后重试, 提醒ID: %d: %v", retryDelay, reminderMsg.ReminderID, err)
+		if err := PublishReminderToQueue(reminderMsg, retryDelay.Milliseconds()); err != nil {
+			log.Printf("重新投递消息失败, 提醒ID: %d: %v", reminderMsg.ReminderID, err)
+		}
+		return false
+	}
+	if reminder == nil {
+		log.Printf("提醒已删除，丢弃消息, 提醒ID: %d", reminderMsg.ReminderID)
+		return false
+	}
+	if reminder.Version != reminderMsg.Version {
+		log.Printf("提醒已更新，丢弃旧版本的消息, 提醒ID: %d, 消息版本: %d, 当前版本: %d", reminder.ID, reminderMsg.Version, reminder.Version)
+		return false
+	}
+	if reminderMsg.Snoozed && reminder.SnoozeCount != reminderMsg.SnoozeCount {
+		log.Printf("提醒已再次推迟，丢弃之前的推迟消息, 提醒ID: %d", reminder.ID)
+		return false
+	}
+	return true
+}
+
+// deliverReminder 投递一条到期的提醒并记录投递结果，返回是否需要安排重复提醒的下一次。
+// 本次提醒被推迟、稍后重试、用户已注销，或因用户被禁用、没有可用的通知渠道而取消时返回 false
+func deliverReminder(reminderMsg models.ReminderMessage, reminderService services.ReminderService, userService services.UserService, profileService services.ProfileService, deliveryLogService services.DeliveryLogService) bool {
+	// 用户注销后，延迟交换机中尚未投递的消息不再发送；用户更换手机号后发送到新手机号
+	user, err := resolveRecipient(userService, reminderMsg)
+	if err != nil {
+		log.Printf("查询提醒所属用户失败，%v 后重试: %v", retryDelay, err)
+		retryDelivery(reminderMsg, reminderService, deliveryLogService, "查询用户失败: "+err.Error())
+		return false
+	}
+	if user == nil {
+		log.Printf("提醒所属用户已注销，丢弃消息，手机号: %s", reminderMsg.Mobile)
+		return false
+	}
+
+	record := func(mobile, status, reason string) {
+		if err := deliveryLogService.Record(user.CreatorID, reminderMsg.Content, mobile, status, reason); err != nil {
+			log.Printf("记录投递结果失败: %v", err)
+		}
+	}
+
+	// 更新提醒的投递状态，没有 reminder_id 的旧消息只记录投递日志
+	setStatus := func(status, lastError string) {
+		if reminderMsg.ReminderID == 0 {
+			return
+		}
+		if err := reminderService.UpdateReminderStatus(reminderMsg.ReminderID, status, lastError); err != nil {
+			log.Printf("更新提醒投递状态失败, 提醒ID: %d: %v", reminderMsg.ReminderID, err)
+		}
+	}
+
+	if user.Disabled {
+		log.Printf("提醒所属用户已被禁用，不发送短信，用户ID: %s", user.CreatorID)
+		record("", services.DeliverySkipped, "用户已被禁用")
+		setStatus(services.ReminderCancelled, "用户已被禁用")
+		return false
+	}
+	if user.Mobile == "" {
+		log.Printf("提醒所属用户未绑定手机号，丢弃消息，用户ID: %s", user.CreatorID)
+		record("", services.DeliverySkipped, "未绑定手机号")
+		setStatus(services.ReminderCancelled, "未绑定手机号")
+		return false
+	}
+	mobile := user.Mobile
+
+	// 按用户资料中的通知渠道和免打扰时段投递
+	profile, err := profileService.GetProfile(user.CreatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败，%v 后重试: %v", retryDelay, err)
+		retryDelivery(reminderMsg, reminderService, deliveryLogService, "获取用户资料失败: "+err.Error())
+		return false
+	}
+	if profile.NotificationChannel == services.ChannelNone {
+		log.Printf("用户关闭了通知，不发送短信，用户ID: %s", user.CreatorID)
+		record(mobile, services.DeliverySkipped, "用户关闭了通知")
+		setStatus(services.ReminderCancelled, "用户关闭了通知")
+		return false
+	}
+	if until, quiet := services.QuietHoursEnd(profile, time.Now()); quiet {
+		log.Printf("处于免打扰时段，推迟到 %v 发送，用户ID: %s", until, user.CreatorID)
+		if err := PublishReminderToQueue(reminderMsg, time.Until(until).Milliseconds()); err != nil {
+			log.Printf("推迟提醒失败: %v", err)
+			record(mobile, services.DeliveryFailed, "推迟提醒失败: "+err.Error())
+			setStatus(services.ReminderFailed, "推迟提醒失败: "+err.Error())
+			return true
+		}
+		record(mobile, services.DeliveryDeferred, "免打扰时段，推迟到 "+until.Format("2006-01-02 15:04:05"))
+		return false
+	}
+
+	// 调用 utils.SendSMSReminder 发送短信提醒
+	setStatus(services.ReminderSending, "")
+	err = utils.SendSMSReminder(reminderMsg.Content, mobile)
+	if err != nil {
+		log.Printf("短信发送失败: %v", err)
+		record(mobile, services.DeliveryFailed, err.Error())
+		setStatus(services.ReminderFailed, err.Error())
+		return true
+	}
+	log.Printf("短信发送成功，手机号: %s", mobile)
+	record(mobile, services.DeliverySent, "")
+	setStatus(services.ReminderSent, "")
+	return true
+}
+
+// retryDelivery 发送前查询数据库失败时记录失败的投递结果，并在 retryDelay 后重新投递消息；
+// 重新投递也失败时把提醒标记为 failed。此时没有发送短信，不能安排重复提醒的下一次
+func retryDelivery(reminderMsg models.ReminderMessage, reminderService services.ReminderService, deliveryLogService services.DeliveryLogService, reason string) {
+	if err := deliveryLogService.Record(reminderMsg.CreatorID, reminderMsg.Content, reminderMsg.Mobile, services.DeliveryFailed, reason); err != nil {
+		log.Printf("记录投递结果失败: %v", err)
+	}
+	if err := PublishReminderToQueue(reminderMsg, retryDelay.Milliseconds()); err != nil {
+		log.Printf("重新投递消息失败, 提醒ID: %d: %v", reminderMsg.ReminderID, err)
+		if reminderMsg.ReminderID == 0 {
+			return
+		}
+		if err := reminderService.UpdateReminderStatus(reminderMsg.ReminderID, services.ReminderFailed, reason); err != nil {
+			log.Printf("更新提醒投递状态失败, 提醒ID: %d: %v", reminderMsg.ReminderID, err)
+		}
+	}
+}
+
+// scheduleNextOccurrence 重复提醒发送后，把下一次提醒发布到延迟队列；提醒已删除或没有下一次时不再发布
+func scheduleNextOccurrence(reminderMsg models.ReminderMessage, reminderService services.ReminderService, profileService services.ProfileService) {
+	// 没有 reminder_id 的旧消息只发送一次
+	if reminderMsg.ReminderID == 0 {
+		return
+	}
+	reminder, err := reminderService.GetReminderByID(reminderMsg.ReminderID)
+	if err != nil {
+		log.Printf("查询提醒失败: %v", err)
+		return
+	}
+	if reminder == nil || reminder.RRule == "" {
+		return
+	}
+
+	profile, err := profileService.GetProfile(reminder.CreatorID)
+	if err != nil {
+		log.Printf("获取用户资料失败: %v", err)
+		return
+	}
+
+	// 从本次提醒的计划时间往后计算；消息积压或被推迟时跳过已经错过的提醒，避免集中补发
+	after := reminderMsg.OccurrenceAt.Time
+	if now := time.Now(); after.Before(now) {
+		after = now
+	}
+	next, ok, err := services.NextOccurrence(reminder, services.ProfileLocation(profile), after)
+	if err != nil {
+		log.Printf("解析重复规则失败, 提醒ID: %d: %v", reminder.ID, err)
+		return
+	}
+	if !ok {
+		log.Printf("重复提醒已结束, 提醒ID: %d", reminder.ID)
+		return
+	}
+	if err := ScheduleOccurrence(reminder, reminderMsg.Mobile, next); err != nil {
+		log.Printf("安排下一次提醒失败, 提醒ID: %d: %v", reminder.ID, err)
+		if err := reminderService.UpdateReminderStatus(reminder.ID, services.ReminderFailed, "安排下一次提醒失败: "+err.Error()); err != nil {
+			log.Printf("更新提醒投递状态失败, 提醒ID: %d: %v", reminder.ID, err)
+		}
+		return
+	}
+	if err := reminderService.UpdateReminderStatus(reminder.ID, services.ReminderScheduled, ""); err != nil {
+		log.Printf("更新提醒投递状态失败, 提醒ID: %d: %v", reminder.ID, err)
+	}
+	log.Printf("已安排下一次提醒, 提醒ID: %d, 时间: %v", reminder.ID, next)
+}
+
+// resolveRecipient 返回提醒所属的用户，用户不存在时返回 nil；兼容没有 creator_id 的旧消息
+func resolveRecipient(userService services.UserService, reminderMsg models.ReminderMessage) (*models.User, error) {
+	var user *models.User
+	var err error
+	if reminderMsg.CreatorID != "" {
+		user, err = userService.GetUserByCreatorID(reminderMsg.CreatorID)
+	} else {
+		mobile := reminderMsg.Mobile
+		if normalized, normalizeErr := utils.NormalizePhoneNumber(mobile); normalizeErr == nil {
+			mobile = normalized
+		}
+		user, err = userService.GetUserByMobile(mobile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
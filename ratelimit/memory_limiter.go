@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// 每处理多少次请求清理一次过期的限流记录
+const memorySweepInterval = 1000
+
+// memoryEntry 单个 key 在窗口内的请求时间
+type memoryEntry struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+// MemoryRateLimiter 进程内的滑动窗口限流器，适用于单机部署和测试
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	calls   int
+}
+
+// NewMemoryRateLimiter 创建内存限流器
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{entries: make(map[string]*memoryEntry)}
+}
+
+// AllowAll 实现 RateLimiter 接口，在同一把锁内检查并记录全部规则
+func (l *MemoryRateLimiter) AllowAll(rules []Rule) (bool, int, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.calls++
+	if l.calls%memorySweepInterval == 0 {
+		l.sweep(now)
+	}
+
+	entries := make([]*memoryEntry, len(rules))
+	for i, rule := range rules {
+		entry, ok := l.entries[rule.Key]
+		if !ok {
+			entry = &memoryEntry{}
+			l.entries[rule.Key] = entry
+		}
+		entry.window = rule.Window
+		entry.hits = prune(entry.hits, now.Add(-rule.Window))
+		if len(entry.hits) >= rule.Limit {
+			return false, i, entry.hits[0].Add(rule.Window).Sub(now), nil
+		}
+		entries[i] = entry
+	}
+	for _, entry := range entries {
+		entry.hits = append(entry.hits, now)
+	}
+	return true, 0, 0, nil
+}
+
+// sweep 删除窗口内已经没有请求记录的 key，避免内存无限增长
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, entry := range l.entries {
+		entry.hits = prune(entry.hits, now.Add(-entry.window))
+		if len(entry.hits) == 0 {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// prune 去掉 since 之前（含）的请求记录
+func prune(hits []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(since) {
+		i++
+	}
+	return hits[i:]
+}
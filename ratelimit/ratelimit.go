@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"calendarReminder-service/config"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimiter 限流器接口：每条规则的 key 在 window 时间窗口内最多允许 limit 次请求
+// 超出限制时返回 false 以及建议的重试等待时间
+type RateLimiter interface {
+	// AllowAll 先检查全部规则，全部通过后才为每条规则记录一次请求；
+	// 被拒绝时返回触发的规则下标，不记录任何请求
+	AllowAll(rules []Rule) (bool, int, time.Duration, error)
+}
+
+// Redis中存储限流计数的Key前缀，与验证码的Key前缀区分开
+const RATE_LIMIT = "RATE_LIMIT:"
+
+// NewRateLimiter 根据配置的后端类型创建限流器，redis 不可用时使用内存实现
+func NewRateLimiter(backend string, redisClient *redis.Client) RateLimiter {
+	if backend == "memory" || redisClient == nil {
+		log.Println("使用内存限流器")
+		return NewMemoryRateLimiter()
+	}
+	log.Println("使用 Redis 限流器")
+	return NewRedisRateLimiter(redisClient)
+}
+
+// Check 检查多条规则，任意一条超限即拒绝，limit 为 0 的规则视为不限制
+// 被拒绝的请求不计入任何规则，避免被单个 IP 拒绝的请求占满全局配额
+func Check(limiter RateLimiter, checks ...Rule) (bool, time.Duration, error) {
+	rules := make([]Rule, 0, len(checks))
+	for _, rule := range checks {
+		if rule.Limit > 0 && rule.Window > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return true, 0, nil
+	}
+
+	allowed, index, retryAfter, err := limiter.AllowAll(rules)
+	if err != nil {
+		return false, 0, fmt.Errorf("限流检查失败: %w", err)
+	}
+	if !allowed {
+		rule := rules[index]
+		log.Printf("触发限流规则: %s, %d 次/%v", rule.Key, rule.Limit, rule.Window)
+		return false, retryAfter, nil
+	}
+	return true, 0, nil
+}
+
+// Rule 一条具体的限流检查
+type Rule struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+}
+
+// NewRule 根据配置生成限流检查，key 由规则名和限流对象组成
+func NewRule(name string, cfg config.RateLimitRule, subject string) Rule {
+	return Rule{
+		Key:    name + ":" + subject,
+		Limit:  cfg.Limit,
+		Window: cfg.Window,
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/utils"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 多规则滑动窗口限流脚本：先清理并检查全部 key，全部未超限时才为每个 key 写入本次请求
+// ARGV 依次为当前毫秒时间戳、成员值，以及每个 key 对应的窗口毫秒数和次数上限
+// 返回 {0, 0} 表示通过，否则返回 {触发的规则序号（从 1 开始）, 重试等待毫秒数}
+var multiSlidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local member = ARGV[2]
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[1 + i * 2])
+	local limit = tonumber(ARGV[2 + i * 2])
+	redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+	if redis.call('ZCARD', key) >= limit then
+		local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+		return {i, tonumber(oldest[2]) + window - now}
+	end
+end
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[1 + i * 2])
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+end
+return {0, 0}
+`)
+
+// RedisRateLimiter 基于 Redis 有序集合的滑动窗口限流器，适用于多实例部署
+type RedisRateLimiter struct {
+	redisClient *redis.Client
+}
+
+// NewRedisRateLimiter 创建 Redis 限流器
+func NewRedisRateLimiter(redisClient *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{redisClient: redisClient}
+}
+
+// AllowAll 实现 RateLimiter 接口，通过一个脚本原子地检查并记录全部规则
+func (l *RedisRateLimiter) AllowAll(rules []Rule) (bool, int, time.Duration, error) {
+	keys := make([]string, len(rules))
+	args := []interface{}{time.Now().UnixMilli(), utils.GenerateUUID()}
+	for i, rule := range rules {
+		keys[i] = RATE_LIMIT + rule.Key
+		args = append(args, rule.Window.Milliseconds(), rule.Limit)
+	}
+	result, err := multiSlidingWindowScript.Run(config.Ctx, l.redisClient, keys, args...).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if result[0] > 0 {
+		return false, int(result[0]) - 1, time.Duration(result[1]) * time.Millisecond, nil
+	}
+	return true, 0, 0, nil
+}
@@ -3,53 +3,247 @@ package routes
 import (
 	"calendarReminder-service/config"
 	"calendarReminder-service/controllers"
+	"calendarReminder-service/oidc"
+	"calendarReminder-service/ratelimit"
 	"calendarReminder-service/services"
+	"calendarReminder-service/store"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func PassportRoutes(r *mux.Router, userService services.UserService) {
+func PassportRoutes(r *mux.Router, auth mux.MiddlewareFunc, codeStore store.CodeStore, userService services.UserService, sessionService services.SessionService, captchaService services.CaptchaService, totpService services.TOTPService, auditService services.AuditService, limiter ratelimit.RateLimiter, rateLimitConfig config.RateLimitConfig) {
+	// 获取人机验证挑战接口
+	r.HandleFunc("/captcha", func(w http.ResponseWriter, r *http.Request) {
+		controllers.GetCaptcha(w, r, captchaService, limiter, rateLimitConfig.Captcha)
+	}).Methods("GET")
 
 	// 发送短信验证码接口
 	r.HandleFunc("/getSMSCode", func(w http.ResponseWriter, r *http.Request) {
-		controllers.GetSMSCode(w, r, config.RedisClient) // 直接使用 config.RedisClient
+		controllers.GetSMSCode(w, r, codeStore, limiter, rateLimitConfig.SMS, captchaService)
 	}).Methods("GET")
 
 	// 登录接口
 	r.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		controllers.Login(w, r, userService, config.RedisClient) // 直接使用 config.RedisClient
+		controllers.Login(w, r, userService, sessionService, totpService, auditService, codeStore)
+	}).Methods("POST")
+
+	// 开启两步验证的用户提交动态验证码完成登录
+	r.HandleFunc("/login/2fa", func(w http.ResponseWriter, r *http.Request) {
+		controllers.LoginTwoFactor(w, r, userService, sessionService, totpService, auditService, codeStore)
 	}).Methods("POST")
 
-	// 登出接口
-	r.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
-		controllers.Logout(w, r, config.RedisClient) // 直接使用 config.RedisClient
+	// 登出接口，只注销当前会话
+	logoutRouter := r.PathPrefix("/logout").Subrouter()
+	logoutRouter.Use(auth, controllers.RequireScope("", ""))
+	logoutRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		controllers.Logout(w, r, sessionService, auditService)
 	}).Methods("POST")
 
 }
 
-func ReminderRoutes(r *mux.Router, userService services.UserService, reminderService services.ReminderService) {
+func OIDCRoutes(r *mux.Router, provider *oidc.Provider, codeStore store.CodeStore, userService services.UserService, sessionService services.SessionService, totpService services.TOTPService, auditService services.AuditService, postLoginRedirect string) {
+	// 发起第三方登录，跳转到身份提供方
+	r.HandleFunc("/oidc/login", func(w http.ResponseWriter, r *http.Request) {
+		controllers.OIDCLogin(w, r, provider, codeStore)
+	}).Methods("GET")
+
+	// 身份提供方登录完成后的回调
+	r.HandleFunc("/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+		controllers.OIDCCallback(w, r, provider, userService, sessionService, totpService, auditService, codeStore, postLoginRedirect)
+	}).Methods("GET")
+}
+
+func SessionRoutes(r *mux.Router, auth mux.MiddlewareFunc, sessionService services.SessionService) {
+	// 会话管理需要登录，且不允许使用 API Token
+	sessionRouter := r.PathPrefix("/sessions").Subrouter()
+	sessionRouter.Use(auth, controllers.RequireScope("", ""))
+
+	// GET: 查询会话列表；DELETE: 在所有设备上退出登录
+	sessionRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			controllers.GetSessions(w, r, sessionService)
+		}
+		if r.Method == http.MethodDelete {
+			controllers.DeleteAllSessions(w, r, sessionService)
+		}
+	}).Methods(http.MethodGet, http.MethodDelete)
+
+	// POST: 刷新当前会话，顺延过期时间
+	sessionRouter.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		controllers.RefreshSession(w, r, sessionService)
+	}).Methods(http.MethodPost)
+
+	// DELETE: 注销指定会话
+	sessionRouter.HandleFunc("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		controllers.DeleteSession(w, r, sessionService)
+	}).Methods(http.MethodDelete)
+}
+
+func ApiTokenRoutes(r *mux.Router, auth mux.MiddlewareFunc, apiTokenService services.ApiTokenService) {
+	// API Token 只能通过登录会话管理，不允许用 API Token 创建新的 API Token
+	tokenRouter := r.PathPrefix("/tokens").Subrouter()
+	tokenRouter.Use(auth, controllers.RequireScope("", ""))
+
+	// POST: 创建 API Token；GET: 查询 API Token 列表
+	tokenRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			controllers.CreateApiToken(w, r, apiTokenService)
+		}
+		if r.Method == http.MethodGet {
+			controllers.GetApiTokens(w, r, apiTokenService)
+		}
+	}).Methods(http.MethodPost, http.MethodGet)
+
+	// DELETE: 吊销 API Token
+	tokenRouter.HandleFunc("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		controllers.DeleteApiToken(w, r, apiTokenService)
+	}).Methods(http.MethodDelete)
+}
+
+func AccountRoutes(r *mux.Router, auth mux.MiddlewareFunc, codeStore store.CodeStore, userService services.UserService, accountService services.AccountService, sessionService services.SessionService, auditService services.AuditService) {
+	// 账号管理需要登录，且不允许使用 API Token
+	accountRouter := r.PathPrefix("/account").Subrouter()
+	accountRouter.Use(auth, controllers.RequireScope("", ""))
+
+	// DELETE: 注销账号
+	accountRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		controllers.DeleteAccount(w, r, accountService, sessionService, auditService, codeStore)
+	}).Methods(http.MethodDelete)
+
+	// PUT: 更换或绑定手机号
+	accountRouter.HandleFunc("/mobile", func(w http.ResponseWriter, r *http.Request) {
+		controllers.ChangeMobile(w, r, userService, auditService, codeStore)
+	}).Methods(http.MethodPut)
+
+	// POST: 冷静期结束后完成更换手机号
+	accountRouter.HandleFunc("/mobile/complete", func(w http.ResponseWriter, r *http.Request) {
+		controllers.CompleteMobileChange(w, r, userService, auditService, codeStore)
+	}).Methods(http.MethodPost)
+
+	// DELETE: 取消待生效的更换手机号申请
+	accountRouter.HandleFunc("/mobile/pending", func(w http.ResponseWriter, r *http.Request) {
+		controllers.CancelMobileChange(w, r, codeStore)
+	}).Methods(http.MethodDelete)
+}
+
+func ProfileRoutes(r *mux.Router, auth mux.MiddlewareFunc, profileService services.ProfileService, totpService services.TOTPService, auditService services.AuditService, codeStore store.CodeStore) {
+	// 用户资料只能通过登录会话查看和修改
+	meRouter := r.PathPrefix("/me").Subrouter()
+	meRouter.Use(auth, controllers.RequireScope("", ""))
+
+	// GET: 获取用户资料；PATCH: 部分更新用户资料
+	meRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			controllers.GetMe(w, r, profileService, totpService)
+		}
+		if r.Method == http.MethodPatch {
+			controllers.UpdateMe(w, r, profileService, totpService)
+		}
+	}).Methods(http.MethodGet, http.MethodPatch)
+
+	// POST: 开始设置两步验证，返回 otpauth 地址
+	meRouter.HandleFunc("/2fa/enroll", func(w http.ResponseWriter, r *http.Request) {
+		controllers.EnrollTOTP(w, r, totpService)
+	}).Methods(http.MethodPost)
+
+	// POST: 提交动态验证码确认开启两步验证，返回恢复码
+	meRouter.HandleFunc("/2fa/confirm", func(w http.ResponseWriter, r *http.Request) {
+		controllers.ConfirmTOTP(w, r, totpService, auditService)
+	}).Methods(http.MethodPost)
+
+	// POST: 提交动态验证码或恢复码关闭两步验证
+	meRouter.HandleFunc("/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+		controllers.DisableTOTP(w, r, totpService, auditService, codeStore)
+	}).Methods(http.MethodPost)
+}
+
+func ReminderRoutes(r *mux.Router, auth mux.MiddlewareFunc, reminderService services.ReminderService, profileService services.ProfileService, auditService services.AuditService) {
+	// 所有 /reminders 路由都需要先通过鉴权，API Token 需要对应的读写权限
+	reminderRouter := r.PathPrefix("/reminders").Subrouter()
+	reminderRouter.Use(auth, controllers.RequireScope(services.ScopeRemindersRead, services.ScopeRemindersWrite))
+
 	// POST 和 GET 请求的路由处理
-	r.HandleFunc("/reminders", func(w http.ResponseWriter, r *http.Request) {
+	reminderRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
 		// POST: 创建提醒
 		if r.Method == http.MethodPost {
-			controllers.CreateReminder(w, r, userService, reminderService)
+			controllers.CreateReminder(w, r, reminderService, profileService, auditService)
 		}
 		// GET: 获取提醒列表
 		if r.Method == http.MethodGet {
-			controllers.GetReminders(w, r, reminderService)
+			controllers.GetReminders(w, r, reminderService, profileService)
 		}
 	}).Methods(http.MethodPost, http.MethodGet)
 
-	// DELETE 和 PUT 请求的路由处理
-	r.HandleFunc("/reminders/{id}", func(w http.ResponseWriter, r *http.Request) {
+	// GET、DELETE 和 PUT 请求的路由处理
+	reminderRouter.HandleFunc("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// GET: 获取单个提醒
+		if r.Method == http.MethodGet {
+			controllers.GetReminder(w, r, reminderService, profileService)
+		}
 		// DELETE: 删除提醒
 		if r.Method == http.MethodDelete {
-			controllers.DeleteReminder(w, r, reminderService)
+			controllers.DeleteReminder(w, r, reminderService, auditService)
 		}
 		// PUT: 更新提醒
 		if r.Method == http.MethodPut {
-			controllers.UpdateReminder(w, r, reminderService)
+			controllers.UpdateReminder(w, r, reminderService, profileService, auditService)
 		}
-	}).Methods(http.MethodDelete, http.MethodPut)
+	}).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
+
+	// POST: 推迟提醒
+	reminderRouter.HandleFunc("/{id}/snooze", func(w http.ResponseWriter, r *http.Request) {
+		controllers.SnoozeReminder(w, r, reminderService, profileService, auditService)
+	}).Methods(http.MethodPost)
+}
+
+func AdminRoutes(r *mux.Router, auth mux.MiddlewareFunc, userService services.UserService, reminderService services.ReminderService, sessionService services.SessionService, deliveryLogService services.DeliveryLogService, auditService services.AuditService) {
+	// 管理接口只允许管理员通过登录会话访问
+	adminRouter := r.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(auth, controllers.RequireAdmin())
+
+	// GET: 按手机号搜索用户
+	adminRouter.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminSearchUsers(w, r, userService)
+	}).Methods(http.MethodGet)
+
+	// POST: 禁用用户
+	adminRouter.HandleFunc("/users/{creator_id}/disable", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminSetUserDisabled(w, r, userService, sessionService, auditService, true)
+	}).Methods(http.MethodPost)
+
+	// POST: 启用用户
+	adminRouter.HandleFunc("/users/{creator_id}/enable", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminSetUserDisabled(w, r, userService, sessionService, auditService, false)
+	}).Methods(http.MethodPost)
+
+	// GET: 查看用户的提醒
+	adminRouter.HandleFunc("/users/{creator_id}/reminders", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminGetUserReminders(w, r, userService, reminderService)
+	}).Methods(http.MethodGet)
+
+	// GET: 查看用户的提醒投递记录
+	adminRouter.HandleFunc("/users/{creator_id}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminGetUserDeliveries(w, r, userService, deliveryLogService)
+	}).Methods(http.MethodGet)
+
+	// GET: 查看用户的会话；DELETE: 强制用户在所有设备上下线
+	adminRouter.HandleFunc("/users/{creator_id}/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			controllers.AdminGetUserSessions(w, r, userService, sessionService)
+		}
+		if r.Method == http.MethodDelete {
+			controllers.AdminDeleteUserSessions(w, r, userService, sessionService, auditService)
+		}
+	}).Methods(http.MethodGet, http.MethodDelete)
+
+	// DELETE: 强制注销用户的指定会话
+	adminRouter.HandleFunc("/users/{creator_id}/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminDeleteUserSessions(w, r, userService, sessionService, auditService)
+	}).Methods(http.MethodDelete)
+
+	// GET: 查询审计日志
+	adminRouter.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		controllers.GetAuditLogs(w, r, auditService)
+	}).Methods(http.MethodGet)
 }
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("用户不存在")
+
+// AccountService 账号服务接口
+type AccountService interface {
+	DeleteAccount(creatorID string) (*models.DeletionReceipt, error)
+}
+
+// AccountServiceImpl 账号服务实现
+type AccountServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewAccountService 创建 AccountService 实例
+func NewAccountService(db *gorm.DB) AccountService {
+	return &AccountServiceImpl{db: db}
+}
+
+// DeleteAccount 在同一事务中删除用户及其提醒、第三方身份、API Token、用户资料、投递记录和两步验证设置，
+// 把审计日志中该用户的 creator_id 和手机号替换为回执中的哈希，并生成注销回执
+func (s *AccountServiceImpl) DeleteAccount(creatorID string) (*models.DeletionReceipt, error) {
+	receiptID, err := utils.GenerateUniqueID()
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &models.DeletionReceipt{
+		ReceiptID:     receiptID,
+		CreatorIDHash: hashToken(creatorID),
+		DeletedAt:     models.JSONTime{Time: time.Now().Truncate(time.Second)},
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("creator_id = ?", creatorID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		result := tx.Where("creator_id = ?", creatorID).Delete(&models.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		result = tx.Where("creator_id = ?", creatorID).Delete(&models.Reminder{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.RemindersDeleted = result.RowsAffected
+
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.ReminderTag{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.ApiToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.UserProfile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.DeliveryLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.UserTOTP{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		// 审计日志需要保留，但不能再保存明文 creator_id 和手机号，替换为与回执一致的哈希
+		if err := tx.Model(&models.AuditLog{}).Where("actor = ?", creatorID).Update("actor", receipt.CreatorIDHash).Error; err != nil {
+			return err
+		}
+		targets := []string{creatorID}
+		if user.Mobile != "" {
+			targets = append(targets, user.Mobile)
+		}
+		if err := tx.Model(&models.AuditLog{}).Where("target IN ?", targets).Update("target", receipt.CreatorIDHash).Error; err != nil {
+			return err
+		}
+		return tx.Create(receipt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
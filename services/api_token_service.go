@@ -0,0 +1,148 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API Token 的权限范围
+const (
+	ScopeRemindersRead  = "reminders:read"
+	ScopeRemindersWrite = "reminders:write"
+)
+
+// API Token 明文前缀，用于和登录会话 token 区分
+const ApiTokenPrefix = "pat_"
+
+// API Token 最长有效期
+const MaxApiTokenTTL = 365 * 24 * time.Hour
+
+// ErrApiTokenNotFound API Token 不存在或不属于当前用户
+var ErrApiTokenNotFound = errors.New("API Token 不存在")
+
+// ApiTokenService API Token 服务接口
+type ApiTokenService interface {
+	CreateToken(creatorID, name string, scopes []string, ttl time.Duration) (*models.ApiToken, string, error)
+	ListTokens(creatorID string) ([]models.ApiToken, error)
+	RevokeToken(id string, creatorID string) error
+	Authenticate(token string) (*models.ApiToken, error)
+}
+
+// ApiTokenServiceImpl API Token 服务实现
+type ApiTokenServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewApiTokenService 创建 ApiTokenService 实例
+func NewApiTokenService(db *gorm.DB) ApiTokenService {
+	return &ApiTokenServiceImpl{db: db}
+}
+
+// ValidScope 判断权限范围是否合法
+func ValidScope(scope string) bool {
+	return scope == ScopeRemindersRead || scope == ScopeRemindersWrite
+}
+
+// HasScope 判断以逗号分隔的权限列表中是否包含指定权限
+func HasScope(scopes string, scope string) bool {
+	for _, s := range strings.Split(scopes, ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateToken 创建 API Token，返回记录和 token 明文，明文只在创建时返回一次
+func (s *ApiTokenServiceImpl) CreateToken(creatorID, name string, scopes []string, ttl time.Duration) (*models.ApiToken, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, "", errors.New("名称不能为空")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("权限范围不能为空")
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return nil, "", fmt.Errorf("不支持的权限范围: %s", scope)
+		}
+	}
+	if ttl <= 0 || ttl > MaxApiTokenTTL {
+		return nil, "", errors.New("有效期必须在 1 秒到 365 天之间")
+	}
+
+	bytes := make([]byte, 20)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	token := ApiTokenPrefix + hex.EncodeToString(bytes)
+	now := time.Now().Truncate(time.Second)
+
+	apiToken := &models.ApiToken{
+		CreatorID: creatorID,
+		Name:      strings.TrimSpace(name),
+		TokenHash: hashToken(token),
+		Prefix:    token[:len(ApiTokenPrefix)+6],
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: models.JSONTime{Time: now.Add(ttl)},
+		CreatedAt: models.JSONTime{Time: now},
+		UpdatedAt: models.JSONTime{Time: now},
+	}
+	if err := s.db.Create(apiToken).Error; err != nil {
+		return nil, "", err
+	}
+	return apiToken, token, nil
+}
+
+// ListTokens 查询用户的全部 API Token
+func (s *ApiTokenServiceImpl) ListTokens(creatorID string) ([]models.ApiToken, error) {
+	var tokens []models.ApiToken
+	err := s.db.Where("creator_id = ?", creatorID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeToken 吊销 API Token
+func (s *ApiTokenServiceImpl) RevokeToken(id string, creatorID string) error {
+	result := s.db.Where("id = ? AND creator_id = ?", id, creatorID).Delete(&models.ApiToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrApiTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate 校验 API Token，无效或已过期时返回 nil
+func (s *ApiTokenServiceImpl) Authenticate(token string) (*models.ApiToken, error) {
+	if !strings.HasPrefix(token, ApiTokenPrefix) {
+		return nil, nil
+	}
+
+	var apiToken models.ApiToken
+	result := s.db.Where("token_hash = ?", hashToken(token)).First(&apiToken)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	now := time.Now().Truncate(time.Second)
+	if !apiToken.ExpiresAt.Time.After(now) {
+		return nil, nil
+	}
+
+	// 记录最近使用时间，失败不影响本次请求
+	lastUsedAt := models.JSONTime{Time: now}
+	s.db.Model(&models.ApiToken{}).Where("id = ?", apiToken.ID).UpdateColumn("last_used_at", lastUsedAt)
+	apiToken.LastUsedAt = &lastUsedAt
+
+	return &apiToken, nil
+}
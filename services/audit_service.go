@@ -0,0 +1,143 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计操作类型
+const (
+	AuditLogin          = "auth.login"
+	AuditOIDCLogin      = "auth.oidc_login"
+	AuditLogout         = "auth.logout"
+	AuditTwoFactor      = "auth.two_factor"
+	AuditMobileChange   = "account.mobile_change"
+	AuditAccountDelete  = "account.delete"
+	AuditTOTPEnable     = "account.totp_enable"
+	AuditTOTPDisable    = "account.totp_disable"
+	AuditReminderCreate = "reminder.create"
+	AuditReminderUpdate = "reminder.update"
+	AuditReminderDelete = "reminder.delete"
+	AuditReminderSnooze = "reminder.snooze"
+	AuditUserDisable    = "admin.user_disable"
+	AuditUserEnable     = "admin.user_enable"
+	AuditForceLogout    = "admin.force_logout"
+)
+
+// 审计结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// 审计日志分页大小
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditQuery 审计日志查询条件，字段为空表示不过滤
+type AuditQuery struct {
+	Actor    string
+	Action   string
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	Page     int // 从 1 开始
+	PageSize int
+}
+
+// AuditService 审计日志服务接口
+type AuditService interface {
+	Record(entry *models.AuditLog) error
+	Query(query AuditQuery) ([]models.AuditLog, int64, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// AuditServiceImpl 审计日志服务实现
+type AuditServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewAuditService 创建 AuditService 实例
+func NewAuditService(db *gorm.DB) AuditService {
+	return &AuditServiceImpl{db: db}
+}
+
+// Record 写入一条审计日志
+func (s *AuditServiceImpl) Record(entry *models.AuditLog) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = models.JSONTime{Time: time.Now().Truncate(time.Second)}
+	}
+	return s.db.Create(entry).Error
+}
+
+// Query 按条件分页查询审计日志，按时间倒序返回，同时返回符合条件的总数
+func (s *AuditServiceImpl) Query(query AuditQuery) ([]models.AuditLog, int64, error) {
+	db := s.db.Model(&models.AuditLog{})
+	if query.Actor != "" {
+		db = db.Where("actor = ?", query.Actor)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.Outcome != "" {
+		db = db.Where("outcome = ?", query.Outcome)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultAuditPageSize
+	}
+	if pageSize > maxAuditPageSize {
+		pageSize = maxAuditPageSize
+	}
+
+	var entries []models.AuditLog
+	err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error
+	return entries, total, err
+}
+
+// Purge 删除 before 之前的审计日志，返回删除的条数
+func (s *AuditServiceImpl) Purge(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&models.AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// StartAuditRetention 按保留期限定期清理过期的审计日志，retention 为 0 表示永久保留
+func StartAuditRetention(auditService AuditService, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			deleted, err := auditService.Purge(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("清理过期审计日志失败: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("已清理 %d 条过期审计日志", deleted)
+			}
+		}
+	}()
+}
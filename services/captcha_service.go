@@ -0,0 +1,100 @@
+package services
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/models"
+	"calendarReminder-service/ratelimit"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"encoding/json"
+	"time"
+)
+
+// Redis中存储人机验证挑战的Key前缀
+const CAPTCHA_CHALLENGE = "CAPTCHA_CHALLENGE:"
+
+// CaptchaService 人机验证服务接口
+type CaptchaService interface {
+	Issue(ip string) (*models.Captcha, error)
+	Verify(captchaID, nonce, ip string) (bool, error)
+	Required(ip, mobile string) (bool, error)
+}
+
+// CaptchaServiceImpl 工作量证明人机验证实现，挑战保存在验证数据存储中
+type CaptchaServiceImpl struct {
+	codeStore store.CodeStore
+	limiter   ratelimit.RateLimiter
+	cfg       config.CaptchaConfig
+}
+
+// NewCaptchaService 创建 CaptchaService 实例，limiter 用于统计请求次数以评估风险
+func NewCaptchaService(codeStore store.CodeStore, limiter ratelimit.RateLimiter, cfg config.CaptchaConfig) CaptchaService {
+	return &CaptchaServiceImpl{codeStore: codeStore, limiter: limiter, cfg: cfg}
+}
+
+// captchaChallenge 存储中的挑战，绑定请求挑战的客户端 IP
+type captchaChallenge struct {
+	Challenge string `json:"challenge"`
+	IP        string `json:"ip"`
+}
+
+// Issue 为客户端 ip 生成新的挑战并保存，挑战只能由同一 IP 使用
+func (s *CaptchaServiceImpl) Issue(ip string) (*models.Captcha, error) {
+	captchaID, err := utils.GenerateUniqueID()
+	if err != nil {
+		return nil, err
+	}
+	challenge := utils.GenerateUUID()
+	data, _ := json.Marshal(captchaChallenge{Challenge: challenge, IP: ip})
+	if err := s.codeStore.Set(CAPTCHA_CHALLENGE+captchaID, string(data), s.cfg.TTL); err != nil {
+		return nil, err
+	}
+	return &models.Captcha{
+		ID:         captchaID,
+		Challenge:  challenge,
+		Difficulty: s.cfg.Difficulty,
+		ExpiresAt:  models.JSONTime{Time: time.Now().Add(s.cfg.TTL).Truncate(time.Second)},
+	}, nil
+}
+
+// Verify 校验客户端提交的 nonce，挑战必须由同一 IP 提交，无论成功与否都只能使用一次
+func (s *CaptchaServiceImpl) Verify(captchaID, nonce, ip string) (bool, error) {
+	if captchaID == "" || nonce == "" {
+		return false, nil
+	}
+	// 读取的同时删除挑战，防止同一个挑战被并发重复使用
+	data, err := s.codeStore.Take(CAPTCHA_CHALLENGE + captchaID)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var challenge captchaChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return false, err
+	}
+	if challenge.IP != ip {
+		return false, nil
+	}
+	return utils.VerifyProofOfWork(challenge.Challenge, nonce, s.cfg.Difficulty), nil
+}
+
+// Required 判断本次请求是否需要人机验证：同一 IP 或手机号在窗口内的请求次数超过阈值后需要验证
+func (s *CaptchaServiceImpl) Required(ip, mobile string) (bool, error) {
+	if !s.cfg.Enabled {
+		return false, nil
+	}
+	if s.cfg.RiskThreshold <= 0 {
+		return true, nil
+	}
+	rule := config.RateLimitRule{Limit: s.cfg.RiskThreshold, Window: s.cfg.RiskWindow}
+	allowed, _, err := ratelimit.Check(s.limiter,
+		ratelimit.NewRule("captcha:risk:ip", rule, ip),
+		ratelimit.NewRule("captcha:risk:mobile", rule, mobile),
+	)
+	if err != nil {
+		return false, err
+	}
+	return !allowed, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 提醒投递结果
+const (
+	DeliverySent     = "sent"     // 短信发送成功
+	DeliveryFailed   = "failed"   // 短信发送失败
+	DeliverySkipped  = "skipped"  // 用户被禁用、未绑定手机号或关闭了通知，不发送
+	DeliveryDeferred = "deferred" // 处于免打扰时段，推迟发送
+)
+
+// 查询投递记录时最多返回的条数
+const maxDeliveryLogs = 100
+
+// DeliveryLogService 提醒投递记录服务接口
+type DeliveryLogService interface {
+	Record(creatorID, content, mobile, status, reason string) error
+	ListByCreatorID(creatorID string) ([]models.DeliveryLog, error)
+}
+
+// DeliveryLogServiceImpl 提醒投递记录服务实现
+type DeliveryLogServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewDeliveryLogService 创建 DeliveryLogService 实例
+func NewDeliveryLogService(db *gorm.DB) DeliveryLogService {
+	return &DeliveryLogServiceImpl{db: db}
+}
+
+// Record 记录一次投递结果
+func (s *DeliveryLogServiceImpl) Record(creatorID, content, mobile, status, reason string) error {
+	return s.db.Create(&models.DeliveryLog{
+		CreatorID: creatorID,
+		Content:   content,
+		Mobile:    mobile,
+		Status:    status,
+		Reason:    reason,
+		CreatedAt: models.JSONTime{Time: time.Now().Truncate(time.Second)},
+	}).Error
+}
+
+// ListByCreatorID 按时间倒序返回用户最近的投递记录
+func (s *DeliveryLogServiceImpl) ListByCreatorID(creatorID string) ([]models.DeliveryLog, error) {
+	var logs []models.DeliveryLog
+	err := s.db.Where("creator_id = ?", creatorID).Order("id DESC").Limit(maxDeliveryLogs).Find(&logs).Error
+	return logs, err
+}
@@ -4,8 +4,11 @@ import (
 	"calendarReminder-service/models"
 	"calendarReminder-service/utils"
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -15,8 +18,27 @@ type UserService interface {
 	GetUserByMobile(mobile string) (*models.User, error)
 	GetUserByCreatorID(CreatorID string) (*models.User, error)
 	CreateUser(mobile string) (*models.User, error)
+	GetUserByIdentity(issuer, subject string) (*models.User, error)
+	CreateUserWithIdentity(issuer, subject, email string) (*models.User, error)
+	UpdateMobile(creatorID, mobile string) error
+	SearchUsersByMobile(mobile string) ([]models.User, error)
+	MigrateLegacyMobiles() (int, error)
+	GrantAdmins(mobiles, creatorIDs []string) (int, error)
+	SetDisabled(creatorID string, disabled bool) error
 }
 
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// 按手机号搜索用户时最多返回的条数
+const maxSearchUsers = 50
+
+// ErrMobileTaken 手机号已被其他用户使用
+var ErrMobileTaken = errors.New("手机号已被其他账号使用")
+
 // UserServiceImpl 结构体实现 UserService 接口
 type UserServiceImpl struct {
 	db          *gorm.DB
@@ -45,6 +67,7 @@ func (s *UserServiceImpl) CreateUser(mobile string) (*models.User, error) {
 	user := &models.User{
 		Mobile:    mobile,
 		CreatorID: creatorID, // 根据需求生成 CreatorID
+		Role:      RoleUser,
 		CreatedAt: models.JSONTime{Time: now},
 		UpdatedAt: models.JSONTime{Time: now},
 	}
@@ -115,3 +138,180 @@ func (s *UserServiceImpl) GetUserByCreatorID(CreatorID string) (*models.User, er
 	// 返回用户信息的指针
 	return &user, nil
 }
+
+// GetUserByIdentity 根据第三方身份（issuer + subject）查询关联的用户
+func (s *UserServiceImpl) GetUserByIdentity(issuer, subject string) (*models.User, error) {
+	var identity models.UserIdentity
+	result := s.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // 尚未关联任何用户
+		}
+		return nil, result.Error
+	}
+	return s.GetUserByCreatorID(identity.CreatorID)
+}
+
+// CreateUserWithIdentity 通过第三方身份注册新用户，用户和身份关联在同一事务中创建
+func (s *UserServiceImpl) CreateUserWithIdentity(issuer, subject, email string) (*models.User, error) {
+	if issuer == "" || subject == "" {
+		return nil, errors.New("第三方身份不能为空")
+	}
+
+	creatorID, err := s.idGenerator.GenerateUniqueID()
+	if err != nil {
+		return nil, err
+	}
+	now := models.JSONTime{Time: time.Now().Truncate(time.Second)}
+
+	user := &models.User{
+		CreatorID: creatorID,
+		Role:      RoleUser,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	identity := &models.UserIdentity{
+		CreatorID: creatorID,
+		Issuer:    issuer,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateMobile 更换用户绑定的手机号
+func (s *UserServiceImpl) UpdateMobile(creatorID, mobile string) error {
+	if len(mobile) == 0 {
+		return errors.New("手机号码不能为空")
+	}
+
+	exists, err := s.QueryMobileIsExist(mobile)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrMobileTaken
+	}
+
+	result := s.db.Model(&models.User{}).Where("creator_id = ?", creatorID).Updates(map[string]interface{}{
+		"mobile":     mobile,
+		"updated_at": models.JSONTime{Time: time.Now().Truncate(time.Second)},
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SearchUsersByMobile 按手机号搜索用户：以 + 开头时按 E.164 前缀匹配，否则按号码片段匹配；
+// 只使用其中的数字和加号，避免 LIKE 通配符
+func (s *UserServiceImpl) SearchUsersByMobile(mobile string) ([]models.User, error) {
+	keyword := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '+' {
+			return r
+		}
+		return -1
+	}, mobile)
+	if keyword == "" {
+		return []models.User{}, nil
+	}
+
+	pattern := keyword + "%"
+	if !strings.HasPrefix(keyword, "+") {
+		pattern = "%" + keyword + "%"
+	}
+
+	var users []models.User
+	err := s.db.Where("mobile LIKE ?", pattern).Order("id").Limit(maxSearchUsers).Find(&users).Error
+	return users, err
+}
+
+// 旧版本只支持国内手机号，按 13800138000 这样的国内格式保存
+var legacyMobilePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+// MigrateLegacyMobiles 把按旧版国内格式保存的手机号转换为 E.164 格式，返回转换的用户数，启动时执行；
+// 转换后的号码已经被其他账号使用时保留原值并记录日志，需要人工合并这两个账号
+func (s *UserServiceImpl) MigrateLegacyMobiles() (int, error) {
+	var users []models.User
+	if err := s.db.Where("mobile NOT LIKE ?", "+%").Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, user := range users {
+		if !legacyMobilePattern.MatchString(user.Mobile) {
+			continue
+		}
+		mobile := "+86" + user.Mobile
+		if err := s.UpdateMobile(user.CreatorID, mobile); err != nil {
+			if errors.Is(err, ErrMobileTaken) {
+				log.Printf("手机号 %s 已被其他账号使用，用户 %s 保留旧格式手机号，需要人工合并", mobile, user.CreatorID)
+				continue
+			}
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// GrantAdmins 把配置文件中指定手机号或用户ID的用户设为管理员，返回新设为管理员的用户数，启动时执行；
+// 只授予不撤销，已是管理员的用户保持不变，尚未注册的手机号在注册后下次启动时生效
+func (s *UserServiceImpl) GrantAdmins(mobiles, creatorIDs []string) (int, error) {
+	normalized := make([]string, 0, len(mobiles))
+	for _, mobile := range mobiles {
+		m, err := utils.NormalizePhoneNumber(mobile)
+		if err != nil {
+			return 0, fmt.Errorf("管理员手机号 %s 无效: %w", mobile, err)
+		}
+		normalized = append(normalized, m)
+	}
+	if len(normalized) == 0 && len(creatorIDs) == 0 {
+		return 0, nil
+	}
+
+	query := s.db.Model(&models.User{}).Where("role <> ?", RoleAdmin)
+	switch {
+	case len(normalized) > 0 && len(creatorIDs) > 0:
+		query = query.Where("mobile IN ? OR creator_id IN ?", normalized, creatorIDs)
+	case len(normalized) > 0:
+		query = query.Where("mobile IN ?", normalized)
+	default:
+		query = query.Where("creator_id IN ?", creatorIDs)
+	}
+	result := query.Updates(map[string]interface{}{
+		"role":       RoleAdmin,
+		"updated_at": models.JSONTime{Time: time.Now().Truncate(time.Second)},
+	})
+	return int(result.RowsAffected), result.Error
+}
+
+// SetDisabled 禁用或启用用户
+func (s *UserServiceImpl) SetDisabled(creatorID string, disabled bool) error {
+	result := s.db.Model(&models.User{}).Where("creator_id = ?", creatorID).Updates(map[string]interface{}{
+		"disabled":   disabled,
+		"updated_at": models.JSONTime{Time: time.Now().Truncate(time.Second)},
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 用户资料的默认值
+const (
+	DefaultTimezone = "Asia/Shanghai"
+	DefaultLanguage = "zh-CN"
+)
+
+// 通知渠道
+const (
+	ChannelSMS  = "sms"  // 短信通知
+	ChannelNone = "none" // 不发送通知，只在列表中查看提醒
+)
+
+// 显示名称的最大长度（字符数）
+const maxDisplayNameLength = 32
+
+// ErrInvalidProfile 用户资料校验失败
+var ErrInvalidProfile = errors.New("用户资料不合法")
+
+// 语言标签格式，例如 zh、zh-CN、en-US
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// ProfileUpdate 部分更新用户资料，字段为 nil 表示不修改
+type ProfileUpdate struct {
+	DisplayName         *string `json:"display_name"`
+	Timezone            *string `json:"timezone"`
+	Language            *string `json:"language"`
+	QuietHoursStart     *string `json:"quiet_hours_start"`
+	QuietHoursEnd       *string `json:"quiet_hours_end"`
+	NotificationChannel *string `json:"notification_channel"`
+}
+
+// ProfileService 用户资料服务接口
+type ProfileService interface {
+	GetProfile(creatorID string) (*models.UserProfile, error)
+	UpdateProfile(creatorID string, update *ProfileUpdate) (*models.UserProfile, error)
+}
+
+// ProfileServiceImpl 用户资料服务实现
+type ProfileServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewProfileService 创建 ProfileService 实例
+func NewProfileService(db *gorm.DB) ProfileService {
+	return &ProfileServiceImpl{db: db}
+}
+
+// GetProfile 获取用户资料，尚未设置过资料的用户返回默认值
+func (s *ProfileServiceImpl) GetProfile(creatorID string) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := s.db.Where("creator_id = ?", creatorID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultProfile(creatorID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// UpdateProfile 校验并保存用户资料，第一次保存时创建记录
+func (s *ProfileServiceImpl) UpdateProfile(creatorID string, update *ProfileUpdate) (*models.UserProfile, error) {
+	profile, err := s.GetProfile(creatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.DisplayName != nil {
+		profile.DisplayName = *update.DisplayName
+	}
+	if update.Timezone != nil {
+		profile.Timezone = *update.Timezone
+	}
+	if update.Language != nil {
+		profile.Language = *update.Language
+	}
+	if update.QuietHoursStart != nil {
+		profile.QuietHoursStart = *update.QuietHoursStart
+	}
+	if update.QuietHoursEnd != nil {
+		profile.QuietHoursEnd = *update.QuietHoursEnd
+	}
+	if update.NotificationChannel != nil {
+		profile.NotificationChannel = *update.NotificationChannel
+	}
+	if err := validateProfile(profile); err != nil {
+		return nil, err
+	}
+
+	now := models.JSONTime{Time: time.Now().Truncate(time.Second)}
+	if profile.ID == 0 {
+		profile.CreatedAt = now
+	}
+	profile.UpdatedAt = now
+	if err := s.db.Save(profile).Error; err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// ProfileLocation 返回用户资料中的时区，时区无效时使用默认时区
+func ProfileLocation(profile *models.UserProfile) *time.Location {
+	if loc, err := time.LoadLocation(profile.Timezone); err == nil && profile.Timezone != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// QuietHoursEnd 判断 t 是否处于用户的免打扰时段，是则返回免打扰结束的时间
+func QuietHoursEnd(profile *models.UserProfile, t time.Time) (time.Time, bool) {
+	start, okStart := parseClock(profile.QuietHoursStart)
+	end, okEnd := parseClock(profile.QuietHoursEnd)
+	if !okStart || !okEnd || start == end {
+		return t, false
+	}
+
+	loc := ProfileLocation(profile)
+	local := t.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if start < end {
+		quiet = minutes >= start && minutes < end
+	} else {
+		// 跨过零点，例如 22:00 - 07:00
+		quiet = minutes >= start || minutes < end
+	}
+	if !quiet {
+		return t, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+func defaultProfile(creatorID string) *models.UserProfile {
+	return &models.UserProfile{
+		CreatorID:           creatorID,
+		Timezone:            DefaultTimezone,
+		Language:            DefaultLanguage,
+		NotificationChannel: ChannelSMS,
+	}
+}
+
+func validateProfile(profile *models.UserProfile) error {
+	if utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("%w: 显示名称不能超过 %d 个字符", ErrInvalidProfile, maxDisplayNameLength)
+	}
+	// time.LoadLocation 会把空字符串解析为 UTC、把 Local 解析为进程时区，这两种都不是 IANA 时区
+	if profile.Timezone == "" || profile.Timezone == "Local" {
+		return fmt.Errorf("%w: 时区不能为空", ErrInvalidProfile)
+	}
+	if _, err := time.LoadLocation(profile.Timezone); err != nil {
+		return fmt.Errorf("%w: 无效的时区 %s", ErrInvalidProfile, profile.Timezone)
+	}
+	if !languagePattern.MatchString(profile.Language) {
+		return fmt.Errorf("%w: 无效的语言 %s", ErrInvalidProfile, profile.Language)
+	}
+	if (profile.QuietHoursStart == "") != (profile.QuietHoursEnd == "") {
+		return fmt.Errorf("%w: 免打扰开始和结束时间需要同时设置", ErrInvalidProfile)
+	}
+	if profile.QuietHoursStart != "" {
+		start, okStart := parseClock(profile.QuietHoursStart)
+		end, okEnd := parseClock(profile.QuietHoursEnd)
+		if !okStart || !okEnd {
+			return fmt.Errorf("%w: 免打扰时间格式应为 HH:MM", ErrInvalidProfile)
+		}
+		if start == end {
+			return fmt.Errorf("%w: 免打扰开始和结束时间不能相同", ErrInvalidProfile)
+		}
+	}
+	if profile.NotificationChannel != ChannelSMS && profile.NotificationChannel != ChannelNone {
+		return fmt.Errorf("%w: 不支持的通知渠道 %s", ErrInvalidProfile, profile.NotificationChannel)
+	}
+	return nil
+}
+
+// parseClock 把 HH:MM 解析为当天的分钟数
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -2,13 +2,131 @@ package services
 
 import (
 	"calendarReminder-service/models"
+	"calendarReminder-service/utils"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// 提醒投递状态。重复提醒安排好下一次提醒后回到 scheduled，sent_at 和 last_error 保留上一次的结果
+const (
+	ReminderScheduled = "scheduled" // 已发布到延迟队列，等待到期
+	ReminderSending   = "sending"   // 已到期，正在发送短信
+	ReminderSent      = "sent"      // 短信发送成功
+	ReminderFailed    = "failed"    // 短信发送失败或无法发布到队列
+	ReminderCancelled = "cancelled" // 用户被禁用、未绑定手机号或关闭了通知，不再发送
+)
+
+// IsValidReminderStatus 判断是否是合法的提醒投递状态
+func IsValidReminderStatus(status string) bool {
+	switch status {
+	case ReminderScheduled, ReminderSending, ReminderSent, ReminderFailed, ReminderCancelled:
+		return true
+	}
+	return false
+}
+
+var (
+	// ErrReminderConflict 更新提醒时提醒的版本已被其他请求修改
+	ErrReminderConflict = errors.New("提醒已被修改")
+	// ErrReminderNotFound 提醒不存在或不属于当前用户
+	ErrReminderNotFound = errors.New("提醒不存在")
+	// ErrInvalidCursor 分页游标无效或与排序方式不匹配
+	ErrInvalidCursor = errors.New("分页游标无效")
+	// ErrExDateWithoutRRule 单次提醒设置了排除日期
+	ErrExDateWithoutRRule = errors.New("只有重复提醒可以设置排除日期")
+	// ErrRecurrenceStartTooEarly 重复提醒的起始时间早于 maxRecurrenceHistoryYears 年前
+	ErrRecurrenceStartTooEarly = fmt.Errorf("重复提醒的起始时间不能早于 %d 年前", maxRecurrenceHistoryYears)
+)
+
+// 重复提醒的起始时间最多早于当前时间的年数；计算下一次提醒时从起始时间逐个周期向后推算，起始时间过早时计算量过大
+const maxRecurrenceHistoryYears = 10
+
+// 提醒列表的排序方式，- 开头表示倒序
+const (
+	SortRemindAtAsc   = "remind_at"
+	SortRemindAtDesc  = "-remind_at"
+	SortCreatedAtAsc  = "created_at"
+	SortCreatedAtDesc = "-created_at"
+)
+
+// 提醒列表分页大小
+const (
+	defaultReminderPageSize = 20
+	maxReminderPageSize     = 100
+)
+
+// 提醒标签的数量和长度限制
+const (
+	maxReminderTags   = 10
+	maxReminderTagLen = 32
+)
+
+// ReminderQuery 提醒列表查询条件，字段为空表示不过滤
+type ReminderQuery struct {
+	Statuses []string  // 投递状态，满足任意一个即可
+	From     time.Time // remind_at 不早于该时间
+	To       time.Time // remind_at 早于该时间
+	Tags     []string  // 标签，带有任意一个即可
+	Keyword  string    // 提醒内容包含的关键字
+	Sort     string    // 排序方式，默认按 remind_at 正序
+	Cursor   string    // 上一页返回的 next_cursor，为空表示第一页
+	Limit    int
+}
+
+// ReminderPage 一页提醒，Total 为符合筛选条件的总数，NextCursor 为空表示没有下一页
+type ReminderPage struct {
+	Items      []models.Reminder `json:"items"`
+	Total      int64             `json:"total"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// IsValidReminderSort 判断是否是支持的排序方式
+func IsValidReminderSort(sort string) bool {
+	switch sort {
+	case SortRemindAtAsc, SortRemindAtDesc, SortCreatedAtAsc, SortCreatedAtDesc:
+		return true
+	}
+	return false
+}
+
+// NormalizeTags 去掉标签首尾空白并去重，校验标签数量和长度
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxReminderTagLen || strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("标签不能超过 %d 个字符，且不能包含逗号: %s", maxReminderTagLen, tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxReminderTags {
+		return nil, fmt.Errorf("每个提醒最多 %d 个标签", maxReminderTags)
+	}
+	return normalized, nil
+}
+
 // ReminderService 提醒服务接口
 type ReminderService interface {
 	CreateReminder(reminder *models.Reminder) error
-	GetRemindersByCreatorID(creatorID string) ([]models.Reminder, error)
+	ListReminders(creatorID string, query ReminderQuery) (*ReminderPage, error)
+	GetReminder(id string, creatorID string) (*models.Reminder, error)
+	GetReminderByID(id uint) (*models.Reminder, error)
+	UpdateReminderStatus(id uint, status string, lastError string) error
+	SnoozeReminder(reminder *models.Reminder, until time.Time) error
 	DeleteReminder(id string, creatorID string) error
 	UpdateReminder(id string, reminder *models.Reminder, creatorID string) error
 }
@@ -23,24 +141,337 @@ func NewReminderService(db *gorm.DB) ReminderService {
 	return &ReminderServiceImpl{db: db}
 }
 
-// CreateReminder 创建提醒
+// CreateReminder 创建提醒和提醒的标签
 func (s *ReminderServiceImpl) CreateReminder(reminder *models.Reminder) error {
-	return s.db.Create(reminder).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(reminder).Error; err != nil {
+			return err
+		}
+		return saveTags(tx, reminder.ID, reminder.CreatorID, reminder.Tags)
+	})
 }
 
-// GetRemindersByCreatorID 获取指定用户的提醒列表
-func (s *ReminderServiceImpl) GetRemindersByCreatorID(creatorID string) ([]models.Reminder, error) {
+// ListReminders 按条件分页查询用户的提醒，使用游标分页，同时返回符合条件的总数
+func (s *ReminderServiceImpl) ListReminders(creatorID string, query ReminderQuery) (*ReminderPage, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = SortRemindAtAsc
+	}
+	if !IsValidReminderSort(sort) {
+		return nil, fmt.Errorf("不支持的排序方式: %s", sort)
+	}
+	column, desc := strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+
+	// 筛选条件，统计总数和查询当前页各用一次
+	filtered := func() *gorm.DB {
+		db := s.db.Model(&models.Reminder{}).Where("creator_id = ?", creatorID)
+		if len(query.Statuses) > 0 {
+			db = db.Where("status IN ?", query.Statuses)
+		}
+		if !query.From.IsZero() {
+			db = db.Where("remind_at >= ?", query.From)
+		}
+		if !query.To.IsZero() {
+			db = db.Where("remind_at < ?", query.To)
+		}
+		if len(query.Tags) > 0 {
+			tagged := s.db.Model(&models.ReminderTag{}).Select("reminder_id").Where("creator_id = ? AND tag IN ?", creatorID, query.Tags)
+			db = db.Where("id IN (?)", tagged)
+		}
+		if query.Keyword != "" {
+			db = db.Where("content LIKE ? ESCAPE '!'", "%"+escapeLike(query.Keyword)+"%")
+		}
+		return db
+	}
+
+	var total int64
+	if err := filtered().Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit < 1 {
+		limit = defaultReminderPageSize
+	}
+	if limit > maxReminderPageSize {
+		limit = maxReminderPageSize
+	}
+
+	// 按排序字段和 ID 定位上一页的最后一条，ID 保证排序字段相同时顺序稳定
+	db := filtered()
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+	if query.Cursor != "" {
+		after, afterID, err := decodeCursor(query.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op), after, after, afterID)
+	}
+
 	var reminders []models.Reminder
-	err := s.db.Where("creator_id = ?", creatorID).Find(&reminders).Error
-	return reminders, err
+	if err := db.Order(column + " " + dir).Order("id " + dir).Limit(limit + 1).Find(&reminders).Error; err != nil {
+		return nil, err
+	}
+
+	page := &ReminderPage{Items: reminders, Total: total}
+	if len(reminders) > limit {
+		page.Items = reminders[:limit]
+		last := page.Items[limit-1]
+		sortValue := last.RemindAt.Time
+		if column == "created_at" {
+			sortValue = last.CreatedAt.Time
+		}
+		page.NextCursor = encodeCursor(sort, sortValue, last.ID)
+	}
+	if err := s.loadTags(page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetReminder 获取属于 creatorID 的提醒，不存在或属于其他用户时返回 ErrReminderNotFound
+func (s *ReminderServiceImpl) GetReminder(id string, creatorID string) (*models.Reminder, error) {
+	var reminder models.Reminder
+	if err := s.db.Where("id = ? AND creator_id = ?", id, creatorID).First(&reminder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReminderNotFound
+		}
+		return nil, err
+	}
+	reminders := []models.Reminder{reminder}
+	if err := s.loadTags(reminders); err != nil {
+		return nil, err
+	}
+	return &reminders[0], nil
+}
+
+// GetReminderByID 按 ID 获取提醒，供投递流程使用，不存在时返回 nil
+func (s *ReminderServiceImpl) GetReminderByID(id uint) (*models.Reminder, error) {
+	var reminder models.Reminder
+	if err := s.db.First(&reminder, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	reminders := []models.Reminder{reminder}
+	if err := s.loadTags(reminders); err != nil {
+		return nil, err
+	}
+	return &reminders[0], nil
+}
+
+// UpdateReminderStatus 更新提醒的投递状态：进入 sending 时累加尝试次数，sent 时记录发送时间并清空失败原因，
+// failed、cancelled 时记录原因，scheduled 保留上一次的结果
+func (s *ReminderServiceImpl) UpdateReminderStatus(id uint, status string, lastError string) error {
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case ReminderSending:
+		updates["attempts"] = gorm.Expr("attempts + 1")
+	case ReminderSent:
+		updates["sent_at"] = models.JSONTime{Time: time.Now().Truncate(time.Second)}
+		updates["last_error"] = ""
+	case ReminderFailed, ReminderCancelled:
+		updates["last_error"] = lastError
+	}
+	return s.db.Model(&models.Reminder{}).Where("id = ?", id).Updates(updates).Error
 }
 
-// DeleteReminder 删除提醒
+// SnoozeReminder 把提醒推迟到 until：累加推迟次数、记录推迟到的时间并回到 scheduled。
+// 单次提醒同时提升版本号，使队列中原来的消息失效；重复提醒保持版本号，后续的重复提醒照常发送。
+// 成功后更新 reminder 中对应的字段，提醒已被其他请求修改时返回 ErrReminderConflict
+func (s *ReminderServiceImpl) SnoozeReminder(reminder *models.Reminder, until time.Time) error {
+	version := reminder.Version
+	if reminder.RRule == "" {
+		version++
+	}
+	snoozedUntil := models.JSONTime{Time: until.Truncate(time.Second)}
+	result := s.db.Model(&models.Reminder{}).
+		Where("id = ? AND version = ? AND snooze_count = ?", reminder.ID, reminder.Version, reminder.SnoozeCount).
+		Updates(map[string]interface{}{
+			"version":       version,
+			"snooze_count":  reminder.SnoozeCount + 1,
+			"snoozed_until": snoozedUntil,
+			"status":        ReminderScheduled,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReminderConflict
+	}
+
+	reminder.Version = version
+	reminder.SnoozeCount++
+	reminder.SnoozedUntil = &snoozedUntil
+	reminder.Status = ReminderScheduled
+	return nil
+}
+
+// DeleteReminder 删除提醒和提醒的标签，提醒不存在或属于其他用户时返回 ErrReminderNotFound
 func (s *ReminderServiceImpl) DeleteReminder(id string, creatorID string) error {
-	return s.db.Where("id = ? AND creator_id = ?", id, creatorID).Delete(&models.Reminder{}).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND creator_id = ?", id, creatorID).Delete(&models.Reminder{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReminderNotFound
+		}
+		return tx.Where("reminder_id = ? AND creator_id = ?", id, creatorID).Delete(&models.ReminderTag{}).Error
+	})
 }
 
-// UpdateReminder 更新提醒
+// UpdateReminder 更新提醒，内容、提醒时间和重复规则按 reminder 中的值整体写入（重复规则为空表示改为单次提醒），
+// 调用方需要先与原提醒合并；reminder.Tags 不为 nil 时替换提醒的标签，提醒不存在或属于其他用户时返回 ErrReminderNotFound。
+// reminder 带有新的版本号时，只在数据库中的版本仍是上一个版本时更新，防止并发更新发布两条相同版本的消息，版本已变化时返回 ErrReminderConflict
 func (s *ReminderServiceImpl) UpdateReminder(id string, reminder *models.Reminder, creatorID string) error {
-	return s.db.Model(&models.Reminder{}).Where("id = ? AND creator_id = ?", id, creatorID).Updates(reminder).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Reminder{}).Where("id = ? AND creator_id = ?", id, creatorID)
+		if reminder.Version > 1 {
+			query = query.Where("version = ?", reminder.Version-1)
+		}
+		// 显式列出要写入的列，结构体更新会跳过零值，否则无法清空重复规则
+		reminder.UpdatedAt = models.JSONTime{Time: time.Now().Truncate(time.Second)}
+		columns := []string{"content", "remind_at", "rrule", "exdate", "updated_at"}
+		if reminder.Version > 0 {
+			columns = append(columns, "version")
+		}
+		if reminder.Status != "" {
+			columns = append(columns, "status")
+		}
+		result := query.Select(columns).Updates(reminder)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// MySQL 在更新前后的值相同时也返回 0，需要确认提醒是否存在
+			var count int64
+			if err := tx.Model(&models.Reminder{}).Where("id = ? AND creator_id = ?", id, creatorID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrReminderNotFound
+			}
+			if reminder.Version > 1 {
+				return ErrReminderConflict
+			}
+		}
+		if reminder.Tags == nil {
+			return nil
+		}
+
+		reminderID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("reminder_id = ? AND creator_id = ?", reminderID, creatorID).Delete(&models.ReminderTag{}).Error; err != nil {
+			return err
+		}
+		return saveTags(tx, uint(reminderID), creatorID, reminder.Tags)
+	})
+}
+
+// saveTags 保存提醒的标签
+func saveTags(tx *gorm.DB, reminderID uint, creatorID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]models.ReminderTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, models.ReminderTag{ReminderID: reminderID, CreatorID: creatorID, Tag: tag})
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadTags 批量查询并填充提醒的标签，没有标签的提醒返回空数组
+func (s *ReminderServiceImpl) loadTags(reminders []models.Reminder) error {
+	if len(reminders) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(reminders))
+	for _, reminder := range reminders {
+		ids = append(ids, reminder.ID)
+	}
+	var rows []models.ReminderTag
+	if err := s.db.Where("reminder_id IN ?", ids).Order("id").Find(&rows).Error; err != nil {
+		return err
+	}
+	tags := map[uint][]string{}
+	for _, row := range rows {
+		tags[row.ReminderID] = append(tags[row.ReminderID], row.Tag)
+	}
+	for i := range reminders {
+		reminders[i].Tags = tags[reminders[i].ID]
+		if reminders[i].Tags == nil {
+			reminders[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// escapeLike 转义 LIKE 中的通配符，与 ESCAPE '!' 一起使用
+func escapeLike(keyword string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(keyword)
+}
+
+// encodeCursor 把排序方式、上一页最后一条的排序字段和 ID 编码为游标
+func encodeCursor(sort string, value time.Time, id uint) string {
+	raw := fmt.Sprintf("%s|%d|%d", sort, value.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor 解析游标，游标的排序方式与本次查询不一致时返回 ErrInvalidCursor
+func decodeCursor(cursor, sort string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || parts[0] != sort {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos), uint(id), nil
+}
+
+// ValidateRecurrence 创建和修改提醒时校验重复设置：排除日期只用于重复提醒，重复提醒的起始时间不能过早
+func ValidateRecurrence(reminder *models.Reminder, now time.Time) error {
+	if reminder.RRule == "" {
+		if reminder.ExDate != "" {
+			return ErrExDateWithoutRRule
+		}
+		return nil
+	}
+	if reminder.RemindAt.Before(now.AddDate(-maxRecurrenceHistoryYears, 0, 0)) {
+		return ErrRecurrenceStartTooEarly
+	}
+	return nil
+}
+
+// NextOccurrence 返回提醒在 after 之后的下一次提醒时间，没有下一次时返回 false。
+// 单次提醒就是 remind_at；重复提醒以 remind_at 为起点，按用户时区 loc 计算重复规则并跳过排除日期
+func NextOccurrence(reminder *models.Reminder, loc *time.Location, after time.Time) (time.Time, bool, error) {
+	if reminder.RRule == "" {
+		return reminder.RemindAt.Time, reminder.RemindAt.After(after), nil
+	}
+	rule, err := utils.ParseRRule(reminder.RRule, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if err := rule.ParseExDates(reminder.ExDate, loc); err != nil {
+		return time.Time{}, false, err
+	}
+	next, ok := rule.Next(reminder.RemindAt.In(loc), after)
+	return next, ok, nil
 }
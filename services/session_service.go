@@ -0,0 +1,157 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// 会话有效期
+const SessionTTL = 24 * time.Hour
+
+// 会话最近活跃时间的记录精度，两次请求间隔小于该值时不更新
+const lastSeenInterval = time.Minute
+
+// ErrSessionNotFound 会话不存在或不属于当前用户
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// SessionService 会话服务接口
+type SessionService interface {
+	CreateSession(creatorID, device, ip, userAgent string) (*models.Session, string, error)
+	ValidateToken(token string) (*models.Session, error)
+	ListSessions(creatorID string) ([]models.Session, error)
+	RefreshSession(creatorID, sessionID string) (*models.Session, error)
+	RevokeSession(creatorID, sessionID string) error
+	RevokeAllSessions(creatorID string) error
+}
+
+// SessionServiceImpl 会话服务实现，会话保存在 Redis 或内存中
+type SessionServiceImpl struct {
+	sessionStore store.SessionStore
+}
+
+// NewSessionService 创建 SessionService 实例
+func NewSessionService(sessionStore store.SessionStore) SessionService {
+	return &SessionServiceImpl{sessionStore: sessionStore}
+}
+
+// hashToken 对 token 做 SHA-256，存储中不保存 token 明文
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession 为用户创建新会话，返回会话信息和 token
+func (s *SessionServiceImpl) CreateSession(creatorID, device, ip, userAgent string) (*models.Session, string, error) {
+	sessionID, err := utils.GenerateUniqueID()
+	if err != nil {
+		return nil, "", err
+	}
+	token := utils.GenerateUUID()
+	now := models.JSONTime{Time: time.Now().Truncate(time.Second)}
+
+	record := store.SessionRecord{
+		Session: models.Session{
+			ID:         sessionID,
+			CreatorID:  creatorID,
+			Device:     device,
+			IP:         ip,
+			UserAgent:  userAgent,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  models.JSONTime{Time: now.Add(SessionTTL)},
+		},
+		TokenHash: hashToken(token),
+	}
+	if err := s.sessionStore.Create(&record, SessionTTL); err != nil {
+		return nil, "", err
+	}
+
+	return &record.Session, token, nil
+}
+
+// ValidateToken 校验 token 并返回对应会话，token 无效时返回 nil
+func (s *SessionServiceImpl) ValidateToken(token string) (*models.Session, error) {
+	if token == "" {
+		return nil, nil
+	}
+	record, err := s.sessionStore.GetByTokenHash(hashToken(token))
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	// 更新最近活跃时间，保留原有过期时间；距离上次记录不足 lastSeenInterval 时不写入，避免每个请求都写存储
+	now := time.Now().Truncate(time.Second)
+	if now.Sub(record.LastSeenAt.Time) >= lastSeenInterval {
+		record.LastSeenAt = models.JSONTime{Time: now}
+		if err := s.sessionStore.Update(record); err != nil {
+			log.Printf("更新会话活跃时间失败: %v", err)
+		}
+	}
+
+	return &record.Session, nil
+}
+
+// ListSessions 查询用户的所有有效会话
+func (s *SessionServiceImpl) ListSessions(creatorID string) ([]models.Session, error) {
+	records, err := s.sessionStore.ListByCreatorID(creatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, record.Session)
+	}
+	return sessions, nil
+}
+
+// RefreshSession 把用户指定会话的过期时间顺延为 SessionTTL 之后
+func (s *SessionServiceImpl) RefreshSession(creatorID, sessionID string) (*models.Session, error) {
+	record, err := s.sessionStore.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.CreatorID != creatorID {
+		return nil, ErrSessionNotFound
+	}
+
+	now := time.Now().Truncate(time.Second)
+	record.LastSeenAt = models.JSONTime{Time: now}
+	record.ExpiresAt = models.JSONTime{Time: now.Add(SessionTTL)}
+	if err := s.sessionStore.Refresh(record, SessionTTL); err != nil {
+		return nil, err
+	}
+	return &record.Session, nil
+}
+
+// RevokeSession 注销用户的指定会话
+func (s *SessionServiceImpl) RevokeSession(creatorID, sessionID string) error {
+	record, err := s.sessionStore.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if record == nil || record.CreatorID != creatorID {
+		return ErrSessionNotFound
+	}
+	return s.sessionStore.Delete(record)
+}
+
+// RevokeAllSessions 注销用户的全部会话，即“在所有设备上退出登录”
+func (s *SessionServiceImpl) RevokeAllSessions(creatorID string) error {
+	records, err := s.sessionStore.ListByCreatorID(creatorID)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if err := s.sessionStore.Delete(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
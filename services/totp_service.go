@@ -0,0 +1,222 @@
+package services
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 确认两步验证时生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	// ErrTOTPAlreadyEnabled 已经开启两步验证
+	ErrTOTPAlreadyEnabled = errors.New("已经开启两步验证")
+	// ErrTOTPNotEnrolled 尚未开始设置两步验证
+	ErrTOTPNotEnrolled = errors.New("尚未设置两步验证")
+	// ErrTOTPInvalidCode 动态验证码或恢复码错误
+	ErrTOTPInvalidCode = errors.New("动态验证码错误")
+)
+
+// TOTPService 两步验证服务接口
+type TOTPService interface {
+	Enroll(creatorID, account string) (secret string, uri string, err error)
+	Confirm(creatorID, code string) ([]string, error)
+	Enabled(creatorID string) (bool, error)
+	Verify(creatorID, code string) (bool, error)
+	Disable(creatorID, code string) error
+}
+
+// TOTPServiceImpl 两步验证服务实现
+type TOTPServiceImpl struct {
+	db     *gorm.DB
+	issuer string // 身份验证器 App 中显示的服务名称
+}
+
+// NewTOTPService 创建 TOTPService 实例
+func NewTOTPService(db *gorm.DB, issuer string) TOTPService {
+	return &TOTPServiceImpl{db: db, issuer: issuer}
+}
+
+// Enroll 生成新的密钥，返回供身份验证器 App 扫码的 otpauth 地址；确认前不会生效
+func (s *TOTPServiceImpl) Enroll(creatorID, account string) (string, string, error) {
+	totp, err := s.get(creatorID)
+	if err != nil {
+		return "", "", err
+	}
+	if totp != nil && totp.Confirmed {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	now := models.JSONTime{Time: time.Now().Truncate(time.Second)}
+	if totp == nil {
+		totp = &models.UserTOTP{CreatorID: creatorID, CreatedAt: now}
+	}
+	totp.Secret = secret
+	totp.LastUsedStep = 0
+	totp.UpdatedAt = now
+	if err := s.db.Save(totp).Error; err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPURI(s.issuer, account, secret), nil
+}
+
+// Confirm 用动态验证码确认设置，开启两步验证并返回一次性恢复码（只返回这一次）
+func (s *TOTPServiceImpl) Confirm(creatorID, code string) ([]string, error) {
+	totp, err := s.get(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if totp.Confirmed {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	ok, err := s.useTOTPCode(totp, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTOTPInvalidCode
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	now := models.JSONTime{Time: time.Now().Truncate(time.Second)}
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			CreatorID: creatorID,
+			CodeHash:  hashToken(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserTOTP{}).Where("id = ?", totp.ID).Updates(map[string]interface{}{
+			"confirmed":  true,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Enabled 判断用户是否已开启两步验证
+func (s *TOTPServiceImpl) Enabled(creatorID string) (bool, error) {
+	totp, err := s.get(creatorID)
+	if err != nil {
+		return false, err
+	}
+	return totp != nil && totp.Confirmed, nil
+}
+
+// Verify 校验动态验证码或恢复码；恢复码使用后作废
+func (s *TOTPServiceImpl) Verify(creatorID, code string) (bool, error) {
+	totp, err := s.get(creatorID)
+	if err != nil {
+		return false, err
+	}
+	if totp == nil || !totp.Confirmed {
+		return false, ErrTOTPNotEnrolled
+	}
+
+	ok, err := s.useTOTPCode(totp, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	return s.useRecoveryCode(creatorID, code)
+}
+
+// Disable 校验动态验证码或恢复码后关闭两步验证，并删除全部恢复码
+func (s *TOTPServiceImpl) Disable(creatorID, code string) error {
+	ok, err := s.Verify(creatorID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTOTPInvalidCode
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("creator_id = ?", creatorID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("creator_id = ?", creatorID).Delete(&models.UserTOTP{}).Error
+	})
+}
+
+func (s *TOTPServiceImpl) get(creatorID string) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+	err := s.db.Where("creator_id = ?", creatorID).First(&totp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// useTOTPCode 校验动态验证码，并要求时间步大于上一次使用的时间步，防止验证码被重放
+func (s *TOTPServiceImpl) useTOTPCode(totp *models.UserTOTP, code string) (bool, error) {
+	step, ok := utils.VerifyTOTP(totp.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+	result := s.db.Model(&models.UserTOTP{}).
+		Where("id = ? AND last_used_step < ?", totp.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// useRecoveryCode 校验并作废一个未使用的恢复码
+func (s *TOTPServiceImpl) useRecoveryCode(creatorID, code string) (bool, error) {
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("creator_id = ? AND code_hash = ? AND used_at IS NULL", creatorID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", models.JSONTime{Time: time.Now().Truncate(time.Second)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// generateRecoveryCode 生成 xxxxx-xxxxx 格式的恢复码
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 5)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(bytes)
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和横线
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -9,8 +9,10 @@ DROP TABLE IF EXISTS users;
 CREATE TABLE users
 (
     id         INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识用户的ID',
-    mobile     VARCHAR(20)  NOT NULL UNIQUE COMMENT '用户手机号，国内格式',
+    mobile     VARCHAR(20)  NULL UNIQUE COMMENT '用户手机号，E.164 格式（例如 +8613800138000）；通过第三方登录注册的用户可以为空',
     creator_id VARCHAR(128) NOT NULL UNIQUE COMMENT '用户唯一标识，用于关联提醒信息',
+    role       VARCHAR(16)  NOT NULL DEFAULT 'user' COMMENT '角色：user 或 admin',
+    disabled   TINYINT(1)   NOT NULL DEFAULT 0 COMMENT '是否被管理员禁用',
     created_at DATETIME NOT NULL COMMENT '用户创建时间',
     updated_at DATETIME NOT NULL COMMENT '用户信息最后更新时间'
 ) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -23,8 +25,165 @@ CREATE TABLE reminders
     id         INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识提醒信息的ID',
     creator_id VARCHAR(128) NOT NULL COMMENT '提醒信息创建者的ID',
     content    TEXT         NOT NULL COMMENT '提醒内容',
-    remind_at  DATETIME     NOT NULL COMMENT '提醒的具体时间', -- 将 TIMESTAMP 改为 DATETIME
+    remind_at  DATETIME     NOT NULL COMMENT '提醒的具体时间，重复提醒时为重复规则的起始时间', -- 将 TIMESTAMP 改为 DATETIME
+    rrule      VARCHAR(1024) NULL COMMENT 'RFC 5545 重复规则，为空表示单次提醒',
+    exdate     VARCHAR(1024) NULL COMMENT 'RFC 5545 EXDATE 排除日期，逗号分隔，只用于重复提醒',
+    version    INT          NOT NULL DEFAULT 1 COMMENT '提醒的版本，队列中版本不一致的消息不会发送',
+    status     VARCHAR(16)  NOT NULL DEFAULT 'scheduled' COMMENT '投递状态：scheduled、sending、sent、failed 或 cancelled',
+    sent_at    DATETIME     NULL COMMENT '最近一次发送成功的时间',
+    attempts   INT          NOT NULL DEFAULT 0 COMMENT '累计尝试发送的次数',
+    last_error TEXT         NULL COMMENT '最近一次发送失败或取消的原因',
+    snooze_count  INT       NOT NULL DEFAULT 0 COMMENT '累计推迟的次数',
+    snoozed_until DATETIME  NULL COMMENT '最近一次推迟到的时间',
     created_at DATETIME     NOT NULL  COMMENT '提醒信息创建时间', -- 改为 DATETIME
     updated_at DATETIME     NOT NULL  COMMENT '提醒信息最后更新时间', -- 改为 DATETIME
-    INDEX      idx_creator_id (creator_id(20)) -- 只索引前 20 个字符
-) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
\ No newline at end of file
+    -- 列表查询总是按 creator_id 过滤，复合索引分别支持按提醒时间、投递状态和创建时间筛选排序
+    INDEX      idx_reminders_creator_remind_at (creator_id, remind_at),
+    INDEX      idx_reminders_creator_status (creator_id, status, remind_at),
+    INDEX      idx_reminders_creator_created_at (creator_id, created_at)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 reminder_tags 表，如果存在
+DROP TABLE IF EXISTS reminder_tags;
+-- 创建 reminder_tags 表
+CREATE TABLE reminder_tags
+(
+    id          INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识标签记录的ID',
+    reminder_id INT          NOT NULL COMMENT '标签所属的提醒ID',
+    creator_id  VARCHAR(128) NOT NULL COMMENT '提醒创建者的ID',
+    tag         VARCHAR(32)  NOT NULL COMMENT '标签',
+    INDEX       idx_reminder_tags_reminder_id (reminder_id),
+    INDEX       idx_reminder_tags_creator_tag (creator_id, tag)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 api_tokens 表，如果存在
+DROP TABLE IF EXISTS api_tokens;
+-- 创建 api_tokens 表
+CREATE TABLE api_tokens
+(
+    id           INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识 API Token 的ID',
+    creator_id   VARCHAR(128) NOT NULL COMMENT 'API Token 所属用户的ID',
+    name         VARCHAR(64)  NOT NULL COMMENT 'API Token 名称',
+    token_hash   CHAR(64)     NOT NULL UNIQUE COMMENT 'API Token 的 SHA-256 哈希',
+    prefix       VARCHAR(16)  NOT NULL COMMENT 'API Token 前几位，便于辨认',
+    scopes       VARCHAR(255) NOT NULL COMMENT '逗号分隔的权限范围',
+    expires_at   DATETIME     NOT NULL COMMENT '过期时间',
+    last_used_at DATETIME NULL COMMENT '最近使用时间',
+    created_at   DATETIME     NOT NULL COMMENT '创建时间',
+    updated_at   DATETIME     NOT NULL COMMENT '最后更新时间',
+    INDEX        idx_creator_id (creator_id(20))
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+
+-- 删除 user_identities 表，如果存在
+DROP TABLE IF EXISTS user_identities;
+-- 创建 user_identities 表
+CREATE TABLE user_identities
+(
+    id         INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识第三方身份的ID',
+    creator_id VARCHAR(128) NOT NULL COMMENT '关联的用户ID',
+    issuer     VARCHAR(255) NOT NULL COMMENT '身份提供方 issuer',
+    subject    VARCHAR(255) NOT NULL COMMENT '身份提供方中的用户标识 sub',
+    email      VARCHAR(255) NULL COMMENT '身份提供方返回的邮箱',
+    created_at DATETIME     NOT NULL COMMENT '创建时间',
+    updated_at DATETIME     NOT NULL COMMENT '最后更新时间',
+    UNIQUE INDEX idx_issuer_subject (issuer, subject),
+    INDEX        idx_creator_id (creator_id(20))
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 deletion_receipts 表，如果存在
+DROP TABLE IF EXISTS deletion_receipts;
+-- 创建 deletion_receipts 表
+CREATE TABLE deletion_receipts
+(
+    id                INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识注销回执的ID',
+    receipt_id        VARCHAR(32) NOT NULL UNIQUE COMMENT '回执编号',
+    creator_id_hash   CHAR(64)    NOT NULL COMMENT '已注销用户 creator_id 的 SHA-256',
+    reminders_deleted BIGINT      NOT NULL DEFAULT 0 COMMENT '删除的提醒数量',
+    deleted_at        DATETIME    NOT NULL COMMENT '注销时间',
+    INDEX             idx_creator_id_hash (creator_id_hash)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 user_profiles 表，如果存在
+DROP TABLE IF EXISTS user_profiles;
+-- 创建 user_profiles 表
+CREATE TABLE user_profiles
+(
+    id                   INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识用户资料的ID',
+    creator_id           VARCHAR(128) NOT NULL UNIQUE COMMENT '关联的用户ID',
+    display_name         VARCHAR(64)  NULL COMMENT '显示名称',
+    timezone             VARCHAR(64)  NOT NULL DEFAULT 'Asia/Shanghai' COMMENT 'IANA 时区',
+    language             VARCHAR(35)  NULL COMMENT '语言标签',
+    quiet_hours_start    CHAR(5)      NULL COMMENT '免打扰开始时间 HH:MM',
+    quiet_hours_end      CHAR(5)      NULL COMMENT '免打扰结束时间 HH:MM',
+    notification_channel VARCHAR(16)  NOT NULL DEFAULT 'sms' COMMENT '通知渠道：sms 或 none',
+    created_at           DATETIME     NOT NULL COMMENT '创建时间',
+    updated_at           DATETIME     NOT NULL COMMENT '最后更新时间'
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 delivery_logs 表，如果存在
+DROP TABLE IF EXISTS delivery_logs;
+-- 创建 delivery_logs 表
+CREATE TABLE delivery_logs
+(
+    id         INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识投递记录的ID',
+    creator_id VARCHAR(128) NOT NULL COMMENT '提醒所属用户ID',
+    content    TEXT         NULL COMMENT '提醒内容',
+    mobile     VARCHAR(20)  NULL COMMENT '接收短信的手机号',
+    status     VARCHAR(16)  NOT NULL COMMENT '投递结果：sent、failed、skipped 或 deferred',
+    reason     VARCHAR(255) NULL COMMENT '失败原因或未发送的原因',
+    created_at DATETIME     NOT NULL COMMENT '投递时间',
+    INDEX      idx_creator_id (creator_id(20))
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 设置管理员：在 config.yaml 的 admin.mobiles 或 admin.creator-ids 中配置，服务启动时自动授予（管理接口不提供授予管理员的入口）
+
+-- 删除 audit_logs 表，如果存在
+DROP TABLE IF EXISTS audit_logs;
+-- 创建 audit_logs 表
+CREATE TABLE audit_logs
+(
+    id         BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识审计日志的ID',
+    actor      VARCHAR(128) NULL COMMENT '操作者的 creator_id，未登录时为空',
+    action     VARCHAR(64)  NOT NULL COMMENT '操作类型，例如 auth.login',
+    target     VARCHAR(255) NULL COMMENT '操作对象',
+    ip         VARCHAR(64)  NULL COMMENT '客户端 IP',
+    user_agent VARCHAR(512) NULL COMMENT '客户端 User-Agent',
+    outcome    VARCHAR(16)  NOT NULL COMMENT '结果：success 或 failure',
+    detail     VARCHAR(512) NULL COMMENT '补充说明',
+    created_at DATETIME     NOT NULL COMMENT '发生时间',
+    INDEX      idx_actor (actor(20)),
+    INDEX      idx_action (action),
+    INDEX      idx_created_at (created_at)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 已有数据迁移：服务启动时会自动把按国内格式保存的手机号转换为 E.164 格式（UserService.MigrateLegacyMobiles），
+-- 转换后与其他账号冲突的号码保留原值并记录日志；也可以在部署前手动执行：
+-- UPDATE users SET mobile = CONCAT('+86', mobile) WHERE mobile REGEXP '^1[3-9][0-9]{9}$';
+
+-- 删除 user_totps 表，如果存在
+DROP TABLE IF EXISTS user_totps;
+-- 创建 user_totps 表
+CREATE TABLE user_totps
+(
+    id             INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识两步验证配置的ID',
+    creator_id     VARCHAR(128) NOT NULL UNIQUE COMMENT '关联的用户ID',
+    secret         VARCHAR(64)  NOT NULL COMMENT 'TOTP 密钥（Base32）',
+    confirmed      TINYINT(1)   NOT NULL DEFAULT 0 COMMENT '是否已用动态验证码确认开启',
+    last_used_step BIGINT       NOT NULL DEFAULT 0 COMMENT '最近一次通过校验的时间步，防止重放',
+    created_at     DATETIME     NOT NULL COMMENT '创建时间',
+    updated_at     DATETIME     NOT NULL COMMENT '最后更新时间'
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- 删除 recovery_codes 表，如果存在
+DROP TABLE IF EXISTS recovery_codes;
+-- 创建 recovery_codes 表
+CREATE TABLE recovery_codes
+(
+    id         INT PRIMARY KEY AUTO_INCREMENT COMMENT '唯一标识恢复码的ID',
+    creator_id VARCHAR(128) NOT NULL COMMENT '关联的用户ID',
+    code_hash  CHAR(64)     NOT NULL COMMENT '恢复码的 SHA-256',
+    used_at    DATETIME     NULL COMMENT '使用时间，未使用时为空',
+    created_at DATETIME     NOT NULL COMMENT '创建时间',
+    INDEX      idx_creator_id (creator_id(20))
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
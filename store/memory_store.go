@@ -0,0 +1,288 @@
+package store
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 每处理多少次操作清理一次过期数据
+const memorySweepInterval = 1000
+
+// memoryValue 内存中的一条验证数据
+type memoryValue struct {
+	value     string
+	expiresAt time.Time // 零值表示没有过期时间
+}
+
+func (v memoryValue) expired(now time.Time) bool {
+	return !v.expiresAt.IsZero() && !now.Before(v.expiresAt)
+}
+
+// expiresAt 根据 ttl 计算过期时间，ttl 不大于 0 表示没有过期时间
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// MemoryCodeStore 进程内的验证数据存储，适用于不使用 Redis 的单机部署和测试
+type MemoryCodeStore struct {
+	mu     sync.Mutex
+	values map[string]memoryValue
+	calls  int
+}
+
+// NewMemoryCodeStore 创建内存验证数据存储
+func NewMemoryCodeStore() *MemoryCodeStore {
+	return &MemoryCodeStore{values: make(map[string]memoryValue)}
+}
+
+// Set 实现 CodeStore 接口
+func (s *MemoryCodeStore) Set(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+	s.values[key] = memoryValue{value: value, expiresAt: expiresAt(now, ttl)}
+	return nil
+}
+
+// Get 实现 CodeStore 接口
+func (s *MemoryCodeStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.get(key, s.tick())
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v.value, nil
+}
+
+// Take 实现 CodeStore 接口
+func (s *MemoryCodeStore) Take(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.get(key, s.tick())
+	if !ok {
+		return "", ErrNotFound
+	}
+	delete(s.values, key)
+	return v.value, nil
+}
+
+// DeleteIfEqual 实现 CodeStore 接口
+func (s *MemoryCodeStore) DeleteIfEqual(key, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.get(key, s.tick())
+	if !ok || subtle.ConstantTimeCompare([]byte(v.value), []byte(value)) != 1 {
+		return false, nil
+	}
+	delete(s.values, key)
+	return true, nil
+}
+
+// Delete 实现 CodeStore 接口
+func (s *MemoryCodeStore) Delete(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+// Incr 实现 CodeStore 接口
+func (s *MemoryCodeStore) Incr(key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+
+	v, ok := s.get(key, now)
+	if !ok {
+		v = memoryValue{value: "0", expiresAt: expiresAt(now, ttl)}
+	}
+	count, err := strconv.ParseInt(v.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	v.value = strconv.FormatInt(count, 10)
+	s.values[key] = v
+	return count, nil
+}
+
+// TTL 实现 CodeStore 接口
+func (s *MemoryCodeStore) TTL(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+	v, ok := s.get(key, now)
+	if !ok {
+		return 0, nil
+	}
+	if v.expiresAt.IsZero() {
+		return -1, nil
+	}
+	return v.expiresAt.Sub(now), nil
+}
+
+// get 读取未过期的数据，已过期的顺便删除；调用方需持有锁
+func (s *MemoryCodeStore) get(key string, now time.Time) (memoryValue, bool) {
+	v, ok := s.values[key]
+	if !ok {
+		return memoryValue{}, false
+	}
+	if v.expired(now) {
+		delete(s.values, key)
+		return memoryValue{}, false
+	}
+	return v, true
+}
+
+// tick 记录一次操作，定期清理过期数据避免内存无限增长；调用方需持有锁
+func (s *MemoryCodeStore) tick() time.Time {
+	now := time.Now()
+	s.calls++
+	if s.calls%memorySweepInterval == 0 {
+		for key, v := range s.values {
+			if v.expired(now) {
+				delete(s.values, key)
+			}
+		}
+	}
+	return now
+}
+
+// memorySession 内存中的一条会话记录
+type memorySession struct {
+	record    SessionRecord
+	expiresAt time.Time
+}
+
+// MemorySessionStore 进程内的会话存储，适用于不使用 Redis 的单机部署和测试，服务重启后会话全部失效
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*memorySession // 会话ID -> 会话
+	tokens   map[string]string         // token 哈希 -> 会话ID
+	calls    int
+}
+
+// NewMemorySessionStore 创建内存会话存储
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*memorySession),
+		tokens:   make(map[string]string),
+	}
+}
+
+// Create 实现 SessionStore 接口
+func (s *MemorySessionStore) Create(record *SessionRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+	s.sessions[record.ID] = &memorySession{record: *record, expiresAt: expiresAt(now, ttl)}
+	s.tokens[record.TokenHash] = record.ID
+	return nil
+}
+
+// Get 实现 SessionStore 接口
+func (s *MemorySessionStore) Get(sessionID string) (*SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.get(sessionID, s.tick())
+	if session == nil {
+		return nil, nil
+	}
+	record := session.record
+	return &record, nil
+}
+
+// GetByTokenHash 实现 SessionStore 接口
+func (s *MemorySessionStore) GetByTokenHash(tokenHash string) (*SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.get(s.tokens[tokenHash], s.tick())
+	if session == nil {
+		return nil, nil
+	}
+	record := session.record
+	return &record, nil
+}
+
+// Update 实现 SessionStore 接口
+func (s *MemorySessionStore) Update(record *SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session := s.get(record.ID, s.tick()); session != nil {
+		session.record = *record
+	}
+	return nil
+}
+
+// Refresh 实现 SessionStore 接口
+func (s *MemorySessionStore) Refresh(record *SessionRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+	if session := s.get(record.ID, now); session != nil {
+		session.record = *record
+		session.expiresAt = expiresAt(now, ttl)
+	}
+	return nil
+}
+
+// ListByCreatorID 实现 SessionStore 接口
+func (s *MemorySessionStore) ListByCreatorID(creatorID string) ([]SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.tick()
+	records := make([]SessionRecord, 0)
+	for sessionID, session := range s.sessions {
+		if session.record.CreatorID != creatorID {
+			continue
+		}
+		if s.get(sessionID, now) != nil {
+			records = append(records, session.record)
+		}
+	}
+	return records, nil
+}
+
+// Delete 实现 SessionStore 接口
+func (s *MemorySessionStore) Delete(record *SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tick()
+	delete(s.sessions, record.ID)
+	delete(s.tokens, record.TokenHash)
+	return nil
+}
+
+// get 读取未过期的会话，已过期的顺便删除；调用方需持有锁
+func (s *MemorySessionStore) get(sessionID string, now time.Time) *memorySession {
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	if !session.expiresAt.IsZero() && !now.Before(session.expiresAt) {
+		delete(s.sessions, sessionID)
+		delete(s.tokens, session.record.TokenHash)
+		return nil
+	}
+	return session
+}
+
+// tick 记录一次操作，定期清理过期会话避免内存无限增长；调用方需持有锁
+func (s *MemorySessionStore) tick() time.Time {
+	now := time.Now()
+	s.calls++
+	if s.calls%memorySweepInterval == 0 {
+		for sessionID := range s.sessions {
+			s.get(sessionID, now)
+		}
+	}
+	return now
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"calendarReminder-service/config"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis中 token 哈希到会话ID的映射Key前缀
+const REDIS_USER_TOKEN = "REDIS_USER_TOKEN:"
+
+// Redis中存储会话详情的Key前缀
+const REDIS_SESSION = "REDIS_SESSION:"
+
+// Redis中存储用户全部会话ID集合的Key前缀
+const REDIS_USER_SESSIONS = "REDIS_USER_SESSIONS:"
+
+// RedisCodeStore 基于 Redis 的验证数据存储，多实例部署时共享
+type RedisCodeStore struct {
+	redisClient *redis.Client
+}
+
+// NewRedisCodeStore 创建 Redis 验证数据存储
+func NewRedisCodeStore(redisClient *redis.Client) *RedisCodeStore {
+	return &RedisCodeStore{redisClient: redisClient}
+}
+
+// Set 实现 CodeStore 接口
+func (s *RedisCodeStore) Set(key, value string, ttl time.Duration) error {
+	return s.redisClient.Set(config.Ctx, key, value, ttl).Err()
+}
+
+// Get 实现 CodeStore 接口
+func (s *RedisCodeStore) Get(key string) (string, error) {
+	value, err := s.redisClient.Get(config.Ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// Take 实现 CodeStore 接口，在事务中读取并删除，防止同一个值被并发重复使用
+func (s *RedisCodeStore) Take(key string) (string, error) {
+	var getCmd *redis.StringCmd
+	_, err := s.redisClient.TxPipelined(config.Ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(config.Ctx, key)
+		pipe.Del(config.Ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return getCmd.Val(), nil
+}
+
+// 比较并删除脚本：值一致时删除 key，返回删除的个数
+var deleteIfEqualScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfEqual 实现 CodeStore 接口，通过脚本保证比较和删除的原子性
+func (s *RedisCodeStore) DeleteIfEqual(key, value string) (bool, error) {
+	deleted, err := deleteIfEqualScript.Run(config.Ctx, s.redisClient, []string{key}, value).Int64()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// Delete 实现 CodeStore 接口
+func (s *RedisCodeStore) Delete(keys ...string) error {
+	return s.redisClient.Del(config.Ctx, keys...).Err()
+}
+
+// 计数脚本：计数加一，第一次计数时在同一个脚本中设置过期时间，避免进程在两步之间退出后计数永不过期
+var incrScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Incr 实现 CodeStore 接口，计数和设置过期时间是原子的
+func (s *RedisCodeStore) Incr(key string, ttl time.Duration) (int64, error) {
+	return incrScript.Run(config.Ctx, s.redisClient, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+// TTL 实现 CodeStore 接口
+func (s *RedisCodeStore) TTL(key string) (time.Duration, error) {
+	ttl, err := s.redisClient.TTL(config.Ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Redis 用 -2 表示 key 不存在，-1 表示没有过期时间
+	if ttl == -2 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RedisSessionStore 基于 Redis 的会话存储，多实例部署时共享
+type RedisSessionStore struct {
+	redisClient *redis.Client
+}
+
+// NewRedisSessionStore 创建 Redis 会话存储
+func NewRedisSessionStore(redisClient *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{redisClient: redisClient}
+}
+
+// Create 实现 SessionStore 接口，会话详情、token 映射和用户会话集合一起写入
+func (s *RedisSessionStore) Create(record *SessionRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.redisClient.TxPipelined(config.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(config.Ctx, REDIS_SESSION+record.ID, data, ttl)
+		pipe.Set(config.Ctx, REDIS_USER_TOKEN+record.TokenHash, record.ID, ttl)
+		pipe.SAdd(config.Ctx, REDIS_USER_SESSIONS+record.CreatorID, record.ID)
+		return nil
+	})
+	return err
+}
+
+// Get 实现 SessionStore 接口
+func (s *RedisSessionStore) Get(sessionID string) (*SessionRecord, error) {
+	data, err := s.redisClient.Get(config.Ctx, REDIS_SESSION+sessionID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record SessionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetByTokenHash 实现 SessionStore 接口
+func (s *RedisSessionStore) GetByTokenHash(tokenHash string) (*SessionRecord, error) {
+	sessionID, err := s.redisClient.Get(config.Ctx, REDIS_USER_TOKEN+tokenHash).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(sessionID)
+}
+
+// Update 实现 SessionStore 接口，使用 SET XX KEEPTTL，与登出或注销并发时不会重新创建已删除的会话
+func (s *RedisSessionStore) Update(record *SessionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.SetXX(config.Ctx, REDIS_SESSION+record.ID, data, redis.KeepTTL).Err()
+}
+
+// Refresh 实现 SessionStore 接口，会话详情和 token 映射一起顺延，会话已删除时不会重新创建
+func (s *RedisSessionStore) Refresh(record *SessionRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.redisClient.TxPipelined(config.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetXX(config.Ctx, REDIS_SESSION+record.ID, data, ttl)
+		pipe.Expire(config.Ctx, REDIS_USER_TOKEN+record.TokenHash, ttl)
+		return nil
+	})
+	return err
+}
+
+// ListByCreatorID 实现 SessionStore 接口，顺便清理集合中已过期的会话ID
+func (s *RedisSessionStore) ListByCreatorID(creatorID string) ([]SessionRecord, error) {
+	sessionIDs, err := s.redisClient.SMembers(config.Ctx, REDIS_USER_SESSIONS+creatorID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]SessionRecord, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		record, err := s.Get(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			s.redisClient.SRem(config.Ctx, REDIS_USER_SESSIONS+creatorID, sessionID)
+			continue
+		}
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+// Delete 实现 SessionStore 接口，删除会话详情、token 映射及集合中的会话ID
+func (s *RedisSessionStore) Delete(record *SessionRecord) error {
+	_, err := s.redisClient.TxPipelined(config.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(config.Ctx, REDIS_SESSION+record.ID, REDIS_USER_TOKEN+record.TokenHash)
+		pipe.SRem(config.Ctx, REDIS_USER_SESSIONS+record.CreatorID, record.ID)
+		return nil
+	})
+	return err
+}
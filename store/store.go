@@ -0,0 +1,76 @@
+package store
+
+import (
+	"calendarReminder-service/models"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNotFound key 不存在或已过期
+var ErrNotFound = errors.New("记录不存在或已过期")
+
+// CodeStore 短期验证数据的存储，例如短信验证码、错误次数、锁定状态和一次性登录凭证，所有数据都有过期时间
+type CodeStore interface {
+	// Set 写入 key，ttl 后过期
+	Set(key, value string, ttl time.Duration) error
+	// Get 读取 key，不存在时返回 ErrNotFound
+	Get(key string) (string, error)
+	// Take 读取并删除 key，保证同一个值只能被取走一次，不存在时返回 ErrNotFound
+	Take(key string) (string, error)
+	// DeleteIfEqual 仅当 key 当前的值等于 value 时删除，返回是否删除；比较和删除是原子的，
+	// 并发请求中只有一个能删除成功
+	DeleteIfEqual(key, value string) (bool, error)
+	// Delete 删除 key，不存在的 key 会被忽略
+	Delete(keys ...string) error
+	// Incr 计数加一并返回新值，key 不存在时从 0 开始并设置 ttl 后过期
+	Incr(key string, ttl time.Duration) (int64, error)
+	// TTL 返回 key 的剩余有效期，key 不存在时返回 0，没有过期时间时返回 -1
+	TTL(key string) (time.Duration, error)
+}
+
+// SessionRecord 存储中的会话记录，额外记录 token 哈希以便按 token 查找和注销时清理
+type SessionRecord struct {
+	models.Session
+	TokenHash string `json:"token_hash"`
+}
+
+// SessionStore 登录会话的存储
+type SessionStore interface {
+	// Create 保存新会话，ttl 后过期
+	Create(record *SessionRecord, ttl time.Duration) error
+	// Get 按会话ID读取会话，不存在时返回 nil
+	Get(sessionID string) (*SessionRecord, error)
+	// GetByTokenHash 按 token 哈希读取会话，不存在时返回 nil
+	GetByTokenHash(tokenHash string) (*SessionRecord, error)
+	// Update 保存会话的修改，保留原有过期时间；会话已被删除时不写入
+	Update(record *SessionRecord) error
+	// Refresh 保存会话的修改，并把过期时间重置为 ttl 之后；会话已被删除时不写入
+	Refresh(record *SessionRecord, ttl time.Duration) error
+	// ListByCreatorID 查询用户的全部有效会话
+	ListByCreatorID(creatorID string) ([]SessionRecord, error)
+	// Delete 删除会话，会话已不存在时不报错
+	Delete(record *SessionRecord) error
+}
+
+// NewCodeStore 根据配置的后端类型创建验证数据存储，redis 不可用时使用内存实现
+func NewCodeStore(backend string, redisClient *redis.Client) CodeStore {
+	if backend == "memory" || redisClient == nil {
+		log.Println("使用内存验证数据存储")
+		return NewMemoryCodeStore()
+	}
+	log.Println("使用 Redis 验证数据存储")
+	return NewRedisCodeStore(redisClient)
+}
+
+// NewSessionStore 根据配置的后端类型创建会话存储，redis 不可用时使用内存实现
+func NewSessionStore(backend string, redisClient *redis.Client) SessionStore {
+	if backend == "memory" || redisClient == nil {
+		log.Println("使用内存会话存储")
+		return NewMemorySessionStore()
+	}
+	log.Println("使用 Redis 会话存储")
+	return NewRedisSessionStore(redisClient)
+}
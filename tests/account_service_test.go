@@ -0,0 +1,88 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试注销账号：删除用户、提醒、第三方身份和 API Token，审计日志中的明文身份替换为哈希，并生成回执
+func TestDeleteAccount(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Reminder{}, &models.ReminderTag{}, &models.UserIdentity{}, &models.ApiToken{}, &models.DeletionReceipt{}, &models.UserProfile{}, &models.DeliveryLog{}, &models.UserTOTP{}, &models.RecoveryCode{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+
+	userService := services.NewUserService(db, &SequenceIDGenerator{})
+	reminderService := services.NewReminderService(db)
+	apiTokenService := services.NewApiTokenService(db)
+	accountService := services.NewAccountService(db)
+
+	user, err := userService.CreateUser("13800138000")
+	assert.NoError(t, err)
+	other, err := userService.CreateUser("13900139000")
+	assert.NoError(t, err)
+
+	for _, creatorID := range []string{user.CreatorID, user.CreatorID, other.CreatorID} {
+		err := reminderService.CreateReminder(&models.Reminder{
+			CreatorID: creatorID,
+			Content:   "测试提醒",
+			RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+		})
+		assert.NoError(t, err)
+	}
+	_, _, err = apiTokenService.CreateToken(user.CreatorID, "脚本", []string{services.ScopeRemindersRead}, time.Hour)
+	assert.NoError(t, err)
+
+	auditService := services.NewAuditService(db)
+	assert.NoError(t, auditService.Record(&models.AuditLog{Actor: user.CreatorID, Action: services.AuditLogin, Target: user.Mobile, Outcome: services.AuditSuccess}))
+	assert.NoError(t, auditService.Record(&models.AuditLog{Actor: "admin", Action: services.AuditUserDisable, Target: user.CreatorID, Outcome: services.AuditSuccess}))
+	assert.NoError(t, auditService.Record(&models.AuditLog{Actor: other.CreatorID, Action: services.AuditLogin, Target: other.Mobile, Outcome: services.AuditSuccess}))
+
+	receipt, err := accountService.DeleteAccount(user.CreatorID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, receipt) {
+		assert.NotEmpty(t, receipt.ReceiptID)
+		assert.Equal(t, int64(2), receipt.RemindersDeleted)
+		assert.NotContains(t, receipt.CreatorIDHash, user.CreatorID, "回执中不应保存明文 creator_id")
+	}
+
+	// 审计日志保留，但该用户的 creator_id 和手机号替换为回执中的哈希，其他用户的审计日志不变
+	var logs []models.AuditLog
+	assert.NoError(t, db.Order("id").Find(&logs).Error)
+	if assert.Len(t, logs, 3) {
+		assert.Equal(t, receipt.CreatorIDHash, logs[0].Actor)
+		assert.Equal(t, receipt.CreatorIDHash, logs[0].Target)
+		assert.Equal(t, "admin", logs[1].Actor)
+		assert.Equal(t, receipt.CreatorIDHash, logs[1].Target)
+		assert.Equal(t, other.CreatorID, logs[2].Actor)
+		assert.Equal(t, other.Mobile, logs[2].Target)
+	}
+
+	// 用户及其数据已删除，其他用户不受影响
+	deleted, err := userService.GetUserByCreatorID(user.CreatorID)
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
+	reminders, _ := reminderService.ListReminders(user.CreatorID, services.ReminderQuery{})
+	assert.Len(t, reminders.Items, 0)
+	tokens, _ := apiTokenService.ListTokens(user.CreatorID)
+	assert.Len(t, tokens, 0)
+	reminders, _ = reminderService.ListReminders(other.CreatorID, services.ReminderQuery{})
+	assert.Len(t, reminders.Items, 1)
+
+	// 注销后手机号可以重新注册
+	exists, _ := userService.QueryMobileIsExist("13800138000")
+	assert.False(t, exists)
+
+	// 重复注销
+	_, err = accountService.DeleteAccount(user.CreatorID)
+	assert.Equal(t, services.ErrUserNotFound, err)
+}
@@ -0,0 +1,87 @@
+package tests__test
+
+import (
+	"calendarReminder-service/controllers"
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试按手机号搜索用户以及禁用、启用用户
+func TestSearchAndDisableUsers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.DeliveryLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	userService := services.NewUserService(db, &SequenceIDGenerator{})
+	deliveryLogService := services.NewDeliveryLogService(db)
+
+	user, err := userService.CreateUser("13800138000")
+	assert.NoError(t, err)
+	assert.Equal(t, services.RoleUser, user.Role)
+	_, err = userService.CreateUser("13800139000")
+	assert.NoError(t, err)
+	_, err = userService.CreateUser("13900138000")
+	assert.NoError(t, err)
+
+	users, err := userService.SearchUsersByMobile("138001")
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	// LIKE 通配符会被忽略
+	users, err = userService.SearchUsersByMobile("%")
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	assert.NoError(t, userService.SetDisabled(user.CreatorID, true))
+	disabled, err := userService.GetUserByCreatorID(user.CreatorID)
+	assert.NoError(t, err)
+	assert.True(t, disabled.Disabled)
+	assert.NoError(t, userService.SetDisabled(user.CreatorID, false))
+	enabled, err := userService.GetUserByCreatorID(user.CreatorID)
+	assert.NoError(t, err)
+	assert.False(t, enabled.Disabled)
+	assert.Equal(t, services.ErrUserNotFound, userService.SetDisabled("not-exist", true))
+
+	assert.NoError(t, deliveryLogService.Record(user.CreatorID, "会议提醒", user.Mobile, services.DeliverySent, ""))
+	assert.NoError(t, deliveryLogService.Record(user.CreatorID, "运动提醒", user.Mobile, services.DeliveryFailed, "网关超时"))
+	deliveries, err := deliveryLogService.ListByCreatorID(user.CreatorID)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, services.DeliveryFailed, deliveries[0].Status, "最新的投递记录排在前面")
+	}
+}
+
+// 测试管理员中间件：只有管理员通过登录会话才能访问
+func TestRequireAdmin(t *testing.T) {
+	handler := controllers.RequireAdmin()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		role string
+		want int
+	}{
+		{services.RoleAdmin, http.StatusOK},
+		{services.RoleUser, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req = req.WithContext(controllers.WithCurrentUser(req.Context(), &models.User{CreatorID: "C1", Role: tt.role}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, tt.want, rec.Code, "角色: %s", tt.role)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/users", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
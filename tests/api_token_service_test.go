@@ -0,0 +1,85 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestApiTokenService 测试 API Token 的创建、校验、查询和吊销
+func TestApiTokenService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ApiToken{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	service := services.NewApiTokenService(db)
+
+	// 创建 token，明文只返回一次，数据库中只保存哈希
+	apiToken, token, err := service.CreateToken("test_user", "脚本", []string{services.ScopeRemindersRead}, time.Hour)
+	assert.NoError(t, err, "创建 API Token 失败")
+	assert.True(t, strings.HasPrefix(token, services.ApiTokenPrefix))
+	assert.NotContains(t, apiToken.TokenHash, token)
+
+	// 校验 token
+	authed, err := service.Authenticate(token)
+	assert.NoError(t, err)
+	if assert.NotNil(t, authed, "期望 API Token 校验通过") {
+		assert.Equal(t, "test_user", authed.CreatorID)
+		assert.True(t, services.HasScope(authed.Scopes, services.ScopeRemindersRead))
+		assert.False(t, services.HasScope(authed.Scopes, services.ScopeRemindersWrite))
+		assert.NotNil(t, authed.LastUsedAt)
+	}
+
+	// 错误的 token 无法通过校验
+	authed, err = service.Authenticate(services.ApiTokenPrefix + "invalid")
+	assert.NoError(t, err)
+	assert.Nil(t, authed)
+
+	// 非法参数
+	_, _, err = service.CreateToken("test_user", "脚本", []string{"admin"}, time.Hour)
+	assert.Error(t, err, "不支持的权限范围应该报错")
+	_, _, err = service.CreateToken("test_user", "脚本", []string{services.ScopeRemindersRead}, 400*24*time.Hour)
+	assert.Error(t, err, "超过最长有效期应该报错")
+
+	// 其他用户无法吊销
+	tokens, err := service.ListTokens("test_user")
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, services.ErrApiTokenNotFound, service.RevokeToken("1", "other_user"))
+
+	// 吊销后无法再使用
+	assert.NoError(t, service.RevokeToken("1", "test_user"))
+	authed, err = service.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Nil(t, authed, "吊销后的 API Token 不应通过校验")
+}
+
+// 测试过期的 API Token
+func TestApiTokenExpired(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ApiToken{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	service := services.NewApiTokenService(db)
+
+	apiToken, token, err := service.CreateToken("test_user", "脚本", []string{services.ScopeRemindersWrite}, time.Hour)
+	assert.NoError(t, err)
+
+	// 将过期时间改到过去
+	db.Model(apiToken).Update("expires_at", models.JSONTime{Time: time.Now().Add(-time.Minute)})
+
+	authed, err := service.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Nil(t, authed, "过期的 API Token 不应通过校验")
+}
@@ -0,0 +1,62 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试审计日志的记录、过滤分页查询和过期清理
+func TestAuditService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	auditService := services.NewAuditService(db)
+
+	now := time.Now().Truncate(time.Second)
+	entries := []*models.AuditLog{
+		{Actor: "C1", Action: services.AuditLogin, Outcome: services.AuditSuccess, CreatedAt: models.JSONTime{Time: now.Add(-200 * 24 * time.Hour)}},
+		{Actor: "", Action: services.AuditLogin, Target: "13800138000", Outcome: services.AuditFailure, Detail: "验证码错误"},
+		{Actor: "C1", Action: services.AuditLogin, Outcome: services.AuditSuccess},
+		{Actor: "C1", Action: services.AuditReminderUpdate, Target: "7", Outcome: services.AuditSuccess},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, auditService.Record(entry))
+	}
+
+	logs, total, err := auditService.Query(services.AuditQuery{Action: services.AuditLogin, Outcome: services.AuditFailure})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "13800138000", logs[0].Target)
+	}
+
+	// 分页：总数不受分页影响，按时间倒序返回
+	logs, total, err = auditService.Query(services.AuditQuery{Actor: "C1", Page: 1, PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, services.AuditReminderUpdate, logs[0].Action)
+	}
+
+	logs, _, err = auditService.Query(services.AuditQuery{Since: now.Add(-time.Hour)})
+	assert.NoError(t, err)
+	assert.Len(t, logs, 3)
+
+	// 超过保留期限的日志被清理
+	deleted, err := auditService.Purge(now.Add(-180 * 24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, total, err = auditService.Query(services.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+}
@@ -0,0 +1,82 @@
+package tests__test
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/controllers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试 CSRF 防护：同源、允许的来源、跨站来源、读请求和 Authorization 头
+func TestCSRFMiddleware(t *testing.T) {
+	handler := controllers.CSRFMiddleware(config.CSRFConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://app.example.com/"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"同源请求", http.MethodPost, map[string]string{"Origin": "http://api.example.com"}, http.StatusOK},
+		{"允许的来源", http.MethodDelete, map[string]string{"Origin": "https://app.example.com"}, http.StatusOK},
+		{"跨站来源", http.MethodPost, map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"跨站 Referer", http.MethodPut, map[string]string{"Referer": "https://evil.example.com/page"}, http.StatusForbidden},
+		{"同源 Referer", http.MethodPut, map[string]string{"Referer": "http://api.example.com/page"}, http.StatusOK},
+		{"null 来源", http.MethodPost, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"读请求不校验", http.MethodGet, map[string]string{"Origin": "https://evil.example.com"}, http.StatusOK},
+		{"Bearer 不校验", http.MethodPost, map[string]string{"Origin": "https://evil.example.com", "Authorization": "Bearer pat_x"}, http.StatusOK},
+		{"其他 Authorization 头仍校验", http.MethodPost, map[string]string{"Origin": "https://evil.example.com", "Authorization": "x"}, http.StatusForbidden},
+		{"非浏览器客户端", http.MethodPost, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://api.example.com/reminders", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	// 要求携带来源时拒绝缺少 Origin 和 Referer 的 Cookie 写请求，包括带有非 Bearer Authorization 头的请求
+	strict := controllers.CSRFMiddleware(config.CSRFConfig{Enabled: true, RequireOrigin: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, authorization := range []string{"", "x"} {
+		req := httptest.NewRequest(http.MethodPost, "http://api.example.com/logout", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: "session-token"})
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		strict.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "Authorization: %q", authorization)
+	}
+
+	// 不带登录 Cookie 的请求（例如命令行登录）和 Bearer 请求不受影响
+	rec := httptest.NewRecorder()
+	strict.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://api.example.com/login", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.com/logout", nil)
+	req.Header.Set("Authorization", "Bearer session-token")
+	rec = httptest.NewRecorder()
+	strict.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// 测试 SameSite 配置的校验
+func TestSetCookieConfig(t *testing.T) {
+	defer controllers.SetCookieConfig(config.CookieConfig{SameSite: "lax"})
+
+	assert.NoError(t, controllers.SetCookieConfig(config.CookieConfig{SameSite: "Strict", Secure: true, Domain: "example.com"}))
+	assert.NoError(t, controllers.SetCookieConfig(config.CookieConfig{SameSite: "none"}))
+	assert.Error(t, controllers.SetCookieConfig(config.CookieConfig{SameSite: "sometimes"}))
+}
@@ -0,0 +1,131 @@
+package tests__test
+
+import (
+	"calendarReminder-service/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+// 测试在配置可信代理的情况下解析客户端 IP
+func TestGetRequestIP(t *testing.T) {
+	if err := utils.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"}); err != nil {
+		t.Fatalf("设置可信代理失败: %v", err)
+	}
+	defer utils.SetTrustedProxies(nil)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "直连请求去掉端口",
+			remoteAddr: "203.0.113.5:54321",
+			expected:   "203.0.113.5",
+		},
+		{
+			name:       "IPv6 直连请求去掉端口",
+			remoteAddr: "[2001:db8::1]:443",
+			expected:   "2001:db8::1",
+		},
+		{
+			name:       "不可信来源伪造 X-Forwarded-For 被忽略",
+			remoteAddr: "203.0.113.5:54321",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			expected:   "203.0.113.5",
+		},
+		{
+			name:       "不可信来源伪造 Proxy-Client-IP 被忽略",
+			remoteAddr: "203.0.113.5:54321",
+			headers:    map[string]string{"Proxy-Client-IP": "1.1.1.1"},
+			expected:   "203.0.113.5",
+		},
+		{
+			name:       "可信代理转发的单个地址",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "从右向左跳过可信代理",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7, 10.1.2.3, 192.168.1.1"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "客户端在最左侧伪造的地址被忽略",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 10.1.2.3"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "X-Forwarded-For 中带端口",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7:1234"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "全部是可信代理时取最左侧地址",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "10.9.9.9, 10.1.2.3"},
+			expected:   "10.9.9.9",
+		},
+		{
+			name:       "遇到无法识别的地址时停止",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7, unknown, 10.1.2.3"},
+			expected:   "10.1.2.3",
+		},
+		{
+			name:       "标准 Forwarded 头",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"Forwarded": "for=198.51.100.7;proto=https, for=10.1.2.3"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "Forwarded 头中的 IPv6 地址和端口",
+			remoteAddr: "[::1]:8080",
+			headers:    map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711"`},
+			expected:   "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded 优先于 X-Forwarded-For",
+			remoteAddr: "10.0.0.2:8080",
+			headers: map[string]string{
+				"Forwarded":       "for=198.51.100.7",
+				"X-Forwarded-For": "198.51.100.8",
+			},
+			expected: "198.51.100.7",
+		},
+		{
+			name:       "可信代理没有转发头时使用 X-Real-IP",
+			remoteAddr: "10.0.0.2:8080",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.7"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "可信代理没有任何转发头时返回代理地址",
+			remoteAddr: "10.0.0.2:8080",
+			expected:   "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/getSMSCode", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.expected, utils.GetRequestIP(req))
+		})
+	}
+}
+
+// 测试无效的可信代理配置
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	assert.Error(t, utils.SetTrustedProxies([]string{"not-an-ip"}))
+	assert.Error(t, utils.SetTrustedProxies([]string{"10.0.0.0/33"}))
+}
@@ -0,0 +1,82 @@
+package tests__test
+
+import (
+	"calendarReminder-service/controllers"
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 测试更换手机号：原手机号验证码错误时新手机号的验证码仍可使用，更换成功后清理待生效申请和登录凭证
+func TestChangeMobileChecksBothCodes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	userService := services.NewUserService(db, &SequenceIDGenerator{})
+	auditService := services.NewAuditService(db)
+	codeStore := store.NewMemoryCodeStore()
+
+	user, err := userService.CreateUser("+8613800138000")
+	assert.NoError(t, err)
+	newMobile := "+8613700137000"
+	assert.NoError(t, codeStore.Set(controllers.MOBILE_SMSCODE+user.Mobile, utils.HashSMSCode(user.Mobile, "111111"), time.Minute))
+	assert.NoError(t, codeStore.Set(controllers.MOBILE_SMSCODE+newMobile, utils.HashSMSCode(newMobile, "222222"), time.Minute))
+	assert.NoError(t, codeStore.Set(controllers.MOBILE_CHANGE_PENDING+user.CreatorID, `{"new_mobile":"+8613600136000"}`, time.Minute))
+	assert.NoError(t, codeStore.Set(controllers.LOGIN_2FA_TICKET+"ticket-1", `{"creator_id":"`+user.CreatorID+`"}`, time.Minute))
+	assert.NoError(t, codeStore.Set(controllers.LOGIN_2FA_USER+user.CreatorID, "ticket-1", time.Minute))
+
+	changeMobile := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/mobile", strings.NewReader(body))
+		req = req.WithContext(controllers.WithCurrentUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		controllers.ChangeMobile(rr, req, userService, auditService, codeStore)
+		return rr
+	}
+
+	// 原手机号验证码错误，两个验证码都不应被作废
+	rr := changeMobile(`{"new_mobile":"` + newMobile + `","new_sms_code":"222222","old_sms_code":"000000"}`)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	_, err = codeStore.Get(controllers.MOBILE_SMSCODE + newMobile)
+	assert.NoError(t, err, "原手机号验证码错误时新手机号的验证码应当保留")
+	_, err = codeStore.Get(controllers.MOBILE_SMSCODE + user.Mobile)
+	assert.NoError(t, err)
+
+	// 新手机号验证码错误，原手机号验证码不应被作废
+	rr = changeMobile(`{"new_mobile":"` + newMobile + `","new_sms_code":"000000","old_sms_code":"111111"}`)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	_, err = codeStore.Get(controllers.MOBILE_SMSCODE + user.Mobile)
+	assert.NoError(t, err, "新手机号验证码错误时原手机号的验证码应当保留")
+
+	// 两个验证码都正确，更换成功
+	rr = changeMobile(`{"new_mobile":"` + newMobile + `","new_sms_code":"222222","old_sms_code":"111111"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	updated, err := userService.GetUserByCreatorID(user.CreatorID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated) {
+		assert.Equal(t, newMobile, updated.Mobile)
+	}
+	for _, key := range []string{
+		controllers.MOBILE_SMSCODE + newMobile,
+		controllers.MOBILE_SMSCODE + user.Mobile,
+		controllers.MOBILE_CHANGE_PENDING + user.CreatorID,
+		controllers.LOGIN_2FA_TICKET + "ticket-1",
+		controllers.LOGIN_2FA_USER + user.CreatorID,
+	} {
+		_, err := codeStore.Get(key)
+		assert.Equal(t, store.ErrNotFound, err, key)
+	}
+}
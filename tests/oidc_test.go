@@ -0,0 +1,415 @@
+package tests__test
+
+import (
+	"calendarReminder-service/config"
+	"calendarReminder-service/controllers"
+	"calendarReminder-service/models"
+	"calendarReminder-service/oidc"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"calendarReminder-service/utils"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeIdP 进程内的假身份提供方，实现发现文档、授权、令牌和 JWKS 端点
+type fakeIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+
+	mu       sync.Mutex
+	codes    map[string]fakeAuthRequest
+	audience string // 为空时使用 clientID，用于构造错误的 audience
+	kid      string // 为空时使用 JWKS 中的 test-key，用于构造未知的签名公钥
+	jwksHits int    // JWKS 端点被请求的次数
+}
+
+type fakeAuthRequest struct {
+	nonce         string
+	codeChallenge string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成 RSA 密钥失败: %v", err)
+	}
+	idp := &fakeIdP{key: key, clientID: "calendar-reminder", secret: "s3cret", codes: map[string]fakeAuthRequest{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		// 假设用户已在身份提供方登录并同意授权，直接带授权码跳回
+		q := r.URL.Query()
+		code := "code-" + q.Get("state")
+		idp.mu.Lock()
+		idp.codes[code] = fakeAuthRequest{nonce: q.Get("nonce"), codeChallenge: q.Get("code_challenge")}
+		idp.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+q.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != idp.clientID || secret != idp.secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		r.ParseForm()
+		idp.mu.Lock()
+		req, found := idp.codes[r.PostForm.Get("code")]
+		delete(idp.codes, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !found || base64.RawURLEncoding.EncodeToString(sum[:]) != req.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.signIDToken(t, req.nonce),
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		idp.jwksHits++
+		idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// signIDToken 签发 RS256 的 id_token
+func (idp *fakeIdP) signIDToken(t *testing.T, nonce string) string {
+	aud := idp.audience
+	if aud == "" {
+		aud = idp.clientID
+	}
+	kid := idp.kid
+	if kid == "" {
+		kid = "test-key"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"iss":   idp.server.URL,
+		"sub":   "external-user-1",
+		"aud":   []string{aud},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": nonce,
+		"email": "user@example.com",
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("签名 id_token 失败: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *fakeIdP) provider() *oidc.Provider {
+	return oidc.NewProvider(config.OIDCConfig{
+		Enabled:      true,
+		Issuer:       idp.server.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: idp.secret,
+		RedirectURL:  "http://127.0.0.1:9900/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+// authorize 模拟浏览器访问授权地址，返回回调中的授权码
+func (idp *fakeIdP) authorize(t *testing.T, provider *oidc.Provider, state, nonce, verifier string) string {
+	authURL, err := provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("生成授权地址失败: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("访问授权地址失败: %v", err)
+	}
+	resp.Body.Close()
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	assert.Equal(t, state, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+// 测试完整的授权码流程
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", oidc.CodeChallenge("verifier-1"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authURL, idp.server.URL+"/authorize?"))
+	parsed, _ := url.Parse(authURL)
+	assert.Equal(t, "code", parsed.Query().Get("response_type"))
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email", parsed.Query().Get("scope"))
+
+	code := idp.authorize(t, provider, "state-1", "nonce-1", "verifier-1")
+	claims, err := provider.Exchange(code, "verifier-1", "nonce-1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, idp.server.URL, claims.Issuer)
+		assert.Equal(t, "external-user-1", claims.Subject)
+		assert.Equal(t, "user@example.com", claims.Email)
+	}
+}
+
+// 测试 ID Token 校验失败的情况
+func TestOIDCRejectsInvalidTokens(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+
+	// nonce 不匹配
+	code := idp.authorize(t, provider, "state-2", "nonce-2", "verifier-2")
+	_, err := provider.Exchange(code, "verifier-2", "another-nonce")
+	assert.Error(t, err, "nonce 不匹配时应该失败")
+
+	// PKCE code_verifier 不匹配
+	code = idp.authorize(t, provider, "state-3", "nonce-3", "verifier-3")
+	_, err = provider.Exchange(code, "wrong-verifier", "nonce-3")
+	assert.Error(t, err, "code_verifier 不匹配时应该失败")
+
+	// audience 不是本服务
+	idp.audience = "another-client"
+	code = idp.authorize(t, provider, "state-4", "nonce-4", "verifier-4")
+	_, err = provider.Exchange(code, "verifier-4", "nonce-4")
+	assert.Error(t, err, "audience 不匹配时应该失败")
+	idp.audience = ""
+
+	// id_token 使用了其他私钥签名（本服务已缓存身份提供方原来的公钥）
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.key = otherKey
+	code = idp.authorize(t, provider, "state-5", "nonce-5", "verifier-5")
+	_, err = provider.Exchange(code, "verifier-5", "nonce-5")
+	assert.Error(t, err, "签名无效时应该失败")
+}
+
+// oidcTestServices OIDC 登录回调使用的服务
+type oidcTestServices struct {
+	codeStore      store.CodeStore
+	userService    services.UserService
+	sessionService services.SessionService
+	totpService    services.TOTPService
+	auditService   services.AuditService
+}
+
+func newOIDCTestServices(t *testing.T) *oidcTestServices {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}, &models.UserTOTP{}, &models.RecoveryCode{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	return &oidcTestServices{
+		codeStore:      store.NewMemoryCodeStore(),
+		userService:    services.NewUserService(db, &SequenceIDGenerator{}),
+		sessionService: services.NewSessionService(store.NewMemorySessionStore()),
+		totpService:    services.NewTOTPService(db, "CalendarReminder"),
+		auditService:   services.NewAuditService(db),
+	}
+}
+
+// oidcStart 发起第三方登录，返回身份提供方跳回的回调参数和写入浏览器的 state Cookie
+func (idp *fakeIdP) oidcStart(t *testing.T, provider *oidc.Provider, deps *oidcTestServices) (string, *http.Cookie) {
+	rr := httptest.NewRecorder()
+	controllers.OIDCLogin(rr, httptest.NewRequest(http.MethodGet, "/oidc/login", nil), provider, deps.codeStore)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	stateCookie := findCookie(rr, "oidc_state")
+	if stateCookie == nil {
+		t.Fatal("发起登录时没有写入 state Cookie")
+	}
+	assert.True(t, stateCookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, stateCookie.SameSite)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("访问授权地址失败: %v", err)
+	}
+	resp.Body.Close()
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	return callback.RawQuery, stateCookie
+}
+
+// oidcCallback 携带 state Cookie（可以为空）访问回调地址
+func oidcCallback(provider *oidc.Provider, deps *oidcTestServices, rawQuery string, stateCookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oidc/callback?"+rawQuery, nil)
+	if stateCookie != nil {
+		req.AddCookie(stateCookie)
+	}
+	rr := httptest.NewRecorder()
+	controllers.OIDCCallback(rr, req, provider, deps.userService, deps.sessionService, deps.totpService, deps.auditService, deps.codeStore, "")
+	return rr
+}
+
+// oidcLogin 模拟同一个浏览器完成一次第三方登录：发起登录、在身份提供方授权、访问回调地址
+func (idp *fakeIdP) oidcLogin(t *testing.T, provider *oidc.Provider, deps *oidcTestServices) *httptest.ResponseRecorder {
+	rawQuery, stateCookie := idp.oidcStart(t, provider, deps)
+	return oidcCallback(provider, deps, rawQuery, stateCookie)
+}
+
+func findCookie(rr *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// 测试回调必须在发起登录的浏览器上完成：攻击者的回调地址不能让其他浏览器登录到攻击者的账号
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	deps := newOIDCTestServices(t)
+
+	// 攻击者发起登录拿到自己的回调地址，诱导受害者访问；受害者的浏览器没有对应的 Cookie
+	attackerQuery, _ := idp.oidcStart(t, provider, deps)
+	rr := oidcCallback(provider, deps, attackerQuery, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Nil(t, findCookie(rr, "token"))
+
+	// 受害者自己发起过登录，Cookie 对应的是另一个 state
+	_, victimCookie := idp.oidcStart(t, provider, deps)
+	rr = oidcCallback(provider, deps, attackerQuery, victimCookie)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Nil(t, findCookie(rr, "token"))
+
+	// 同一浏览器完成登录后清除 state Cookie
+	rr = idp.oidcLogin(t, provider, deps)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotNil(t, findCookie(rr, "token"))
+	if stateCookie := findCookie(rr, "oidc_state"); assert.NotNil(t, stateCookie) {
+		assert.Empty(t, stateCookie.Value)
+	}
+}
+
+// 测试开启了两步验证的用户通过第三方登录时不会直接创建会话
+func TestOIDCCallbackRequiresTwoFactor(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	deps := newOIDCTestServices(t)
+
+	// 首次登录自动注册并直接创建会话
+	rr := idp.oidcLogin(t, provider, deps)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotNil(t, findCookie(rr, "token"))
+
+	user, err := deps.userService.GetUserByIdentity(idp.server.URL, "external-user-1")
+	if !assert.NoError(t, err) || !assert.NotNil(t, user) {
+		return
+	}
+	secret, _, err := deps.totpService.Enroll(user.CreatorID, "")
+	assert.NoError(t, err)
+	code, _ := utils.TOTPCode(secret, time.Now())
+	_, err = deps.totpService.Confirm(user.CreatorID, code)
+	assert.NoError(t, err)
+
+	// 开启两步验证后只返回登录凭证
+	rr = idp.oidcLogin(t, provider, deps)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Nil(t, findCookie(rr, "token"), "两步验证通过前不能写入会话 Cookie")
+	var body struct {
+		Data struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			LoginTicket       string `json:"login_ticket"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.True(t, body.Data.TwoFactorRequired)
+	assert.NotEmpty(t, body.Data.LoginTicket)
+
+	sessions, err := deps.sessionService.ListSessions(user.CreatorID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1, "只有首次登录创建了会话")
+
+	// 配置了跳转地址时登录凭证只写入 HttpOnly Cookie，不出现在跳转地址中
+	rawQuery, stateCookie := idp.oidcStart(t, provider, deps)
+	req := httptest.NewRequest(http.MethodGet, "/oidc/callback?"+rawQuery, nil)
+	req.AddCookie(stateCookie)
+	rr = httptest.NewRecorder()
+	controllers.OIDCCallback(rr, req, provider, deps.userService, deps.sessionService, deps.totpService, deps.auditService, deps.codeStore, "https://app.example.com/done")
+	assert.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	assert.Equal(t, "true", location.Query().Get("two_factor_required"))
+	assert.Empty(t, location.Query().Get("login_ticket"), "登录凭证不能放到跳转地址中")
+	ticketCookie := findCookie(rr, "login_ticket")
+	if !assert.NotNil(t, ticketCookie) {
+		return
+	}
+	assert.True(t, ticketCookie.HttpOnly)
+	assert.Nil(t, findCookie(rr, "token"))
+
+	// 提交动态验证码时请求体可以省略登录凭证，使用 Cookie 中的凭证完成登录并清除该 Cookie
+	// 开启时使用过的验证码不能重放，使用下一个时间步的验证码
+	code, _ = utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	req = httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"code":"`+code+`"}`))
+	req.AddCookie(ticketCookie)
+	rr = httptest.NewRecorder()
+	controllers.LoginTwoFactor(rr, req, deps.userService, deps.sessionService, deps.totpService, deps.auditService, deps.codeStore)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotNil(t, findCookie(rr, "token"))
+	if cleared := findCookie(rr, "login_ticket"); assert.NotNil(t, cleared) {
+		assert.Empty(t, cleared.Value)
+	}
+}
+
+// 测试携带未知 kid 的 id_token 不会在冷却时间内反复触发 JWKS 拉取
+func TestOIDCUnknownKeyDoesNotRefetchJWKS(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	deps := newOIDCTestServices(t)
+
+	rr := idp.oidcLogin(t, provider, deps)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 1, idp.jwksHits)
+
+	idp.kid = "unknown-key"
+	for i := 0; i < 3; i++ {
+		rr = idp.oidcLogin(t, provider, deps)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	}
+	assert.Equal(t, 1, idp.jwksHits, "冷却时间内不应重新拉取 JWKS")
+}
@@ -6,6 +6,8 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // MockIDGenerator 用于模拟 ID 生成器
@@ -69,3 +71,90 @@ func TestUserService(t *testing.T) {
 		t.Errorf("期望获取的手机号码为 '%s'，但实际为 '%s'", mobile, fetchedUser.Mobile)
 	}
 }
+
+// 测试旧版国内格式手机号迁移为 E.164 格式，迁移后老用户登录能找到原来的账号
+func TestMigrateLegacyMobiles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	userService := services.NewUserService(db, &MockIDGenerator{})
+
+	legacy, err := userService.CreateUser("13800138000")
+	assert.NoError(t, err)
+	// 已经是 E.164 格式或没有手机号的用户不受影响
+	current, err := userService.CreateUser("+8613900139000")
+	assert.NoError(t, err)
+	oidcUser, err := userService.CreateUserWithIdentity("https://idp.example.com", "sub-1", "")
+	assert.NoError(t, err)
+	// 迁移前已经用新格式重复注册的号码不能覆盖
+	conflict, err := userService.CreateUser("13700137000")
+	assert.NoError(t, err)
+	_, err = userService.CreateUser("+8613700137000")
+	assert.NoError(t, err)
+
+	migrated, err := userService.MigrateLegacyMobiles()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	user, err := userService.GetUserByMobile("+8613800138000")
+	assert.NoError(t, err)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, legacy.CreatorID, user.CreatorID)
+	}
+	user, _ = userService.GetUserByCreatorID(current.CreatorID)
+	assert.Equal(t, "+8613900139000", user.Mobile)
+	user, _ = userService.GetUserByCreatorID(oidcUser.CreatorID)
+	assert.Empty(t, user.Mobile)
+	user, _ = userService.GetUserByCreatorID(conflict.CreatorID)
+	assert.Equal(t, "13700137000", user.Mobile)
+
+	// 重复执行不会再转换
+	migrated, err = userService.MigrateLegacyMobiles()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
+
+// 测试按配置授予管理员：手机号按登录格式解析，已是管理员的用户不重复计数，无效手机号返回错误
+func TestGrantAdmins(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	userService := services.NewUserService(db, &MockIDGenerator{})
+
+	byMobile, err := userService.CreateUser("+8613800138000")
+	assert.NoError(t, err)
+	byID, err := userService.CreateUserWithIdentity("https://idp.example.com", "sub-1", "")
+	assert.NoError(t, err)
+	other, err := userService.CreateUser("+8613900139000")
+	assert.NoError(t, err)
+
+	granted, err := userService.GrantAdmins([]string{"13800138000"}, []string{byID.CreatorID})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, granted)
+	for creatorID, role := range map[string]string{
+		byMobile.CreatorID: services.RoleAdmin,
+		byID.CreatorID:     services.RoleAdmin,
+		other.CreatorID:    services.RoleUser,
+	} {
+		user, _ := userService.GetUserByCreatorID(creatorID)
+		if assert.NotNil(t, user) {
+			assert.Equal(t, role, user.Role, creatorID)
+		}
+	}
+
+	// 重复执行不会再授予
+	granted, err = userService.GrantAdmins([]string{"+8613800138000"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, granted)
+
+	_, err = userService.GrantAdmins([]string{"not-a-mobile"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package tests__test
+
+import (
+	"calendarReminder-service/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试手机号解析为 E.164 格式以及允许的国家/地区
+func TestNormalizePhoneNumber(t *testing.T) {
+	if err := utils.SetPhoneCountries([]string{"CN", "HK", "GB"}, "CN"); err != nil {
+		t.Fatalf("设置国家/地区失败: %v", err)
+	}
+	defer utils.SetPhoneCountries(nil, "CN")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      error
+	}{
+		{"国内格式", "13800138000", "+8613800138000", nil},
+		{"带区号", "+8613800138000", "+8613800138000", nil},
+		{"00 开头的国际格式", "008613800138000", "+8613800138000", nil},
+		{"带空格和横线", "+86 138-0013-8000", "+8613800138000", nil},
+		{"香港", "+852 9123 4567", "+85291234567", nil},
+		{"英国去掉冠码 0", "+44 07700 900123", "+447700900123", nil},
+		{"国内号码位数不对", "1380013800", "", utils.ErrInvalidPhoneNumber},
+		{"固定电话", "+861012345678", "", utils.ErrInvalidPhoneNumber},
+		{"未知区号", "+999123456789", "", utils.ErrInvalidPhoneNumber},
+		{"不允许的国家", "+1 415 555 2671", "", utils.ErrPhoneCountryNotAllowed},
+		{"空字符串", "", "", utils.ErrInvalidPhoneNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := utils.NormalizePhoneNumber(tt.input)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.expected, normalized)
+		})
+	}
+
+	assert.True(t, utils.IsValidPhoneNumber("13800138000"))
+	assert.False(t, utils.IsValidPhoneNumber("+14155552671"))
+	assert.Error(t, utils.SetPhoneCountries([]string{"XX"}, "CN"))
+	assert.Error(t, utils.SetPhoneCountries(nil, ""))
+}
@@ -0,0 +1,82 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// 测试用户资料：默认值、部分更新和校验
+func TestProfileService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserProfile{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	profileService := services.NewProfileService(db)
+
+	profile, err := profileService.GetProfile("C1")
+	assert.NoError(t, err)
+	assert.Equal(t, services.DefaultTimezone, profile.Timezone)
+	assert.Equal(t, services.ChannelSMS, profile.NotificationChannel)
+
+	profile, err = profileService.UpdateProfile("C1", &services.ProfileUpdate{
+		DisplayName:     strPtr("小明"),
+		Timezone:        strPtr("America/New_York"),
+		QuietHoursStart: strPtr("22:00"),
+		QuietHoursEnd:   strPtr("07:00"),
+	})
+	assert.NoError(t, err)
+
+	// 只修改语言，其余字段保持不变
+	profile, err = profileService.UpdateProfile("C1", &services.ProfileUpdate{Language: strPtr("en-US")})
+	assert.NoError(t, err)
+	assert.Equal(t, "小明", profile.DisplayName)
+	assert.Equal(t, "America/New_York", profile.Timezone)
+	assert.Equal(t, "en-US", profile.Language)
+
+	invalid := []*services.ProfileUpdate{
+		{Timezone: strPtr("Mars/Olympus")},
+		{Timezone: strPtr("")},
+		{QuietHoursStart: strPtr("25:00")},
+		{QuietHoursEnd: strPtr("")},
+		{NotificationChannel: strPtr("email")},
+		{Language: strPtr("中文")},
+	}
+	for _, update := range invalid {
+		_, err := profileService.UpdateProfile("C1", update)
+		assert.True(t, errors.Is(err, services.ErrInvalidProfile), "应当拒绝无效的资料: %+v", update)
+	}
+}
+
+// 测试免打扰时段：跨零点的时段按用户时区计算结束时间
+func TestQuietHoursEnd(t *testing.T) {
+	profile := &models.UserProfile{Timezone: "America/New_York", QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+	loc, _ := time.LoadLocation("America/New_York")
+
+	until, quiet := services.QuietHoursEnd(profile, time.Date(2024, 9, 30, 23, 30, 0, 0, loc))
+	assert.True(t, quiet)
+	assert.Equal(t, time.Date(2024, 10, 1, 7, 0, 0, 0, loc), until)
+
+	until, quiet = services.QuietHoursEnd(profile, time.Date(2024, 10, 1, 6, 0, 0, 0, loc))
+	assert.True(t, quiet)
+	assert.Equal(t, time.Date(2024, 10, 1, 7, 0, 0, 0, loc), until)
+
+	_, quiet = services.QuietHoursEnd(profile, time.Date(2024, 10, 1, 12, 0, 0, 0, loc))
+	assert.False(t, quiet)
+
+	_, quiet = services.QuietHoursEnd(&models.UserProfile{Timezone: "UTC"}, time.Now())
+	assert.False(t, quiet, "未设置免打扰时不应推迟")
+}
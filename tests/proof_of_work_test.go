@@ -0,0 +1,28 @@
+package tests__test
+
+import (
+	"calendarReminder-service/utils"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+// 测试工作量证明：客户端暴力搜索到的 nonce 能通过校验
+func TestVerifyProofOfWork(t *testing.T) {
+	challenge := "test-challenge"
+	difficulty := 12
+
+	nonce := ""
+	for i := 0; i < 1<<20; i++ {
+		if utils.VerifyProofOfWork(challenge, strconv.Itoa(i), difficulty) {
+			nonce = strconv.Itoa(i)
+			break
+		}
+	}
+	assert.NotEmpty(t, nonce, "没有找到满足难度的 nonce")
+
+	assert.True(t, utils.VerifyProofOfWork(challenge, nonce, difficulty))
+	assert.False(t, utils.VerifyProofOfWork("other-challenge", nonce, 256), "换了挑战后不应通过")
+	assert.False(t, utils.VerifyProofOfWork(challenge, "", 0), "空 nonce 不应通过")
+	assert.True(t, utils.VerifyProofOfWork(challenge, "any", 0), "难度为 0 时任意 nonce 都应通过")
+}
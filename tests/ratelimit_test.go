@@ -0,0 +1,94 @@
+package tests__test
+
+import (
+	"calendarReminder-service/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// allowOne 检查单条限流规则
+func allowOne(limiter ratelimit.RateLimiter, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	allowed, _, retryAfter, err := limiter.AllowAll([]ratelimit.Rule{{Key: key, Limit: limit, Window: window}})
+	return allowed, retryAfter, err
+}
+
+// 测试内存限流器：窗口内超过次数后拒绝，并返回重试等待时间
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := ratelimit.NewMemoryRateLimiter()
+
+	for i := 0; i < 3; i++ {
+		allowed, _, err := allowOne(limiter, "ip:127.0.0.1", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, allowed, "第 %d 次请求应该被允许", i+1)
+	}
+
+	allowed, retryAfter, err := allowOne(limiter, "ip:127.0.0.1", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "超过次数的请求应该被拒绝")
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Minute, "重试等待时间不正确: %v", retryAfter)
+
+	// 不同的 key 互不影响
+	allowed, _, _ = allowOne(limiter, "ip:127.0.0.2", 3, time.Minute)
+	assert.True(t, allowed)
+}
+
+// 测试滑动窗口：窗口过去后恢复请求
+func TestMemoryRateLimiterWindowSlides(t *testing.T) {
+	limiter := ratelimit.NewMemoryRateLimiter()
+
+	allowed, _, _ := allowOne(limiter, "mobile:13800138000", 1, 50*time.Millisecond)
+	assert.True(t, allowed)
+	allowed, _, _ = allowOne(limiter, "mobile:13800138000", 1, 50*time.Millisecond)
+	assert.False(t, allowed)
+
+	time.Sleep(60 * time.Millisecond)
+	allowed, _, _ = allowOne(limiter, "mobile:13800138000", 1, 50*time.Millisecond)
+	assert.True(t, allowed, "窗口过去后应该恢复请求")
+}
+
+// 测试多条规则组合：任意一条超限即拒绝，limit 为 0 的规则不生效
+func TestRateLimitCheck(t *testing.T) {
+	limiter := ratelimit.NewMemoryRateLimiter()
+	rules := []ratelimit.Rule{
+		{Key: "sms:global:all", Limit: 0, Window: time.Minute},
+		{Key: "sms:ip:127.0.0.1", Limit: 2, Window: time.Minute},
+	}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := ratelimit.Check(limiter, rules...)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter, err := ratelimit.Check(limiter, rules...)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.True(t, retryAfter > 0)
+}
+
+// 测试多条规则组合：被 IP 规则拒绝的请求不占用全局配额
+func TestRateLimitCheckRejectedDoesNotConsume(t *testing.T) {
+	limiter := ratelimit.NewMemoryRateLimiter()
+	rules := func(ip string) []ratelimit.Rule {
+		return []ratelimit.Rule{
+			{Key: "sms:global:all", Limit: 3, Window: time.Minute},
+			{Key: "sms:ip:" + ip, Limit: 1, Window: time.Minute},
+		}
+	}
+
+	allowed, _, _ := ratelimit.Check(limiter, rules("10.0.0.1")...)
+	assert.True(t, allowed)
+	// 同一 IP 反复请求都被 IP 规则拒绝
+	for i := 0; i < 5; i++ {
+		allowed, _, _ = ratelimit.Check(limiter, rules("10.0.0.1")...)
+		assert.False(t, allowed)
+	}
+
+	// 全局配额只用掉 1 次，其他 IP 仍然可以请求 2 次
+	for _, ip := range []string{"10.0.0.2", "10.0.0.3"} {
+		allowed, _, _ = ratelimit.Check(limiter, rules(ip)...)
+		assert.True(t, allowed, "IP %s 应该被允许", ip)
+	}
+	allowed, _, _ = ratelimit.Check(limiter, rules("10.0.0.4")...)
+	assert.False(t, allowed, "全局配额用完后应该被拒绝")
+}
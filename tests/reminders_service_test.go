@@ -7,6 +7,8 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"log"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -18,7 +20,7 @@ func initDB() *gorm.DB {
 		log.Fatalf("无法连接到数据库: %v", err)
 	}
 	// 运行自动迁移
-	err = db.AutoMigrate(&models.Reminder{})
+	err = db.AutoMigrate(&models.Reminder{}, &models.ReminderTag{})
 	if err != nil {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
@@ -41,3 +43,345 @@ func TestCreateReminder(t *testing.T) {
 	assert.NoError(t, err, "创建提醒失败")
 	log.Printf("成功创建提醒: %+v\n", reminder)
 }
+
+// 测试投递状态的流转和按状态筛选
+func TestUpdateReminderStatus(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	reminder := models.Reminder{
+		CreatorID: "test_user",
+		Content:   "Test Reminder",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+	}
+	assert.NoError(t, service.CreateReminder(&reminder))
+
+	got, err := service.GetReminderByID(reminder.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, services.ReminderScheduled, got.Status, "新建的提醒默认是 scheduled")
+
+	// 第一次发送失败，第二次发送成功
+	assert.NoError(t, service.UpdateReminderStatus(reminder.ID, services.ReminderSending, ""))
+	assert.NoError(t, service.UpdateReminderStatus(reminder.ID, services.ReminderFailed, "网关超时"))
+	got, _ = service.GetReminderByID(reminder.ID)
+	assert.Equal(t, services.ReminderFailed, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, "网关超时", got.LastError)
+	assert.Nil(t, got.SentAt)
+
+	assert.NoError(t, service.UpdateReminderStatus(reminder.ID, services.ReminderSending, ""))
+	assert.NoError(t, service.UpdateReminderStatus(reminder.ID, services.ReminderSent, ""))
+	got, _ = service.GetReminderByID(reminder.ID)
+	assert.Equal(t, services.ReminderSent, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Empty(t, got.LastError)
+	assert.NotNil(t, got.SentAt)
+
+	other := models.Reminder{CreatorID: "test_user", Content: "Other", RemindAt: reminder.RemindAt}
+	assert.NoError(t, service.CreateReminder(&other))
+
+	sent, err := service.ListReminders("test_user", services.ReminderQuery{Statuses: []string{services.ReminderSent}})
+	assert.NoError(t, err)
+	assert.Len(t, sent.Items, 1)
+	assert.Equal(t, reminder.ID, sent.Items[0].ID)
+
+	all, err := service.ListReminders("test_user", services.ReminderQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, all.Items, 2)
+
+	missing, err := service.GetReminderByID(999)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+// 测试新建提醒的版本和删除后投递流程查不到提醒
+func TestReminderVersionAndDelete(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	reminder := models.Reminder{
+		CreatorID: "test_user",
+		Content:   "Test Reminder",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+	}
+	assert.NoError(t, service.CreateReminder(&reminder))
+
+	got, err := service.GetReminderByID(reminder.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Version, "新建的提醒版本为 1")
+
+	// 其他用户不能删除
+	assert.ErrorIs(t, service.DeleteReminder(strconv.FormatUint(uint64(reminder.ID), 10), "other_user"), services.ErrReminderNotFound)
+	got, _ = service.GetReminderByID(reminder.ID)
+	assert.NotNil(t, got)
+
+	assert.NoError(t, service.DeleteReminder(strconv.FormatUint(uint64(reminder.ID), 10), "test_user"))
+	got, err = service.GetReminderByID(reminder.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, got, "删除后队列中的消息查不到提醒，不会发送")
+}
+
+// 测试带新版本号的更新只在版本未变化时生效
+func TestUpdateReminderVersionConflict(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	reminder := models.Reminder{
+		CreatorID: "test_user",
+		Content:   "Test Reminder",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+		Version:   1,
+	}
+	assert.NoError(t, service.CreateReminder(&reminder))
+	id := strconv.FormatUint(uint64(reminder.ID), 10)
+
+	first := reminder
+	first.Content = "第一次更新"
+	first.Version = 2
+	assert.NoError(t, service.UpdateReminder(id, &first, "test_user"))
+	// 另一个请求基于版本 1 更新
+	second := reminder
+	second.Content = "第二次更新"
+	second.Version = 2
+	err := service.UpdateReminder(id, &second, "test_user")
+	assert.ErrorIs(t, err, services.ErrReminderConflict)
+
+	got, _ := service.GetReminderByID(reminder.ID)
+	assert.Equal(t, "第一次更新", got.Content)
+	assert.Equal(t, 2, got.Version)
+}
+
+// 测试获取单个提醒：其他用户的和不存在的提醒返回 ErrReminderNotFound，删除和更新同样如此
+func TestGetReminder(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	reminder := models.Reminder{
+		CreatorID: "test_user",
+		Content:   "Test Reminder",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+		Version:   1,
+		Tags:      []string{"工作"},
+	}
+	assert.NoError(t, service.CreateReminder(&reminder))
+	id := strconv.FormatUint(uint64(reminder.ID), 10)
+
+	got, err := service.GetReminder(id, "test_user")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Reminder", got.Content)
+	assert.Equal(t, []string{"工作"}, got.Tags)
+
+	_, err = service.GetReminder(id, "other_user")
+	assert.ErrorIs(t, err, services.ErrReminderNotFound)
+	_, err = service.GetReminder("9999", "test_user")
+	assert.ErrorIs(t, err, services.ErrReminderNotFound)
+
+	assert.ErrorIs(t, service.DeleteReminder("9999", "test_user"), services.ErrReminderNotFound)
+	assert.ErrorIs(t, service.UpdateReminder("9999", &models.Reminder{Content: "x", Version: 2}, "test_user"), services.ErrReminderNotFound)
+	assert.ErrorIs(t, service.UpdateReminder(id, &models.Reminder{Content: "x", Version: 2}, "other_user"), services.ErrReminderNotFound)
+
+	// 未携带版本号且内容未变化时不视为失败
+	unchanged := reminder
+	unchanged.Version = 0
+	assert.NoError(t, service.UpdateReminder(id, &unchanged, "test_user"))
+}
+
+// 测试更新提醒时清空重复规则，改为单次提醒
+func TestUpdateReminderClearRRule(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	reminder := models.Reminder{
+		CreatorID: "test_user",
+		Content:   "每日提醒",
+		RemindAt:  models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)},
+		RRule:     "FREQ=DAILY",
+		ExDate:    "20990101",
+		Version:   1,
+	}
+	assert.NoError(t, service.CreateReminder(&reminder))
+	id := strconv.FormatUint(uint64(reminder.ID), 10)
+
+	update := reminder
+	update.RRule = ""
+	update.ExDate = ""
+	update.Version = 2
+	update.Status = services.ReminderScheduled
+	assert.NoError(t, service.UpdateReminder(id, &update, "test_user"))
+
+	got, _ := service.GetReminderByID(reminder.ID)
+	assert.Empty(t, got.RRule, "重复规则应该被清空")
+	assert.Empty(t, got.ExDate, "排除日期应该被清空")
+	assert.Equal(t, "每日提醒", got.Content)
+	assert.True(t, got.RemindAt.Equal(reminder.RemindAt.Time))
+	assert.Equal(t, 2, got.Version)
+}
+
+// 测试推迟提醒：单次提醒提升版本号，重复提醒保持版本号，基于过期数据的推迟返回冲突
+func TestSnoozeReminder(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+	remindAt := models.JSONTime{Time: time.Now().Add(time.Hour).Truncate(time.Second)}
+
+	once := models.Reminder{CreatorID: "test_user", Content: "单次提醒", RemindAt: remindAt, Version: 1, Status: services.ReminderSent}
+	assert.NoError(t, service.CreateReminder(&once))
+	stale := once
+
+	until := time.Now().Add(10 * time.Minute)
+	assert.NoError(t, service.SnoozeReminder(&once, until))
+	assert.Equal(t, 2, once.Version)
+	assert.Equal(t, 1, once.SnoozeCount)
+
+	got, _ := service.GetReminderByID(once.ID)
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, 1, got.SnoozeCount)
+	assert.Equal(t, services.ReminderScheduled, got.Status)
+	assert.NotNil(t, got.SnoozedUntil)
+
+	assert.ErrorIs(t, service.SnoozeReminder(&stale, until), services.ErrReminderConflict)
+
+	daily := models.Reminder{CreatorID: "test_user", Content: "重复提醒", RemindAt: remindAt, RRule: "FREQ=DAILY", Version: 1}
+	assert.NoError(t, service.CreateReminder(&daily))
+	assert.NoError(t, service.SnoozeReminder(&daily, until))
+	assert.Equal(t, 1, daily.Version, "重复提醒推迟后版本号不变，后续的重复提醒照常发送")
+	assert.Equal(t, 1, daily.SnoozeCount)
+}
+
+// 测试提醒列表的游标分页、筛选、排序、关键字搜索和标签
+func TestListReminders(t *testing.T) {
+	db := initDB()
+	service := services.NewReminderService(db)
+
+	base := time.Date(2030, 1, 1, 9, 0, 0, 0, time.Local)
+	contents := []string{"晨会", "周会 100%", "周报", "体检", "晨跑"}
+	tags := [][]string{{"work"}, {"work", "meeting"}, {"work"}, {"health"}, {"health"}}
+	for i, content := range contents {
+		reminder := models.Reminder{
+			CreatorID: "test_user",
+			Content:   content,
+			RemindAt:  models.JSONTime{Time: base.AddDate(0, 0, i)},
+			CreatedAt: models.JSONTime{Time: base.Add(-time.Duration(i) * time.Hour)},
+			Tags:      tags[i],
+		}
+		assert.NoError(t, service.CreateReminder(&reminder))
+	}
+	other := models.Reminder{CreatorID: "other_user", Content: "晨会", RemindAt: models.JSONTime{Time: base}, Tags: []string{"work"}}
+	assert.NoError(t, service.CreateReminder(&other))
+
+	// 按 remind_at 正序，每页 2 条翻完所有提醒
+	var contentsSeen []string
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		page, err := service.ListReminders("test_user", services.ReminderQuery{Limit: 2, Cursor: cursor})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		for _, reminder := range page.Items {
+			contentsSeen = append(contentsSeen, reminder.Content)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, contents, contentsSeen)
+
+	// 倒序
+	page, err := service.ListReminders("test_user", services.ReminderQuery{Sort: services.SortRemindAtDesc, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "晨跑", page.Items[0].Content)
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Sort: services.SortRemindAtDesc, Limit: 1, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, "体检", page.Items[0].Content)
+
+	// 按创建时间倒序：第一条创建得最晚
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Sort: services.SortCreatedAtDesc, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "晨会", page.Items[0].Content)
+
+	// 游标与排序方式不匹配
+	_, err = service.ListReminders("test_user", services.ReminderQuery{Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, services.ErrInvalidCursor)
+	_, err = service.ListReminders("test_user", services.ReminderQuery{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, services.ErrInvalidCursor)
+
+	// 提醒时间不足一秒的差异也不能让翻页跳过或重复提醒
+	for i, content := range []string{"第一条", "第二条", "第三条"} {
+		reminder := models.Reminder{CreatorID: "precise_user", Content: content, RemindAt: models.JSONTime{Time: base.Add(time.Duration(i+1) * 300 * time.Millisecond)}}
+		assert.NoError(t, service.CreateReminder(&reminder))
+	}
+	contentsSeen = nil
+	cursor = ""
+	for pages := 0; pages < 3; pages++ {
+		page, err := service.ListReminders("precise_user", services.ReminderQuery{Limit: 1, Cursor: cursor})
+		assert.NoError(t, err)
+		for _, reminder := range page.Items {
+			contentsSeen = append(contentsSeen, reminder.Content)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"第一条", "第二条", "第三条"}, contentsSeen)
+
+	// 标签、时间范围和关键字筛选，% 按字面匹配
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Tags: []string{"work"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, []string{"work", "meeting"}, page.Items[1].Tags)
+
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Tags: []string{"health"}, From: base.AddDate(0, 0, 4)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "晨跑", page.Items[0].Content)
+
+	page, err = service.ListReminders("test_user", services.ReminderQuery{From: base.AddDate(0, 0, 1), To: base.AddDate(0, 0, 3)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Keyword: "晨"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Keyword: "0%"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	page, err = service.ListReminders("test_user", services.ReminderQuery{Keyword: "%"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+
+	// 更新时替换标签，不传标签时保持不变
+	first := page.Items[0]
+	id := strconv.FormatUint(uint64(first.ID), 10)
+	retag := first
+	retag.Tags = []string{"review"}
+	assert.NoError(t, service.UpdateReminder(id, &retag, "test_user"))
+	rename := first
+	rename.Content = "周会"
+	rename.Tags = nil
+	assert.NoError(t, service.UpdateReminder(id, &rename, "test_user"))
+	got, _ := service.GetReminderByID(first.ID)
+	assert.Equal(t, []string{"review"}, got.Tags)
+	assert.Equal(t, "周会", got.Content)
+
+	// 删除提醒时删除标签
+	assert.NoError(t, service.DeleteReminder(id, "test_user"))
+	var count int64
+	db.Model(&models.ReminderTag{}).Where("reminder_id = ?", first.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+// 测试标签的规范化和校验
+func TestNormalizeTags(t *testing.T) {
+	tags, err := services.NormalizeTags([]string{" work ", "work", "", "家庭"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "家庭"}, tags)
+
+	tags, err = services.NormalizeTags(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, tags, "没有传标签时保持 nil，更新时不修改标签")
+
+	_, err = services.NormalizeTags([]string{"a,b"})
+	assert.Error(t, err)
+	_, err = services.NormalizeTags([]string{strings.Repeat("长", 33)})
+	assert.Error(t, err)
+	_, err = services.NormalizeTags([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var shanghai = time.FixedZone("CST", 8*3600)
+
+// occurrences 返回 dtstart 开始的前 n 次提醒时间
+func occurrences(t *testing.T, rule string, dtstart time.Time, n int) []string {
+	return occurrencesExcept(t, rule, "", dtstart, n)
+}
+
+// occurrencesExcept 返回 dtstart 开始、排除 exdate 后的前 n 次提醒时间
+func occurrencesExcept(t *testing.T, rule, exdate string, dtstart time.Time, n int) []string {
+	rr, err := utils.ParseRRule(rule, dtstart.Location())
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	if !assert.NoError(t, rr.ParseExDates(exdate, dtstart.Location())) {
+		return nil
+	}
+	var result []string
+	after := dtstart.Add(-time.Second)
+	for len(result) < n {
+		next, ok := rr.Next(dtstart, after)
+		if !ok {
+			break
+		}
+		result = append(result, next.Format("2006-01-02 15:04"))
+		after = next
+	}
+	return result
+}
+
+// 测试重复规则的解析校验
+func TestParseRRuleInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;UNTIL=2025-01-01",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;EXDATE=20250102",
+	}
+	for _, rule := range invalid {
+		_, err := utils.ParseRRule(rule, shanghai)
+		assert.True(t, errors.Is(err, utils.ErrInvalidRRule), "规则 %q 应当无效", rule)
+	}
+
+	_, err := utils.ParseRRule("rrule:freq=weekly;byday=mo,fr", shanghai)
+	assert.NoError(t, err)
+}
+
+// 测试每个工作日、隔周、每月、每年和倒数日期的重复
+func TestRRuleNext(t *testing.T) {
+	// 2025-01-01 是星期三
+	dtstart := time.Date(2025, 1, 1, 9, 0, 0, 0, shanghai)
+
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-02 09:00", "2025-01-03 09:00", "2025-01-06 09:00", "2025-01-07 09:00"},
+		occurrences(t, "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", dtstart, 5))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-15 09:00", "2025-01-29 09:00"},
+		occurrences(t, "FREQ=WEEKLY;INTERVAL=2", dtstart, 3))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-04 09:00", "2025-01-07 09:00"},
+		occurrences(t, "FREQ=DAILY;INTERVAL=3", dtstart, 3))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-02-01 09:00", "2025-03-01 09:00"},
+		occurrences(t, "FREQ=MONTHLY;BYMONTHDAY=1", dtstart, 3))
+	assert.Equal(t, []string{"2025-01-31 09:00", "2025-02-28 09:00", "2025-03-31 09:00"},
+		occurrences(t, "FREQ=MONTHLY;BYMONTHDAY=-1", dtstart, 3))
+	// 每月第一个星期一、最后一个星期五
+	assert.Equal(t, []string{"2025-01-06 09:00", "2025-02-03 09:00"},
+		occurrences(t, "FREQ=MONTHLY;BYDAY=1MO", dtstart, 2))
+	assert.Equal(t, []string{"2025-01-31 09:00", "2025-02-28 09:00"},
+		occurrences(t, "FREQ=MONTHLY;BYDAY=-1FR", dtstart, 2))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2026-01-01 09:00"},
+		occurrences(t, "FREQ=YEARLY", dtstart, 2))
+
+	// 没有 BYMONTHDAY 时 31 日按月重复，跳过没有 31 日的月份
+	jan31 := time.Date(2025, 1, 31, 9, 0, 0, 0, shanghai)
+	assert.Equal(t, []string{"2025-01-31 09:00", "2025-03-31 09:00", "2025-05-31 09:00"},
+		occurrences(t, "FREQ=MONTHLY", jan31, 3))
+}
+
+// 测试 COUNT、UNTIL 和 EXDATE
+func TestRRuleLimits(t *testing.T) {
+	dtstart := time.Date(2025, 1, 1, 9, 0, 0, 0, shanghai)
+
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-02 09:00", "2025-01-03 09:00"},
+		occurrences(t, "FREQ=DAILY;COUNT=3", dtstart, 10))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-02 09:00"},
+		occurrences(t, "FREQ=DAILY;UNTIL=20250102", dtstart, 10))
+	// UNTIL 以 Z 结尾时为 UTC 时间，2025-01-02 01:00Z 即北京时间 09:00
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-02 09:00"},
+		occurrences(t, "FREQ=DAILY;UNTIL=20250102T010000Z", dtstart, 10))
+	// 被排除的提醒也计入 COUNT
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-04 09:00"},
+		occurrencesExcept(t, "FREQ=DAILY;COUNT=4", "20250102,20250103T090000", dtstart, 10))
+	assert.Equal(t, []string{"2025-01-01 09:00", "2025-01-03 09:00"},
+		occurrencesExcept(t, "FREQ=DAILY;COUNT=3", "EXDATE:20250102T010000Z", dtstart, 10))
+	rr, err := utils.ParseRRule("FREQ=DAILY", shanghai)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(rr.ParseExDates("2025-01-02", shanghai), utils.ErrInvalidRRule))
+
+	// 永远不会命中的规则不会无限循环
+	rr, err = utils.ParseRRule("FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30", shanghai)
+	assert.NoError(t, err)
+	_, ok := rr.Next(time.Date(2025, 2, 1, 9, 0, 0, 0, shanghai), dtstart)
+	assert.False(t, ok)
+}
+
+// 测试下一次提醒时间按用户时区计算，夏令时切换后仍然是当地的同一时刻
+func TestNextOccurrence(t *testing.T) {
+	once := &models.Reminder{RemindAt: models.JSONTime{Time: time.Date(2025, 1, 1, 9, 0, 0, 0, shanghai)}}
+	next, ok, err := services.NextOccurrence(once, shanghai, time.Date(2024, 12, 31, 0, 0, 0, 0, shanghai))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, next.Equal(once.RemindAt.Time))
+	_, ok, _ = services.NextOccurrence(once, shanghai, once.RemindAt.Time)
+	assert.False(t, ok)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	// 2025-03-09 美国东部进入夏令时，remind_at 以 UTC 保存
+	daily := &models.Reminder{
+		RemindAt: models.JSONTime{Time: time.Date(2025, 3, 7, 9, 0, 0, 0, newYork).UTC()},
+		RRule:    "FREQ=DAILY",
+	}
+	next, ok, err = services.NextOccurrence(daily, newYork, time.Date(2025, 3, 9, 12, 0, 0, 0, newYork))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2025-03-10 09:00 -0400", next.In(newYork).Format("2006-01-02 15:04 -0700"))
+
+	_, _, err = services.NextOccurrence(&models.Reminder{RRule: "FREQ=SECONDLY"}, shanghai, time.Now())
+	assert.True(t, errors.Is(err, utils.ErrInvalidRRule))
+
+	// 排除日期保存在单独的字段中
+	daily = &models.Reminder{
+		RemindAt: models.JSONTime{Time: time.Date(2025, 1, 1, 9, 0, 0, 0, shanghai)},
+		RRule:    "FREQ=DAILY",
+		ExDate:   "20250102",
+	}
+	next, ok, err = services.NextOccurrence(daily, shanghai, daily.RemindAt.Time)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2025-01-03 09:00", next.In(shanghai).Format("2006-01-02 15:04"))
+}
+
+// 测试重复设置的校验：单次提醒不能设置排除日期，重复提醒的起始时间不能过早
+func TestValidateRecurrence(t *testing.T) {
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, shanghai)
+	at := func(years int) models.JSONTime { return models.JSONTime{Time: now.AddDate(years, 0, 0)} }
+
+	assert.NoError(t, services.ValidateRecurrence(&models.Reminder{RemindAt: at(1)}, now))
+	assert.Equal(t, services.ErrExDateWithoutRRule, services.ValidateRecurrence(&models.Reminder{RemindAt: at(1), ExDate: "20260102"}, now))
+	assert.NoError(t, services.ValidateRecurrence(&models.Reminder{RemindAt: at(-9), RRule: "FREQ=DAILY"}, now))
+	assert.Equal(t, services.ErrRecurrenceStartTooEarly, services.ValidateRecurrence(&models.Reminder{RemindAt: at(-11), RRule: "FREQ=DAILY"}, now))
+	assert.Equal(t, services.ErrRecurrenceStartTooEarly, services.ValidateRecurrence(&models.Reminder{RRule: "FREQ=DAILY"}, now))
+}
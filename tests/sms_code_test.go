@@ -0,0 +1,38 @@
+package tests__test
+
+import (
+	"calendarReminder-service/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// 测试验证码生成：固定位数、只包含数字
+func TestGenerateNumericCode(t *testing.T) {
+	pattern := regexp.MustCompile(`^\d{6}$`)
+	for i := 0; i < 100; i++ {
+		code, err := utils.GenerateNumericCode(6)
+		assert.NoError(t, err, "生成验证码失败")
+		assert.Regexp(t, pattern, code, "验证码格式不正确")
+	}
+}
+
+// 测试验证码哈希：同一手机号和验证码结果稳定，不同手机号结果不同
+func TestHashSMSCode(t *testing.T) {
+	hash := utils.HashSMSCode("13800138000", "123456")
+	assert.Equal(t, hash, utils.HashSMSCode("13800138000", "123456"))
+	assert.NotEqual(t, hash, utils.HashSMSCode("13900139000", "123456"))
+	assert.NotContains(t, hash, "123456", "哈希中不应包含验证码明文")
+
+	// 哈希依赖服务端密钥，只知道手机号无法穷举出验证码
+	plain := sha256.Sum256([]byte("13800138000:123456"))
+	assert.NotEqual(t, hex.EncodeToString(plain[:]), hash)
+	assert.Error(t, utils.SetSMSCodeSecret("too-short"))
+	assert.NoError(t, utils.SetSMSCodeSecret(strings.Repeat("a", 32)))
+	withA := utils.HashSMSCode("13800138000", "123456")
+	assert.NoError(t, utils.SetSMSCodeSecret(strings.Repeat("b", 32)))
+	assert.NotEqual(t, withA, utils.HashSMSCode("13800138000", "123456"), "不同密钥的哈希应该不同")
+}
@@ -0,0 +1,159 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/store"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试内存验证数据存储：读写、一次性读取、计数和过期
+func TestMemoryCodeStore(t *testing.T) {
+	codeStore := store.NewMemoryCodeStore()
+
+	_, err := codeStore.Get("MOBILE_SMSCODE:+8613800138000")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	assert.NoError(t, codeStore.Set("MOBILE_SMSCODE:+8613800138000", "hash", time.Minute))
+	value, err := codeStore.Get("MOBILE_SMSCODE:+8613800138000")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", value)
+	ttl, _ := codeStore.TTL("MOBILE_SMSCODE:+8613800138000")
+	assert.True(t, ttl > 0 && ttl <= time.Minute, "剩余有效期不正确: %v", ttl)
+
+	// Take 只能取走一次
+	value, err = codeStore.Take("MOBILE_SMSCODE:+8613800138000")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", value)
+	_, err = codeStore.Take("MOBILE_SMSCODE:+8613800138000")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// 计数从 1 开始，过期后重新计数
+	for i := int64(1); i <= 3; i++ {
+		count, err := codeStore.Incr("MOBILE_SMSCODE_FAIL:+8613800138000", 50*time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, i, count)
+	}
+	time.Sleep(60 * time.Millisecond)
+	count, _ := codeStore.Incr("MOBILE_SMSCODE_FAIL:+8613800138000", 50*time.Millisecond)
+	assert.Equal(t, int64(1), count)
+
+	// 不存在的 key 剩余有效期为 0，没有过期时间时为 -1
+	ttl, _ = codeStore.TTL("MOBILE_SMSCODE_LOCK:+8613800138000")
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.NoError(t, codeStore.Set("MOBILE_SMSCODE_LOCK:+8613800138000", "1", 0))
+	ttl, _ = codeStore.TTL("MOBILE_SMSCODE_LOCK:+8613800138000")
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	assert.NoError(t, codeStore.Delete("MOBILE_SMSCODE_LOCK:+8613800138000", "MOBILE_SMSCODE_FAIL:+8613800138000"))
+	_, err = codeStore.Get("MOBILE_SMSCODE_LOCK:+8613800138000")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+// 测试比较并删除：值不一致时不删除，并发请求中只有一个能删除成功
+func TestMemoryCodeStoreDeleteIfEqual(t *testing.T) {
+	codeStore := store.NewMemoryCodeStore()
+	assert.NoError(t, codeStore.Set("MOBILE_SMSCODE:+8613800138000", "hash", time.Minute))
+
+	deleted, err := codeStore.DeleteIfEqual("MOBILE_SMSCODE:+8613800138000", "wrong")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	_, err = codeStore.Get("MOBILE_SMSCODE:+8613800138000")
+	assert.NoError(t, err, "值不一致时不能删除")
+
+	var wg sync.WaitGroup
+	var succeeded int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if deleted, _ := codeStore.DeleteIfEqual("MOBILE_SMSCODE:+8613800138000", "hash"); deleted {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded, "同一个值只能被删除一次")
+
+	deleted, _ = codeStore.DeleteIfEqual("MOBILE_SMSCODE:+8613800138000", "hash")
+	assert.False(t, deleted)
+}
+
+// 测试基于内存会话存储的会话服务：创建、校验、列表和注销
+func TestSessionServiceWithMemoryStore(t *testing.T) {
+	sessionService := services.NewSessionService(store.NewMemorySessionStore())
+
+	session, token, err := sessionService.CreateSession("C1", "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	_, otherToken, err := sessionService.CreateSession("C1", "电脑", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	_, _, err = sessionService.CreateSession("C2", "手机", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	validated, err := sessionService.ValidateToken(token)
+	assert.NoError(t, err)
+	if assert.NotNil(t, validated) {
+		assert.Equal(t, session.ID, validated.ID)
+	}
+	validated, _ = sessionService.ValidateToken("invalid")
+	assert.Nil(t, validated)
+
+	sessions, err := sessionService.ListSessions("C1")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	// 不能注销其他用户的会话
+	assert.ErrorIs(t, sessionService.RevokeSession("C2", session.ID), services.ErrSessionNotFound)
+	assert.NoError(t, sessionService.RevokeSession("C1", session.ID))
+	validated, _ = sessionService.ValidateToken(token)
+	assert.Nil(t, validated)
+
+	assert.NoError(t, sessionService.RevokeAllSessions("C1"))
+	validated, _ = sessionService.ValidateToken(otherToken)
+	assert.Nil(t, validated)
+	sessions, _ = sessionService.ListSessions("C2")
+	assert.Len(t, sessions, 1, "其他用户的会话不受影响")
+}
+
+// 测试内存会话存储中的会话到期后失效
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	sessionStore := store.NewMemorySessionStore()
+	record := &store.SessionRecord{
+		Session:   models.Session{ID: "S1", CreatorID: "C1"},
+		TokenHash: "hash",
+	}
+	assert.NoError(t, sessionStore.Create(record, 50*time.Millisecond))
+
+	found, err := sessionStore.GetByTokenHash("hash")
+	assert.NoError(t, err)
+	assert.NotNil(t, found)
+
+	time.Sleep(60 * time.Millisecond)
+	found, _ = sessionStore.GetByTokenHash("hash")
+	assert.Nil(t, found)
+	records, _ := sessionStore.ListByCreatorID("C1")
+	assert.Empty(t, records)
+}
+
+// 测试会话被删除后，并发的活跃时间更新和续期不会重新创建会话
+func TestMemorySessionStoreUpdateAfterDelete(t *testing.T) {
+	sessionStore := store.NewMemorySessionStore()
+	record := &store.SessionRecord{
+		Session:   models.Session{ID: "S1", CreatorID: "C1"},
+		TokenHash: "hash",
+	}
+	assert.NoError(t, sessionStore.Create(record, time.Minute))
+	assert.NoError(t, sessionStore.Delete(record))
+
+	assert.NoError(t, sessionStore.Update(record))
+	assert.NoError(t, sessionStore.Refresh(record, time.Minute))
+	found, err := sessionStore.Get("S1")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	found, _ = sessionStore.GetByTokenHash("hash")
+	assert.Nil(t, found)
+}
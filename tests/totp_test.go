@@ -0,0 +1,140 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"calendarReminder-service/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 附录 B 的 SHA-1 测试密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// 测试 TOTP 算法：RFC 6238 测试向量和前后一个时间步的容差
+func TestTOTPCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := utils.TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, "时间 %d 的动态验证码不正确", unix)
+	}
+
+	now := time.Unix(1111111109, 0)
+	step, ok := utils.VerifyTOTP(rfc6238Secret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111109/30), step)
+
+	_, ok = utils.VerifyTOTP(rfc6238Secret, "081804", now.Add(30*time.Second))
+	assert.True(t, ok, "应允许前一个时间步的验证码")
+	_, ok = utils.VerifyTOTP(rfc6238Secret, "081804", now.Add(90*time.Second))
+	assert.False(t, ok, "超过容差的验证码应被拒绝")
+	_, ok = utils.VerifyTOTP(rfc6238Secret, "000000", now)
+	assert.False(t, ok)
+}
+
+// 测试 otpauth 地址包含身份验证器需要的参数
+func TestTOTPURI(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri, err := url.Parse(utils.TOTPURI("CalendarReminder", "+8613800138000", secret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/CalendarReminder:+8613800138000", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "CalendarReminder", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
+
+// 测试两步验证的设置、登录校验、防重放、恢复码和关闭
+func TestTOTPService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserTOTP{}, &models.RecoveryCode{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	totpService := services.NewTOTPService(db, "CalendarReminder")
+
+	// 未设置时不需要两步验证
+	enabled, err := totpService.Enabled("C1")
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+	_, err = totpService.Confirm("C1", "123456")
+	assert.ErrorIs(t, err, services.ErrTOTPNotEnrolled)
+
+	secret, uri, err := totpService.Enroll("C1", "+8613800138000")
+	assert.NoError(t, err)
+	assert.Contains(t, uri, "secret="+secret)
+
+	// 确认前不生效
+	enabled, _ = totpService.Enabled("C1")
+	assert.False(t, enabled)
+
+	_, err = totpService.Confirm("C1", "000000")
+	assert.ErrorIs(t, err, services.ErrTOTPInvalidCode)
+
+	now := time.Now()
+	code, _ := utils.TOTPCode(secret, now)
+	recoveryCodes, err := totpService.Confirm("C1", code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10)
+
+	enabled, _ = totpService.Enabled("C1")
+	assert.True(t, enabled)
+	_, _, err = totpService.Enroll("C1", "+8613800138000")
+	assert.ErrorIs(t, err, services.ErrTOTPAlreadyEnabled)
+
+	// 确认时使用过的验证码不能再次使用
+	ok, err := totpService.Verify("C1", code)
+	assert.NoError(t, err)
+	assert.False(t, ok, "同一动态验证码不能重复使用")
+
+	// 下一个时间步的验证码在容差范围内，只能使用一次
+	next, _ := utils.TOTPCode(secret, now.Add(30*time.Second))
+	ok, err = totpService.Verify("C1", next)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = totpService.Verify("C1", next)
+	assert.False(t, ok)
+
+	// 恢复码忽略大小写和横线，只能使用一次
+	ok, err = totpService.Verify("C1", strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", "")))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = totpService.Verify("C1", recoveryCodes[0])
+	assert.False(t, ok, "恢复码不能重复使用")
+
+	var hashes []models.RecoveryCode
+	db.Where("creator_id = ?", "C1").Find(&hashes)
+	for _, h := range hashes {
+		assert.NotEqual(t, recoveryCodes[1], h.CodeHash, "数据库中不应保存恢复码明文")
+	}
+
+	// 关闭需要有效的验证码
+	assert.ErrorIs(t, totpService.Disable("C1", "000000"), services.ErrTOTPInvalidCode)
+	assert.NoError(t, totpService.Disable("C1", recoveryCodes[1]))
+	enabled, _ = totpService.Enabled("C1")
+	assert.False(t, enabled)
+
+	var count int64
+	db.Model(&models.RecoveryCode{}).Where("creator_id = ?", "C1").Count(&count)
+	assert.Zero(t, count, "关闭后应删除恢复码")
+}
@@ -0,0 +1,51 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试更换手机号：成功更换、手机号已被占用、用户不存在
+func TestUpdateMobile(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+
+	userService := services.NewUserService(db, &SequenceIDGenerator{})
+	user, err := userService.CreateUser("13800138000")
+	assert.NoError(t, err)
+	other, err := userService.CreateUser("13900139000")
+	assert.NoError(t, err)
+
+	err = userService.UpdateMobile(user.CreatorID, "13700137000")
+	assert.NoError(t, err)
+	updated, err := userService.GetUserByMobile("13700137000")
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated) {
+		assert.Equal(t, user.CreatorID, updated.CreatorID)
+	}
+	exists, err := userService.QueryMobileIsExist("13800138000")
+	assert.NoError(t, err)
+	assert.False(t, exists, "原手机号应当释放")
+
+	err = userService.UpdateMobile(user.CreatorID, other.Mobile)
+	assert.Equal(t, services.ErrMobileTaken, err)
+
+	err = userService.UpdateMobile("not-exist", "13600136000")
+	assert.Equal(t, services.ErrUserNotFound, err)
+
+	// 第三方登录注册的用户没有手机号，可以绑定手机号
+	oidcUser, err := userService.CreateUserWithIdentity("https://idp.example.com", "sub-1", "a@example.com")
+	assert.NoError(t, err)
+	err = userService.UpdateMobile(oidcUser.CreatorID, "13600136000")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,68 @@
+package tests__test
+
+import (
+	"calendarReminder-service/models"
+	"calendarReminder-service/services"
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SequenceIDGenerator 按顺序生成不重复的 ID
+type SequenceIDGenerator struct {
+	next int
+}
+
+func (g *SequenceIDGenerator) GenerateUniqueID() (string, error) {
+	g.next++
+	return fmt.Sprintf("seq_creator_%d", g.next), nil
+}
+
+// 测试通过第三方身份查找和注册用户
+func TestUserServiceIdentity(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法连接到内存数据库: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}); err != nil {
+		t.Fatalf("无法自动迁移模型: %v", err)
+	}
+	userService := services.NewUserService(db, &SequenceIDGenerator{})
+
+	// 尚未关联时返回 nil
+	user, err := userService.GetUserByIdentity("https://idp.example.com", "sub-1")
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+
+	// 注册两个没有手机号的用户，手机号唯一约束不应冲突
+	created, err := userService.CreateUserWithIdentity("https://idp.example.com", "sub-1", "a@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "", created.Mobile)
+	_, err = userService.CreateUserWithIdentity("https://idp.example.com", "sub-2", "b@example.com")
+	assert.NoError(t, err, "多个没有手机号的用户应该可以共存")
+
+	// 同一个第三方身份不能重复关联
+	_, err = userService.CreateUserWithIdentity("https://idp.example.com", "sub-1", "a@example.com")
+	assert.Error(t, err)
+
+	// 按第三方身份查找到同一个用户
+	found, err := userService.GetUserByIdentity("https://idp.example.com", "sub-1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, created.CreatorID, found.CreatorID)
+		assert.Equal(t, "", found.Mobile)
+	}
+
+	// 不同 issuer 下相同的 sub 是不同的身份
+	other, err := userService.GetUserByIdentity("https://other.example.com", "sub-1")
+	assert.NoError(t, err)
+	assert.Nil(t, other)
+
+	// 空手机号不会匹配到第三方注册的用户
+	exists, err := userService.QueryMobileIsExist("")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
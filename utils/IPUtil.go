@@ -1,41 +1,139 @@
 package utils
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 )
 
+// 可信代理网段，只有来自这些地址的转发头才会被采信
+var (
+	trustedProxies   []*net.IPNet
+	trustedProxiesMu sync.RWMutex
+)
+
+// SetTrustedProxies 设置可信代理的 CIDR 列表，单个 IP 视为 /32 或 /128
+func SetTrustedProxies(cidrs []string) error {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("无效的可信代理地址: %s", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("无效的可信代理网段: %s", cidr)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	trustedProxiesMu.Lock()
+	trustedProxies = nets
+	trustedProxiesMu.Unlock()
+	return nil
+}
+
+// isTrustedProxy 判断 IP 是否属于可信代理
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRequestIP 获取用户请求的 IP 地址
-// 处理多级代理，尝试从 X-Forwarded-For 等头部信息获取客户端的真实 IP
+// 只有直连地址是可信代理时才读取转发头；从右向左遍历转发链，跳过可信代理，
+// 遇到的第一个不可信地址即为客户端 IP，避免客户端伪造转发头绕过基于 IP 的限流
 func GetRequestIP(r *http.Request) string {
-	// 从 X-Forwarded-For 头中获取 IP
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" || strings.ToLower(ip) == "unknown" {
-		// 从 Proxy-Client-IP 头中获取 IP
-		ip = r.Header.Get("Proxy-Client-IP")
+	remoteIP := stripPort(r.RemoteAddr)
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !isTrustedProxy(ip) {
+		return remoteIP
 	}
-	if ip == "" || strings.ToLower(ip) == "unknown" {
-		// 从 WL-Proxy-Client-IP 头中获取 IP
-		ip = r.Header.Get("WL-Proxy-Client-IP")
+
+	// 优先使用标准的 Forwarded 头，其次是 X-Forwarded-For
+	hops := parseForwarded(r.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		hops = parseXForwardedFor(r.Header.Values("X-Forwarded-For"))
 	}
-	if ip == "" || strings.ToLower(ip) == "unknown" {
-		// 从 HTTP_CLIENT_IP 头中获取 IP
-		ip = r.Header.Get("HTTP_CLIENT_IP")
+	if len(hops) == 0 {
+		// 没有转发链时，尝试代理设置的 X-Real-IP
+		if realIP := net.ParseIP(stripPort(strings.TrimSpace(r.Header.Get("X-Real-IP")))); realIP != nil {
+			return realIP.String()
+		}
+		return remoteIP
 	}
-	if ip == "" || strings.ToLower(ip) == "unknown" {
-		// 从 HTTP_X_FORWARDED_FOR 头中获取 IP
-		ip = r.Header.Get("HTTP_X_FORWARDED_FOR")
+
+	clientIP := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(hops[i])
+		if hop == nil {
+			// 无法识别的地址（如 unknown 或混淆标识），以最近一个已知地址为准
+			break
+		}
+		clientIP = hop.String()
+		if !isTrustedProxy(hop) {
+			break
+		}
 	}
-	if ip == "" || strings.ToLower(ip) == "unknown" {
-		// 最后从 RemoteAddr 获取
-		ip = r.RemoteAddr
+	return clientIP
+}
+
+// parseXForwardedFor 解析 X-Forwarded-For，多个头部按出现顺序拼接
+func parseXForwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			hops = append(hops, stripPort(part))
+		}
 	}
+	return hops
+}
 
-	// 如果 IP 是通过 X-Forwarded-For 获取的，可能是逗号分隔的多个 IP 地址
-	// 取第一个有效 IP
-	if strings.Contains(ip, ",") {
-		ip = strings.TrimSpace(strings.Split(ip, ",")[0])
+// parseForwarded 解析 RFC 7239 Forwarded 头中的 for 参数
+// 例如: Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::17]:4711"
+func parseForwarded(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(key), "for") {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				hops = append(hops, stripPort(val))
+			}
+		}
 	}
+	return hops
+}
 
-	return ip
+// stripPort 去掉地址中的端口，兼容 "1.2.3.4:80"、"[::1]:80"、"[::1]" 和不带端口的地址
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
 }
@@ -1,18 +1,153 @@
 package utils
 
-import "regexp"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
 
-// 国内手机号码的正则表达式
-const chinaPhoneNumberRegex = `^1[3-9]\d{9}$`
+// phoneRule 一个国家/地区的手机号规则
+type phoneRule struct {
+	callingCode string         // 国际电话区号，不含 +
+	trunkPrefix string         // 国内长途冠码，例如英国的 0，E.164 格式中需要去掉
+	pattern     *regexp.Regexp // 去掉区号和冠码后的国内手机号格式
+}
+
+// 支持的国家/地区手机号规则，key 为 ISO 3166-1 二位代码
+var phoneRules = map[string]phoneRule{
+	"CN": {callingCode: "86", pattern: regexp.MustCompile(`^1[3-9]\d{9}$`)},
+	"HK": {callingCode: "852", pattern: regexp.MustCompile(`^[4-9]\d{7}$`)},
+	"MO": {callingCode: "853", pattern: regexp.MustCompile(`^6\d{7}$`)},
+	"TW": {callingCode: "886", trunkPrefix: "0", pattern: regexp.MustCompile(`^9\d{8}$`)},
+	"SG": {callingCode: "65", pattern: regexp.MustCompile(`^[89]\d{7}$`)},
+	"JP": {callingCode: "81", trunkPrefix: "0", pattern: regexp.MustCompile(`^[789]0\d{8}$`)},
+	"KR": {callingCode: "82", trunkPrefix: "0", pattern: regexp.MustCompile(`^1[016789]\d{7,8}$`)},
+	"US": {callingCode: "1", pattern: regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)},
+	"GB": {callingCode: "44", trunkPrefix: "0", pattern: regexp.MustCompile(`^7\d{9}$`)},
+	"DE": {callingCode: "49", trunkPrefix: "0", pattern: regexp.MustCompile(`^1[5-7]\d{8,9}$`)},
+	"FR": {callingCode: "33", trunkPrefix: "0", pattern: regexp.MustCompile(`^[67]\d{8}$`)},
+	"AU": {callingCode: "61", trunkPrefix: "0", pattern: regexp.MustCompile(`^4\d{8}$`)},
+}
+
+var (
+	// ErrInvalidPhoneNumber 手机号格式不正确
+	ErrInvalidPhoneNumber = errors.New("手机号格式不正确")
+	// ErrPhoneCountryNotAllowed 手机号所属国家/地区不在允许的列表中
+	ErrPhoneCountryNotAllowed = errors.New("暂不支持该国家/地区的手机号")
+)
 
-// 校验手机号是否符合国内的标准
+// 允许注册的国家/地区和不带区号时使用的默认国家/地区，启动时通过 SetPhoneCountries 设置
+var (
+	phoneMu               sync.RWMutex
+	allowedPhoneCountries = map[string]bool{"CN": true}
+	defaultPhoneCountry   = "CN"
+)
+
+// SetPhoneCountries 设置允许的国家/地区列表和默认国家/地区，列表为空时只允许默认国家/地区
+func SetPhoneCountries(allowed []string, defaultCountry string) error {
+	defaultCountry = strings.ToUpper(strings.TrimSpace(defaultCountry))
+	if _, ok := phoneRules[defaultCountry]; !ok {
+		return fmt.Errorf("不支持的默认国家/地区: %q", defaultCountry)
+	}
+
+	countries := map[string]bool{defaultCountry: true}
+	for _, country := range allowed {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if _, ok := phoneRules[country]; !ok {
+			return fmt.Errorf("不支持的国家/地区: %q", country)
+		}
+		countries[country] = true
+	}
+
+	phoneMu.Lock()
+	defer phoneMu.Unlock()
+	allowedPhoneCountries = countries
+	defaultPhoneCountry = defaultCountry
+	return nil
+}
+
+// NormalizePhoneNumber 解析手机号并转换为 E.164 格式（例如 +8613800138000）。
+// 支持 +区号、00区号 开头的国际格式，不带区号时按默认国家/地区解析；忽略空格、横线、点和括号
+func NormalizePhoneNumber(mobile string) (string, error) {
+	number := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(mobile))
+
+	phoneMu.RLock()
+	defaultCountry := defaultPhoneCountry
+	allowed := allowedPhoneCountries
+	phoneMu.RUnlock()
+
+	var country, national string
+	switch {
+	case strings.HasPrefix(number, "+"):
+		country, national = splitCallingCode(number[1:])
+	case strings.HasPrefix(number, "00"):
+		country, national = splitCallingCode(number[2:])
+	default:
+		country, national = defaultCountry, number
+	}
+	if country == "" {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	rule := phoneRules[country]
+	if rule.trunkPrefix != "" {
+		national = strings.TrimPrefix(national, rule.trunkPrefix)
+	}
+	if !rule.pattern.MatchString(national) {
+		return "", ErrInvalidPhoneNumber
+	}
+	if !allowed[country] {
+		return "", ErrPhoneCountryNotAllowed
+	}
+	return "+" + rule.callingCode + national, nil
+}
+
+// 按区号长度从长到短排列的国家/地区，拆分区号时优先匹配更长的区号，例如 852 优先于 8
+var callingCodeOrder = func() []string {
+	countries := make([]string, 0, len(phoneRules))
+	for country := range phoneRules {
+		countries = append(countries, country)
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		return len(phoneRules[countries[i]].callingCode) > len(phoneRules[countries[j]].callingCode)
+	})
+	return countries
+}()
+
+// splitCallingCode 拆分国际区号，返回国家/地区代码和国内号码
+func splitCallingCode(number string) (string, string) {
+	for _, country := range callingCodeOrder {
+		if code := phoneRules[country].callingCode; strings.HasPrefix(number, code) {
+			return country, number[len(code):]
+		}
+	}
+	return "", ""
+}
+
+// 校验手机号是否是允许的国家/地区的有效手机号
 func IsValidPhoneNumber(mobile string) bool {
 	// 如果手机号为空，则返回false
 	if mobile == "" {
 		return false
 	}
 
-	// 使用正则表达式进行校验
-	pattern := regexp.MustCompile(chinaPhoneNumberRegex)
-	return pattern.MatchString(mobile)
+	_, err := NormalizePhoneNumber(mobile)
+	return err == nil
+}
+
+// smsPhoneNumber 把 E.164 格式转换为短信服务商要求的格式：国内号码不带区号，国际号码为区号加号码、不带 +
+func smsPhoneNumber(mobile string) string {
+	if national, ok := strings.CutPrefix(mobile, "+"+phoneRules["CN"].callingCode); ok {
+		return national
+	}
+	return strings.TrimPrefix(mobile, "+")
 }
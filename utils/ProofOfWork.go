@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"math/bits"
+)
+
+// VerifyProofOfWork 校验 hashcash 风格的工作量证明：
+// SHA-256(challenge + ":" + nonce) 的前 difficulty 个比特必须全为 0
+func VerifyProofOfWork(challenge, nonce string, difficulty int) bool {
+	if nonce == "" || difficulty < 0 || difficulty > 256 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+// leadingZeroBits 统计字节数组开头连续为 0 的比特数
+func leadingZeroBits(data []byte) int {
+	count := 0
+	for _, b := range data {
+		if b == 0 {
+			count += 8
+			continue
+		}
+		return count + bits.LeadingZeros8(b)
+	}
+	return count
+}
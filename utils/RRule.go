@@ -0,0 +1,379 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRRule 重复规则格式不正确
+var ErrInvalidRRule = errors.New("重复规则格式不正确")
+
+// 支持的重复频率
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// 计算下一次发生时间时最多向后查找的年数，防止永远不会命中的规则（例如每 12 个月的 2 月 30 日）无限循环
+const rruleLookaheadYears = 100
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRuleWeekday BYDAY 中的一项，N 为月内第几个（负数表示倒数第几个），0 表示每一个
+type RRuleWeekday struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// RRule RFC 5545 重复规则，支持 FREQ、INTERVAL、BYDAY、BYMONTHDAY、COUNT、UNTIL；
+// 排除日期在 RFC 5545 中是单独的 EXDATE 属性，通过 ParseExDates 设置
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []RRuleWeekday
+	ByMonthDay []int
+	Count      int
+	Until      time.Time // 零值表示不限结束时间
+	ExDates    []time.Time
+
+	exDays map[string]bool // 只写日期的 EXDATE 排除当天所有提醒
+}
+
+// ParseRRule 解析重复规则，例如 "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"。
+// 不带 Z 的 UNTIL、EXDATE 按 loc 时区解释
+func ParseRRule(rule string, loc *time.Location) (*RRule, error) {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, "RRULE:")
+	if rule == "" {
+		return nil, fmt.Errorf("%w: 规则为空", ErrInvalidRRule)
+	}
+
+	rr := &RRule{Interval: 1, exDays: map[string]bool{}}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s 重复出现", ErrInvalidRRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rr.Freq = value
+			default:
+				err = fmt.Errorf("不支持的 FREQ: %s", value)
+			}
+		case "INTERVAL":
+			rr.Interval, err = parsePositive(value)
+		case "COUNT":
+			rr.Count, err = parsePositive(value)
+		case "UNTIL":
+			rr.Until, err = parseRRuleUntil(value, loc)
+		case "BYDAY":
+			rr.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rr.ByMonthDay, err = parseByMonthDay(value)
+		case "EXDATE":
+			err = errors.New("EXDATE 不属于重复规则，请单独设置排除日期")
+		default:
+			err = fmt.Errorf("不支持的规则项: %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRRule, err)
+		}
+	}
+
+	if rr.Freq == "" {
+		return nil, fmt.Errorf("%w: 缺少 FREQ", ErrInvalidRRule)
+	}
+	if rr.Count > 0 && !rr.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT 和 UNTIL 不能同时使用", ErrInvalidRRule)
+	}
+	for _, day := range rr.ByDay {
+		if day.N != 0 && rr.Freq != FreqMonthly {
+			return nil, fmt.Errorf("%w: 只有 FREQ=MONTHLY 时 BYDAY 可以带序号", ErrInvalidRRule)
+		}
+	}
+	if rr.Freq == FreqYearly && len(rr.ByDay) > 0 {
+		return nil, fmt.Errorf("%w: FREQ=YEARLY 不支持 BYDAY", ErrInvalidRRule)
+	}
+	return rr, nil
+}
+
+// Next 返回 dtstart 开始的重复序列中晚于 after 的第一次提醒时间，没有时返回 false。
+// 每次提醒的时刻与 dtstart 在其时区中的时分秒相同，dtstart 本身不符合规则时不算一次提醒
+func (rr *RRule) Next(dtstart, after time.Time) (time.Time, bool) {
+	dtstart = dtstart.Truncate(time.Second)
+	horizon := after.AddDate(rruleLookaheadYears, 0, 0)
+	count := 0
+	for period := 0; ; period++ {
+		candidates, periodStart := rr.candidates(dtstart, period)
+		if periodStart.After(horizon) {
+			return time.Time{}, false
+		}
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !rr.Until.IsZero() && t.After(rr.Until) {
+				return time.Time{}, false
+			}
+			// COUNT 统计规则生成的所有提醒，包括被 EXDATE 排除的
+			count++
+			if rr.Count > 0 && count > rr.Count {
+				return time.Time{}, false
+			}
+			if rr.excluded(t) {
+				continue
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+}
+
+// candidates 返回第 period 个周期内按时间排序的候选提醒时间，以及该周期的起始日期
+func (rr *RRule) candidates(dtstart time.Time, period int) ([]time.Time, time.Time) {
+	loc := dtstart.Location()
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, sec, 0, loc)
+	}
+	step := period * rr.Interval
+
+	var result []time.Time
+	switch rr.Freq {
+	case FreqDaily:
+		t := at(year, month, day+step)
+		if rr.matchWeekday(t) && rr.matchMonthDay(t) {
+			result = append(result, t)
+		}
+		return result, t
+	case FreqWeekly:
+		// 以周一作为一周的开始（RFC 5545 默认的 WKST=MO）
+		weekStart := at(year, month, day-(int(dtstart.Weekday())+6)%7+step*7)
+		for i := 0; i < 7; i++ {
+			t := at(weekStart.Year(), weekStart.Month(), weekStart.Day()+i)
+			matchDay := t.Weekday() == dtstart.Weekday()
+			if len(rr.ByDay) > 0 {
+				matchDay = rr.matchWeekday(t)
+			}
+			if matchDay && rr.matchMonthDay(t) {
+				result = append(result, t)
+			}
+		}
+		return result, weekStart
+	case FreqMonthly:
+		monthStart := at(year, month+time.Month(step), 1)
+		for d := 1; d <= daysIn(monthStart); d++ {
+			t := at(monthStart.Year(), monthStart.Month(), d)
+			if rr.matchMonthly(t, day) {
+				result = append(result, t)
+			}
+		}
+		return result, monthStart
+	default: // FreqYearly，在 dtstart 所在月份内按 BYMONTHDAY 或 dtstart 的日期重复
+		monthStart := at(year+step, month, 1)
+		for d := 1; d <= daysIn(monthStart); d++ {
+			t := at(monthStart.Year(), monthStart.Month(), d)
+			if (len(rr.ByMonthDay) == 0 && d == day) || (len(rr.ByMonthDay) > 0 && rr.matchMonthDay(t)) {
+				result = append(result, t)
+			}
+		}
+		return result, monthStart
+	}
+}
+
+// matchMonthly 判断 t 是否符合按月重复的规则，没有 BYDAY 和 BYMONTHDAY 时在 dtstart 的日期重复
+func (rr *RRule) matchMonthly(t time.Time, startDay int) bool {
+	if len(rr.ByDay) == 0 && len(rr.ByMonthDay) == 0 {
+		return t.Day() == startDay
+	}
+	if len(rr.ByMonthDay) > 0 && !rr.matchMonthDay(t) {
+		return false
+	}
+	if len(rr.ByDay) == 0 {
+		return true
+	}
+	for _, day := range rr.ByDay {
+		if day.Weekday != t.Weekday() {
+			continue
+		}
+		switch {
+		case day.N == 0:
+			return true
+		case day.N > 0 && (t.Day()-1)/7+1 == day.N:
+			return true
+		case day.N < 0 && (daysIn(t)-t.Day())/7+1 == -day.N:
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday 判断 t 是否符合不带序号的 BYDAY，没有 BYDAY 时总是符合
+func (rr *RRule) matchWeekday(t time.Time) bool {
+	if len(rr.ByDay) == 0 {
+		return true
+	}
+	for _, day := range rr.ByDay {
+		if day.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMonthDay 判断 t 是否符合 BYMONTHDAY，负数表示倒数第几天，没有 BYMONTHDAY 时总是符合
+func (rr *RRule) matchMonthDay(t time.Time) bool {
+	if len(rr.ByMonthDay) == 0 {
+		return true
+	}
+	for _, d := range rr.ByMonthDay {
+		if d == t.Day() || (d < 0 && daysIn(t)+d+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded 判断 t 是否被 EXDATE 排除
+func (rr *RRule) excluded(t time.Time) bool {
+	if rr.exDays[t.Format("20060102")] {
+		return true
+	}
+	for _, ex := range rr.ExDates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseExDates 解析 RFC 5545 EXDATE 属性的值并排除这些提醒，例如 "20250102,20250103T090000"，
+// 可以带 "EXDATE:" 前缀；只写日期时排除当天所有提醒，不带 Z 的时间按 loc 时区解释
+func (rr *RRule) ParseExDates(value string, loc *time.Location) error {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "EXDATE:")
+	if value == "" {
+		return nil
+	}
+	if err := rr.parseExDates(value, loc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRRule, err)
+	}
+	return nil
+}
+
+func (rr *RRule) parseExDates(value string, loc *time.Location) error {
+	for _, item := range strings.Split(value, ",") {
+		if len(item) == len("20060102") {
+			day, err := time.ParseInLocation("20060102", item, loc)
+			if err != nil {
+				return fmt.Errorf("EXDATE 格式不正确: %s", item)
+			}
+			rr.exDays[day.Format("20060102")] = true
+			continue
+		}
+		t, err := parseRRuleTime(item, loc)
+		if err != nil {
+			return fmt.Errorf("EXDATE 格式不正确: %s", item)
+		}
+		rr.ExDates = append(rr.ExDates, t)
+	}
+	return nil
+}
+
+// parseRRuleUntil 解析 UNTIL，只写日期时包含当天
+func parseRRuleUntil(value string, loc *time.Location) (time.Time, error) {
+	if len(value) == len("20060102") {
+		day, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("UNTIL 格式不正确: %s", value)
+		}
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	t, err := parseRRuleTime(value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("UNTIL 格式不正确: %s", value)
+	}
+	return t, nil
+}
+
+// parseRRuleTime 解析 RFC 5545 的 DATE-TIME，以 Z 结尾时为 UTC 时间，否则按 loc 解释
+func parseRRuleTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+func parseByDay(value string) ([]RRuleWeekday, error) {
+	var days []RRuleWeekday
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("BYDAY 格式不正确: %s", item)
+		}
+		weekday, ok := rruleWeekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("BYDAY 格式不正确: %s", item)
+		}
+		day := RRuleWeekday{Weekday: weekday}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("BYDAY 格式不正确: %s", item)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		d, err := strconv.Atoi(item)
+		if err != nil || d == 0 || d < -31 || d > 31 {
+			return nil, fmt.Errorf("BYMONTHDAY 格式不正确: %s", item)
+		}
+		days = append(days, d)
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("必须是正整数: %s", value)
+	}
+	return n, nil
+}
+
+// daysIn 返回 t 所在月份的天数
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Response 统一的响应结构
@@ -12,6 +14,14 @@ type Response struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// 业务错误码，用于区分同一 HTTP 状态码下的不同错误
+const (
+	CodeSMSCodeLocked   = 4291 // 验证码错误次数过多，暂时锁定
+	CodeTOTPLocked      = 4292 // 关闭两步验证时动态验证码错误次数过多，暂时锁定
+	CodeCaptchaRequired = 4031 // 需要先完成人机验证
+	CodeCaptchaInvalid  = 4032 // 人机验证未通过或已过期
+)
+
 // SuccessResponse 成功的响应
 func SuccessResponse(w http.ResponseWriter, data interface{}, message string) {
 	response := Response{
@@ -35,3 +45,25 @@ func ErrorResponse(w http.ResponseWriter, code int, message string) {
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ErrorResponseWithCode 错误的响应，使用单独的业务错误码
+func ErrorResponseWithCode(w http.ResponseWriter, status int, code int, message string) {
+	response := Response{
+		Code:    code,
+		Message: message,
+		Data:    nil,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// TooManyRequestsResponse 429 响应，通过 Retry-After 头告知客户端需要等待的秒数
+func TooManyRequestsResponse(w http.ResponseWriter, retryAfter time.Duration, code int, message string) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second) // 向上取整
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	ErrorResponseWithCode(w, http.StatusTooManyRequests, code, message)
+}
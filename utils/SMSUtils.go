@@ -36,7 +36,7 @@ func SendSMS(mobile string, random string) error {
 	sendSmsRequest := &dysmsapi20170525.SendSmsRequest{
 		SignName:      tea.String("迎客知识"),
 		TemplateCode:  tea.String("SMS_461375482"),
-		PhoneNumbers:  tea.String(mobile),
+		PhoneNumbers:  tea.String(smsPhoneNumber(mobile)),
 		TemplateParam: tea.String(fmt.Sprintf("{\"code\":\"%s\"}", random)),
 	}
 
@@ -85,7 +85,7 @@ func SendSMSReminder(content string, mobile string) error {
 	sendSmsRequest := &dysmsapi20170525.SendSmsRequest{
 		SignName:      tea.String("迎客知识"),
 		TemplateCode:  tea.String("SMS_473770239"),
-		PhoneNumbers:  tea.String(mobile),
+		PhoneNumbers:  tea.String(smsPhoneNumber(mobile)),
 		TemplateParam: tea.String(fmt.Sprintf("{\"value\":\"%s\"}", content)),
 	}
 
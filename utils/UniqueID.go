@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/google/uuid"
+	"math/big"
 )
 
 // IDGenerator 接口定义
@@ -35,3 +39,46 @@ func GenerateUniqueID() (string, error) {
 func GenerateUUID() string {
 	return uuid.New().String() // 生成并返回一个 UUID 字符串
 }
+
+// GenerateNumericCode 使用 crypto/rand 生成指定位数的数字验证码
+func GenerateNumericCode(digits int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+// 验证码 HMAC 密钥的最小长度（字节）
+const minSMSCodeSecretLength = 32
+
+// smsCodeSecret 计算验证码 HMAC 的服务端密钥，启动时通过 SetSMSCodeSecret 设置；
+// 未设置时使用进程内随机生成的密钥，只适用于单机部署和测试
+var smsCodeSecret = func() []byte {
+	secret := make([]byte, minSMSCodeSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// SetSMSCodeSecret 设置验证码 HMAC 的服务端密钥，多实例部署时所有实例必须使用同一个密钥
+func SetSMSCodeSecret(secret string) error {
+	if len(secret) < minSMSCodeSecretLength {
+		return fmt.Errorf("验证码密钥至少需要 %d 个字符", minSMSCodeSecretLength)
+	}
+	smsCodeSecret = []byte(secret)
+	return nil
+}
+
+// HashSMSCode 计算验证码的 HMAC-SHA256，Redis 中只保存哈希；
+// 验证码只有 6 位，没有服务端密钥时能读取 Redis 的人可以直接穷举出明文
+func HashSMSCode(mobile, code string) string {
+	mac := hmac.New(sha256.New, smsCodeSecret)
+	mac.Write([]byte(mobile + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}